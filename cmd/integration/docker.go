@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+// docker runs the docker CLI, and returns its trimmed stdout.
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("docker %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// startKubo runs image in a container, offline, with the API published on a
+// random local port. It returns the ID of the container, and the address
+// of the API.
+func startKubo(ctx context.Context, image string) (string, string, error) {
+	id, err := docker(
+		ctx,
+		"run",
+		"--detach",
+		"--rm",
+		"--publish", "127.0.0.1::5001",
+		image,
+		"daemon", "--offline",
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	port, err := docker(ctx, "port", id, "5001/tcp")
+	if err != nil {
+		return id, "", err
+	}
+
+	// docker port prints a line for each address, like 127.0.0.1:49153.
+	host, portNumber, err := net.SplitHostPort(strings.SplitN(port, "\n", 2)[0])
+	if err != nil {
+		return id, "", fmt.Errorf("parsing published port failed: %w", err)
+	}
+
+	return id, "/ip4/" + host + "/tcp/" + portNumber, nil
+}
+
+// stopKubo removes the container. It uses a new context, so it still runs
+// when the test was interrupted.
+func stopKubo(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := docker(ctx, "stop", id)
+	if err != nil {
+		slog.Warn("stopping kubo container failed", "container", id, "err", err)
+	}
+}
+
+// waitKubo waits until the Kubo API at address responds.
+func waitKubo(ctx context.Context, address string) (*kubo.Client, error) {
+	addr, err := kubo.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("parsing api address failed: %w", err)
+	}
+
+	api, err := kubo.NewAPI(addr, &http.Client{
+		Timeout:   time.Minute,
+		Transport: kubo.NewTransport(addr),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating rpc client failed: %w", err)
+	}
+
+	client := kubo.New(api)
+
+	for {
+		_, err := client.ID(ctx)
+		if err == nil {
+			return client, nil
+		}
+
+		slog.Debug("waiting for kubo", "err", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("kubo didn't start: %w", err)
+		case <-time.After(time.Second):
+		}
+	}
+}
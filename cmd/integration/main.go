@@ -0,0 +1,363 @@
+// Command integration runs the updater against a real Kubo, in a Docker
+// container, and the fake work server from pkg/workapitest. It runs a
+// download, pin, and delete job, and checks the pins, the sizes, and the
+// results reported to the server, like the format of the hash of a
+// download, which is the file and its wrapping directory.
+//
+//	go run ./cmd/integration
+//
+// It exits with 1 if any check failed.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/angaz/ipfspodcasting/pkg/workapitest"
+)
+
+// episodeSize is larger than a few blocks, so the file isn't a single block.
+const episodeSize = 3<<20 + 12345
+
+func main() {
+	kuboImage := flag.String(
+		"kubo-image",
+		"ipfs/kubo:v0.31.0",
+		"Docker image of Kubo to test against",
+	)
+	apiAddress := flag.String(
+		"api-address",
+		"",
+		"Address of the API of a running Kubo to test against, instead of starting a container. Its pins are changed",
+	)
+	timeout := flag.Duration(
+		"timeout",
+		5*time.Minute,
+		"Timeout of the whole test, including pulling the image",
+	)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	err := run(ctx, *kuboImage, *apiAddress)
+	if err != nil {
+		slog.Error("integration test failed", "err", err)
+
+		os.Exit(1)
+	}
+
+	slog.Info("integration test passed")
+}
+
+func run(ctx context.Context, kuboImage string, apiAddress string) error {
+	if apiAddress == "" {
+		slog.Info("starting kubo", "image", kuboImage)
+
+		id, address, err := startKubo(ctx, kuboImage)
+		if id != "" {
+			defer stopKubo(id)
+		}
+		if err != nil {
+			return fmt.Errorf("starting kubo failed: %w", err)
+		}
+
+		apiAddress = address
+	}
+
+	k, err := waitKubo(ctx, apiAddress)
+	if err != nil {
+		return err
+	}
+
+	episode := randomBytes(1, episodeSize)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/episode.mp3" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(episode))
+	}))
+	defer origin.Close()
+
+	server := workapitest.NewServer()
+	defer server.Close()
+
+	server.SetJSON(true)
+
+	u, err := updater.New(k, updater.Options{
+		Email:              "integration@example.com",
+		Name:               "integration",
+		ServerURL:          server.URL,
+		UpdateFrequency:    time.Second,
+		MinUpdateFrequency: time.Second,
+		MaxUpdateFrequency: time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("creating updater failed: %w", err)
+	}
+
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+
+	go func() {
+		_ = u.Run(runCtx)
+	}()
+
+	c := &cycle{
+		kubo:   k,
+		server: server,
+	}
+
+	downloaded, err := c.download(ctx, origin.URL+"/episode.mp3", episode)
+	if err != nil {
+		return fmt.Errorf("download job: %w", err)
+	}
+
+	err = c.pin(ctx)
+	if err != nil {
+		return fmt.Errorf("pin job: %w", err)
+	}
+
+	err = c.delete(ctx, downloaded)
+	if err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+
+	err = c.missing(ctx, origin.URL+"/missing.mp3")
+	if err != nil {
+		return fmt.Errorf("missing download job: %w", err)
+	}
+
+	return nil
+}
+
+// randomBytes are n bytes, the same for each seed.
+func randomBytes(seed uint64, n int) []byte {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Uint32())
+	}
+
+	return b
+}
+
+// cycle runs the jobs, one at a time, and checks the results.
+type cycle struct {
+	kubo      *kubo.Client
+	server    *workapitest.Server
+	responses int
+}
+
+// run queues work, and waits for its result.
+func (c *cycle) run(ctx context.Context, work workapi.Work) (workapi.WorkResponse, error) {
+	c.server.Queue(work)
+	c.responses += 1
+
+	responses, err := c.server.WaitResponses(ctx, c.responses)
+	if err != nil {
+		return workapi.WorkResponse{}, fmt.Errorf("waiting for the result failed: %w", err)
+	}
+
+	return responses[c.responses-1], nil
+}
+
+// download checks the result of a download job, which is the file and the
+// directory wrapping it, as <file>/<dir>. It returns the directory.
+func (c *cycle) download(ctx context.Context, url string, episode []byte) (string, error) {
+	r, err := c.run(ctx, workapi.Work{
+		Show:     "integration",
+		Episode:  "download",
+		Download: url,
+		Filename: "episode.mp3",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if r.Error != nil {
+		return "", fmt.Errorf("job failed: %s: %s", r.ErrorReason, r.ErrorMessage)
+	}
+	if r.Downloaded == nil || r.Length == nil {
+		return "", errors.New("downloaded or length missing")
+	}
+	if *r.Length != len(episode) {
+		return "", fmt.Errorf("length is %d, want %d", *r.Length, len(episode))
+	}
+
+	file, dir, err := c.checkWrapped(ctx, *r.Downloaded, "episode.mp3", len(episode))
+	if err != nil {
+		return "", err
+	}
+
+	stat, err := c.kubo.FilesStat(ctx, file)
+	if err != nil {
+		return "", fmt.Errorf("stat of the file failed: %w", err)
+	}
+	if stat.Size != len(episode) {
+		return "", fmt.Errorf("size of the file in kubo is %d, want %d", stat.Size, len(episode))
+	}
+
+	slog.Info("download job passed", "file", file, "dir", dir)
+
+	return dir, nil
+}
+
+// pin checks a pin job, of content which is on the node, but not pinned.
+func (c *cycle) pin(ctx context.Context) error {
+	content := randomBytes(2, 1<<20)
+
+	added, err := c.kubo.AddWrapped(ctx, bytes.NewReader(content), "pinned.mp3", kubo.AddOptions{})
+	if err != nil {
+		return fmt.Errorf("adding content failed: %w", err)
+	}
+
+	err = c.kubo.PinRm(ctx, added.Dir.Hash)
+	if err != nil {
+		return fmt.Errorf("unpinning content failed: %w", err)
+	}
+
+	r, err := c.run(ctx, workapi.Work{
+		Show:    "integration",
+		Episode: "pin",
+		Pin:     added.Dir.Hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.Error != nil {
+		return fmt.Errorf("job failed: %s: %s", r.ErrorReason, r.ErrorMessage)
+	}
+	if r.Pinned == nil || r.Length == nil {
+		return errors.New("pinned or length missing")
+	}
+	if *r.Pinned != added.File.Hash+"/"+added.Dir.Hash {
+		return fmt.Errorf("pinned is %s, want %s/%s", *r.Pinned, added.File.Hash, added.Dir.Hash)
+	}
+	if *r.Length != len(content) {
+		return fmt.Errorf("length is %d, want %d", *r.Length, len(content))
+	}
+
+	pinned, err := c.kubo.IsPinned(ctx, added.Dir.Hash)
+	if err != nil {
+		return fmt.Errorf("checking pin failed: %w", err)
+	}
+	if !pinned {
+		return errors.New("directory isn't pinned")
+	}
+
+	slog.Info("pin job passed", "dir", added.Dir.Hash)
+
+	return nil
+}
+
+// delete checks a delete job, of the downloaded directory.
+func (c *cycle) delete(ctx context.Context, dir string) error {
+	r, err := c.run(ctx, workapi.Work{
+		Show:    "integration",
+		Episode: "download",
+		Delete:  dir,
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.Error != nil {
+		return fmt.Errorf("job failed: %s: %s", r.ErrorReason, r.ErrorMessage)
+	}
+	if r.Deleted == nil || *r.Deleted != dir {
+		return fmt.Errorf("deleted is %v, want %s", r.Deleted, dir)
+	}
+
+	pinned, err := c.kubo.IsPinned(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("checking pin failed: %w", err)
+	}
+	if pinned {
+		return errors.New("directory is still pinned")
+	}
+
+	slog.Info("delete job passed", "dir", dir)
+
+	return nil
+}
+
+// missing checks the error reported for a download which isn't found.
+func (c *cycle) missing(ctx context.Context, url string) error {
+	r, err := c.run(ctx, workapi.Work{
+		Show:     "integration",
+		Episode:  "missing",
+		Download: url,
+		Filename: "missing.mp3",
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.Error == nil {
+		return errors.New("job didn't fail")
+	}
+	if r.ErrorReason != "download_404" || !r.ErrorPermanent {
+		return fmt.Errorf("error is %s, permanent %t, want a permanent download_404", r.ErrorReason, r.ErrorPermanent)
+	}
+
+	slog.Info("missing download job passed")
+
+	return nil
+}
+
+// checkWrapped checks hashes is <file>/<dir>, and that dir is a directory
+// with only file, named filename, of size bytes.
+func (c *cycle) checkWrapped(ctx context.Context, hashes string, filename string, size int) (string, string, error) {
+	file, dir, ok := strings.Cut(hashes, "/")
+	if !ok || file == "" || dir == "" {
+		return "", "", fmt.Errorf("hashes %q aren't <file>/<dir>", hashes)
+	}
+
+	ls, err := c.kubo.Ls(ctx, dir)
+	if err != nil {
+		return "", "", fmt.Errorf("ls of the directory failed: %w", err)
+	}
+
+	if len(ls.Objects) != 1 || len(ls.Objects[0].Links) != 1 {
+		return "", "", fmt.Errorf("directory %s doesn't have exactly one link", dir)
+	}
+
+	link := ls.Objects[0].Links[0]
+
+	if link.Hash != file {
+		return "", "", fmt.Errorf("directory links to %s, want %s", link.Hash, file)
+	}
+	if link.Name != filename {
+		return "", "", fmt.Errorf("file in the directory is named %q, want %q", link.Name, filename)
+	}
+	if link.Size != size {
+		return "", "", fmt.Errorf("file in the directory is %d bytes, want %d", link.Size, size)
+	}
+
+	return file, dir, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/alecthomas/units"
+	"github.com/angaz/ipfspodcasting/pkg/notify"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// fileConfig is the config file, set with --config.
+//
+//	{
+//	  "flags": {
+//	    "email": "email@example.com",
+//	    "update-frequency": "10m"
+//	  },
+//	  "bandwidth_profiles": [
+//	    {"window": "07:00-23:00", "download_rate": "2MB"}
+//	  ],
+//	  "webhooks": [
+//	    {"url": "https://example.com/hook", "secret": "hunter2"}
+//	  ],
+//	  "chats": [
+//	    {"type": "telegram", "bot_token": "123:abc", "chat_id": "42"}
+//	  ],
+//	  "feeds": [
+//	    "https://example.com/podcast.rss"
+//	  ],
+//	  "tags": {
+//	    "region": "eu",
+//	    "storage": "large"
+//	  }
+//	}
+type fileConfig struct {
+	// Flags sets the flags by name. Flags given on the command line take
+	// precedence.
+	Flags map[string]any `json:"flags"`
+
+	// BandwidthProfiles limit the speed of downloads during the day.
+	BandwidthProfiles []bandwidthProfileConfig `json:"bandwidth_profiles"`
+
+	// Webhooks are sent events, like failed jobs or low disk space.
+	Webhooks []webhookConfig `json:"webhooks"`
+
+	// Chats are sent the same events as the webhooks, as chat messages.
+	Chats []chatConfig `json:"chats"`
+
+	// Email is sent failing events, when every work cycle failed for
+	// --failure-alert-after.
+	Email *emailConfig `json:"email"`
+
+	// Feeds are RSS feeds which are hosted without the server assigning
+	// them, by downloading every episode.
+	Feeds []string `json:"feeds"`
+
+	// Tags are sent with every work request. The --tags flag overrides
+	// tags with the same key.
+	Tags map[string]string `json:"tags"`
+}
+
+type webhookConfig struct {
+	URL string `json:"url"`
+	// Secret signs the body with HMAC-SHA256. Empty sends them unsigned.
+	Secret string `json:"secret"`
+}
+
+type emailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// Events are the types of events which are emailed. Defaults to only
+	// failing.
+	Events []notify.EventType `json:"events"`
+}
+
+func (c *emailConfig) sender() (notify.Sender, error) {
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return nil, fmt.Errorf("email needs a host, from, and to")
+	}
+
+	events := c.Events
+	if len(events) == 0 {
+		events = []notify.EventType{notify.EventFailing}
+	}
+
+	email := notify.NewEmail(notify.EmailConfig{
+		Host:     c.Host,
+		Port:     c.Port,
+		Username: c.Username,
+		Password: c.Password,
+		From:     c.From,
+		To:       c.To,
+	})
+
+	return notify.Only(email, events...), nil
+}
+
+type bandwidthProfileConfig struct {
+	// Window like 07:00-23:00.
+	Window string `json:"window"`
+	// DownloadRate in bytes per second, like 2MB. Empty or 0 is unlimited.
+	DownloadRate string `json:"download_rate"`
+}
+
+func loadConfig(path string) (*fileConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening config failed: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	// Keeps large numbers, like sizes in bytes, exact.
+	decoder.UseNumber()
+
+	var config fileConfig
+
+	err = decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("decoding config failed: %w", err)
+	}
+
+	return &config, nil
+}
+
+// applyFlags sets the flags from the config, which weren't set on the
+// command line.
+func (c *fileConfig) applyFlags(flags *flag.FlagSet) error {
+	set := map[string]bool{}
+
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for name, value := range c.Flags {
+		if name == "config" {
+			return fmt.Errorf("config can't be set in the config file")
+		}
+
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag: %s", name)
+		}
+
+		if set[name] {
+			continue
+		}
+
+		var str string
+
+		switch value := value.(type) {
+		case string:
+			str = value
+		case json.Number:
+			str = value.String()
+		case bool:
+			str = strconv.FormatBool(value)
+		default:
+			return fmt.Errorf("%s must be a string, number, or boolean", name)
+		}
+
+		err := flags.Set(name, str)
+		if err != nil {
+			return fmt.Errorf("setting %s failed: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *fileConfig) bandwidthProfiles() ([]updater.BandwidthProfile, error) {
+	profiles := make([]updater.BandwidthProfile, 0, len(c.BandwidthProfiles))
+
+	for _, profile := range c.BandwidthProfiles {
+		window, err := updater.ParseWindow(profile.Window)
+		if err != nil {
+			return nil, err
+		}
+
+		downloadRate := 0
+
+		if profile.DownloadRate != "" && profile.DownloadRate != "0" {
+			rate, err := units.ParseStrictBytes(profile.DownloadRate)
+			if err != nil {
+				return nil, fmt.Errorf("parsing download_rate failed: %w", err)
+			}
+
+			downloadRate = int(rate)
+		}
+
+		profiles = append(profiles, updater.BandwidthProfile{
+			Window:       window,
+			DownloadRate: downloadRate,
+		})
+	}
+
+	return profiles, nil
+}
+
+// chatConfig is a chat to send notifications to. The fields depend on the
+// type, which is discord, slack, telegram, or matrix.
+type chatConfig struct {
+	Type string `json:"type"`
+
+	// WebhookURL of discord and slack.
+	WebhookURL string `json:"webhook_url"`
+
+	// BotToken and ChatID of telegram.
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+
+	// Homeserver, RoomID, and AccessToken of matrix.
+	Homeserver  string `json:"homeserver"`
+	RoomID      string `json:"room_id"`
+	AccessToken string `json:"access_token"`
+}
+
+func (c chatConfig) sender(httpClient *http.Client) (notify.Sender, error) {
+	switch c.Type {
+	case "discord":
+		return notify.NewDiscord(httpClient, c.WebhookURL), nil
+	case "slack":
+		return notify.NewSlack(httpClient, c.WebhookURL), nil
+	case "telegram":
+		return notify.NewTelegram(httpClient, c.BotToken, c.ChatID), nil
+	case "matrix":
+		return notify.NewMatrix(httpClient, c.Homeserver, c.RoomID, c.AccessToken), nil
+	default:
+		return nil, fmt.Errorf("unknown chat type: %q", c.Type)
+	}
+}
+
+// senders creates the notification senders of the config.
+func (c *fileConfig) senders(httpClient *http.Client) ([]notify.Sender, error) {
+	var senders []notify.Sender
+
+	for _, webhook := range c.Webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook url is required")
+		}
+
+		senders = append(senders, notify.NewWebhook(httpClient, webhook.URL, webhook.Secret))
+	}
+
+	for _, chat := range c.Chats {
+		sender, err := chat.sender(httpClient)
+		if err != nil {
+			return nil, err
+		}
+
+		senders = append(senders, sender)
+	}
+
+	if c.Email != nil {
+		sender, err := c.Email.sender()
+		if err != nil {
+			return nil, err
+		}
+
+		senders = append(senders, sender)
+	}
+
+	return senders, nil
+}
+
+// tags merges the tags of the config file with flagTags, in the format of
+// workapi.FormatTags, which take precedence.
+func (c *fileConfig) tags(flagTags string) (map[string]string, error) {
+	tags, err := workapi.ParseTags(flagTags)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range c.Tags {
+		if _, ok := tags[key]; !ok {
+			tags[key] = value
+		}
+	}
+
+	err = workapi.CheckTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"bytes": func(n any) string {
+		switch n := n.(type) {
+		case int:
+			return formatBytes(int64(n))
+		case int64:
+			return formatBytes(n)
+		}
+
+		return fmt.Sprint(n)
+	},
+	"join": strings.Join,
+	"duration": func(d time.Duration) string {
+		return d.Round(100 * time.Millisecond).String()
+	},
+}).Parse(dashboardHTML))
+
+type dashboardNode struct {
+	Name            string
+	UpdateFrequency time.Duration
+	KuboError       string
+	Disk            *updater.DiskSample
+	DiskPoints      string
+	Jobs            []updater.JobRecord
+	Episodes        []catalog.Episode
+	CatalogSize     int
+}
+
+type dashboardData struct {
+	Version string
+	Nodes   []dashboardNode
+}
+
+// formatBytes formats n bytes with a binary unit, like 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp += 1
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// diskPoints are the points of a 600x80 SVG polyline of the disk usage, as
+// a fraction of the disk's size.
+func diskPoints(samples []updater.DiskSample) string {
+	if len(samples) < 2 {
+		return ""
+	}
+
+	const width, height = 600, 80
+
+	points := make([]string, 0, len(samples))
+
+	for i, sample := range samples {
+		if sample.Total == 0 {
+			continue
+		}
+
+		x := float64(i) * width / float64(len(samples)-1)
+		y := height - float64(sample.Used)/float64(sample.Total)*height
+
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	return strings.Join(points, " ")
+}
+
+func newDashboardNode(ctx context.Context, u *updater.Updater) dashboardNode {
+	node := dashboardNode{
+		Name:            u.Name(),
+		UpdateFrequency: u.UpdateFrequency(),
+		Jobs:            u.RecentJobs(),
+		Episodes:        u.Catalog().Episodes(),
+		CatalogSize:     u.Catalog().Size(),
+	}
+
+	slices.Reverse(node.Episodes)
+
+	err := u.CheckKubo(ctx)
+	if err != nil {
+		node.KuboError = err.Error()
+	}
+
+	disk := u.DiskUsage()
+	if len(disk) != 0 {
+		node.Disk = &disk[len(disk)-1]
+		node.DiskPoints = diskPoints(disk)
+	}
+
+	return node
+}
+
+// sameOrigin rejects requests from other sites, so a page the operator
+// visits can't trigger the buttons. Browsers which don't send
+// Sec-Fetch-Site are allowed.
+func sameOrigin(r *http.Request) bool {
+	site := r.Header.Get("Sec-Fetch-Site")
+
+	return site == "" || site == "same-origin" || site == "none"
+}
+
+// registerDashboard adds the dashboard, at /dashboard, to mux.
+func registerDashboard(mux *http.ServeMux, updaters []*updater.Updater) {
+	mux.HandleFunc("GET /dashboard", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		data := dashboardData{
+			Version: version,
+			Nodes:   make([]dashboardNode, 0, len(updaters)),
+		}
+
+		for _, u := range updaters {
+			data.Nodes = append(data.Nodes, newDashboardNode(ctx, u))
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		err := dashboardTemplate.Execute(w, data)
+		if err != nil {
+			slog.Warn("writing dashboard failed", "err", err)
+		}
+	})
+
+	trigger := func(action func(u *updater.Updater)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !sameOrigin(r) {
+				http.Error(w, "cross-site request", http.StatusForbidden)
+
+				return
+			}
+
+			u := findUpdater(updaters, r.URL.Query().Get("node"))
+			if u == nil {
+				http.Error(w, "unknown node", http.StatusNotFound)
+
+				return
+			}
+
+			action(u)
+
+			http.Redirect(w, r, "../dashboard", http.StatusSeeOther)
+		}
+	}
+
+	mux.HandleFunc("POST /dashboard/cycle", trigger((*updater.Updater).TriggerCycle))
+	mux.HandleFunc("POST /dashboard/reconcile", trigger((*updater.Updater).TriggerReconcile))
+}
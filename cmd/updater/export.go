@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+// runExport exports the episodes in the catalog as CAR files, one per
+// episode, named <cid>.car, with a catalog.json of the exported episodes,
+// which updater import reads. It returns the exit code.
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+
+	apiAddress := flags.String(
+		"api-address",
+		"/ip4/127.0.0.1/tcp/5001",
+		"address of the IPFS API",
+	)
+	catalogPath := flags.String(
+		"catalog",
+		filepath.Join(defaultStateDir(), "catalog.json"),
+		"Catalog of the episodes to export. With several nodes, it's <state-dir>/<node>/catalog.json",
+	)
+	output := flags.String(
+		"output",
+		"",
+		"Directory to write the CAR files to. Required",
+	)
+	incremental := flags.Bool(
+		"incremental",
+		false,
+		"Skip episodes which were exported to the output directory before",
+	)
+	flags.Parse(args)
+
+	if *output == "" {
+		slog.Error("output is required")
+
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := export(ctx, *apiAddress, *catalogPath, *output, *incremental)
+	if err != nil {
+		slog.Error("export failed", "err", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func export(ctx context.Context, apiAddress string, catalogPath string, output string, incremental bool) error {
+	client, err := newKuboClient(apiAddress)
+	if err != nil {
+		return err
+	}
+
+	source, err := catalog.Open(catalogPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(output, 0o755)
+	if err != nil {
+		return fmt.Errorf("creating output failed: %w", err)
+	}
+
+	exported, err := catalog.Open(filepath.Join(output, "catalog.json"))
+	if err != nil {
+		return err
+	}
+
+	episodes := source.Episodes()
+	count := 0
+	skipped := 0
+	totalBytes := int64(0)
+
+	for _, episode := range episodes {
+		carPath := filepath.Join(output, episode.Hash+".car")
+
+		if incremental {
+			_, err := os.Stat(carPath)
+			if err == nil {
+				skipped += 1
+
+				continue
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("stat %s failed: %w", carPath, err)
+			}
+		}
+
+		written, err := exportCAR(ctx, client, episode.Hash, carPath)
+		if err != nil {
+			return fmt.Errorf("exporting %s failed: %w", episode.Hash, err)
+		}
+
+		err = exported.Add(episode)
+		if err != nil {
+			return err
+		}
+
+		count += 1
+		totalBytes += written
+
+		slog.Info("exported episode", "cid", episode.Hash, "show", episode.Show, "episode", episode.Episode, "bytes", written)
+	}
+
+	slog.Info("export finished", "exported", count, "skipped", skipped, "bytes", totalBytes)
+
+	return nil
+}
+
+// exportCAR writes the DAG of hash to path, through a temporary file, so an
+// interrupted export doesn't leave a partial CAR behind, which an
+// incremental export would skip.
+func exportCAR(ctx context.Context, client *kubo.Client, hash string, path string) (int64, error) {
+	car, err := client.DagExport(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+	defer car.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".export-*.car")
+	if err != nil {
+		return 0, fmt.Errorf("creating temp file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, car)
+	if err != nil {
+		tmp.Close()
+
+		return 0, fmt.Errorf("writing car failed: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return 0, fmt.Errorf("writing car failed: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return 0, fmt.Errorf("renaming car failed: %w", err)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/alecthomas/units"
+)
+
+// byteSize is a flag for a number of bytes, which accepts units like 500MB
+// or 1TiB, or a plain number of bytes.
+type byteSize int
+
+func (b *byteSize) String() string {
+	return units.MetricBytes(*b).String()
+}
+
+func (b *byteSize) Set(s string) error {
+	plain, err := strconv.Atoi(s)
+	if err == nil {
+		*b = byteSize(plain)
+
+		return nil
+	}
+
+	size, err := units.ParseStrictBytes(s)
+	if err != nil {
+		return err
+	}
+
+	*b = byteSize(size)
+
+	return nil
+}
+
+func byteSizeFlag(name string, value int, usage string) *int {
+	b := byteSize(value)
+	flag.Var(&b, name, usage)
+
+	return (*int)(&b)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runHealthcheck checks the /healthz endpoint of a running updater, and
+// returns the exit code, 0 if it's healthy, 1 if not. It's meant for Docker's
+// HEALTHCHECK, so images don't need curl.
+func runHealthcheck(args []string) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+
+	address := flags.String(
+		"metrics-address",
+		"127.0.0.1:9196",
+		"metrics-address of the running updater. An address without a host, like :9196, is checked on localhost",
+	)
+	path := flags.String(
+		"path",
+		"/healthz",
+		"Path of the check, /healthz or /readyz",
+	)
+	basicAuth := flags.String(
+		"metrics-basic-auth",
+		"",
+		"Basic auth credentials of the metrics server, as user:password. Not needed by /healthz and /readyz, which are served without them",
+	)
+	useTLS := flags.Bool(
+		"tls",
+		false,
+		"Connect with TLS. The certificate isn't verified, because it's usually not issued for localhost",
+	)
+	timeout := flags.Duration(
+		"timeout",
+		10*time.Second,
+		"Timeout of the check",
+	)
+	flags.Parse(args)
+
+	err := healthcheck(*address, *path, *basicAuth, *useTLS, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func healthcheck(address string, path string, basicAuth string, useTLS bool, timeout time.Duration) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("parsing address failed: %w", err)
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	url := scheme + "://" + net.JoinHostPort(host, port) + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+
+	if basicAuth != "" {
+		user, password, _ := strings.Cut(basicAuth, ":")
+		req.SetBasicAuth(user, password)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", path, resp.StatusCode)
+	}
+
+	return nil
+}
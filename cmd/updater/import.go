@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+const importUsage = `Usage: updater import [flags] <file or directory>...
+
+Imports CAR files into the Kubo node, pins their roots, and adds them to the
+catalog. Directories are searched for *.car files, and the catalog.json
+written by updater export, which has the show and episode of each CAR.
+
+Flags:
+`
+
+// runImport imports the CAR files written by updater export, so a node can be
+// moved to new hardware without downloading all the episodes again. It
+// returns the exit code.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+
+	apiAddress := flags.String(
+		"api-address",
+		"/ip4/127.0.0.1/tcp/5001",
+		"address of the IPFS API",
+	)
+	catalogPath := flags.String(
+		"catalog",
+		filepath.Join(defaultStateDir(), "catalog.json"),
+		"Catalog to add the imported episodes to. With several nodes, it's <state-dir>/<node>/catalog.json",
+	)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := importCARs(ctx, *apiAddress, *catalogPath, flags.Args())
+	if err != nil {
+		slog.Error("import failed", "err", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func importCARs(ctx context.Context, apiAddress string, catalogPath string, paths []string) error {
+	client, err := newKuboClient(apiAddress)
+	if err != nil {
+		return err
+	}
+
+	local, err := catalog.Open(catalogPath)
+	if err != nil {
+		return err
+	}
+
+	exported, files, err := findCARs(paths)
+	if err != nil {
+		return err
+	}
+
+	pinned := 0
+	failed := 0
+
+	for _, file := range files {
+		roots, err := importCAR(ctx, client, file)
+		if err != nil {
+			return fmt.Errorf("importing %s failed: %w", file, err)
+		}
+
+		for _, root := range roots {
+			if root.PinErrorMsg != "" {
+				failed += 1
+
+				fmt.Printf("%s\tfailed\t%s\n", root.Cid, root.PinErrorMsg)
+
+				continue
+			}
+
+			episode, ok := exported[root.Cid]
+			if !ok {
+				episode = catalog.Episode{
+					Hash:  root.Cid,
+					Added: time.Now(),
+				}
+
+				size, err := client.FileSize(ctx, root.Cid)
+				if err != nil {
+					slog.Warn("getting size failed", "cid", root.Cid, "err", err)
+				}
+				episode.Size = size
+			}
+
+			err = local.Add(episode)
+			if err != nil {
+				return err
+			}
+
+			pinned += 1
+
+			fmt.Printf("%s\tpinned\t%s\n", root.Cid, file)
+		}
+	}
+
+	slog.Info("import finished", "files", len(files), "pinned", pinned, "failed", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d roots failed to pin", failed)
+	}
+
+	return nil
+}
+
+// findCARs returns the CAR files in paths, and the episodes of the
+// catalog.json files in the directories.
+func findCARs(paths []string) (map[string]catalog.Episode, []string, error) {
+	exported := map[string]catalog.Episode{}
+	files := []string{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("stat %s failed: %w", path, err)
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+
+			continue
+		}
+
+		cars, err := filepath.Glob(filepath.Join(path, "*.car"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing %s failed: %w", path, err)
+		}
+		files = append(files, cars...)
+
+		catalogPath := filepath.Join(path, "catalog.json")
+
+		_, err = os.Stat(catalogPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		dirCatalog, err := catalog.Open(catalogPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, episode := range dirCatalog.Episodes() {
+			exported[episode.Hash] = episode
+		}
+	}
+
+	return exported, files, nil
+}
+
+func importCAR(ctx context.Context, client *kubo.Client, file string) ([]kubo.DagImportRoot, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening car failed: %w", err)
+	}
+	defer f.Close()
+
+	return client.DagImport(ctx, f)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+const keyUsage = `Usage: updater key <command> [flags] [args]
+
+Manages the IPNS keys of the Kubo node, so the manifest can be published
+under a dedicated key instead of the node's identity.
+
+Commands:
+  list                  List the keys
+  gen <name>            Create a key
+  export <name>         Export a key from the Kubo repo to a file
+  import <name> <file>  Import a key from a file
+
+Flags go before the arguments. See updater key <command> -h.
+`
+
+// runKey runs the key subcommands, and returns the exit code.
+func runKey(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, keyUsage)
+
+		return 2
+	}
+
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("key "+command, flag.ExitOnError)
+
+	apiAddress := flags.String(
+		"api-address",
+		"/ip4/127.0.0.1/tcp/5001",
+		"address of the IPFS API",
+	)
+	timeout := flags.Duration(
+		"timeout",
+		time.Minute,
+		"Timeout of the request to Kubo",
+	)
+
+	var err error
+
+	switch command {
+	case "list":
+		flags.Parse(args)
+
+		err = keyList(*apiAddress, *timeout)
+	case "gen":
+		keyType := flags.String(
+			"type",
+			"ed25519",
+			"Type of the key, ed25519 or rsa",
+		)
+		flags.Parse(args)
+
+		err = keyGen(*apiAddress, *timeout, flags.Arg(0), *keyType)
+	case "export":
+		repo := flags.String(
+			"repo",
+			defaultIPFSPath(),
+			"Path of the Kubo repo. Kubo doesn't allow exporting keys through the API, so they are read from the repo",
+		)
+		output := flags.String(
+			"output",
+			"",
+			"File to write the key to. Defaults to <name>.key",
+		)
+		flags.Parse(args)
+
+		err = keyExport(*repo, flags.Arg(0), *output)
+	case "import":
+		format := flags.String(
+			"format",
+			"libp2p-protobuf-cleartext",
+			"Format of the key, libp2p-protobuf-cleartext or pem-pkcs8-cleartext",
+		)
+		flags.Parse(args)
+
+		err = keyImport(*apiAddress, *timeout, flags.Arg(0), flags.Arg(1), *format)
+	default:
+		fmt.Fprint(os.Stderr, keyUsage)
+
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "key "+command+" failed:", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func newKuboClient(address string) (*kubo.Client, error) {
+	addr, err := kubo.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("parsing api-address failed: %w", err)
+	}
+
+	api, err := kubo.NewAPI(addr, &http.Client{
+		Transport: kubo.NewTransport(addr),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating api client failed: %w", err)
+	}
+
+	return kubo.New(api), nil
+}
+
+func keyList(address string, timeout time.Duration) error {
+	client, err := newKuboClient(address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	keys, err := client.KeyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		fmt.Printf("%s\t%s\n", key.Name, key.ID)
+	}
+
+	return nil
+}
+
+func keyGen(address string, timeout time.Duration, name string, keyType string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	client, err := newKuboClient(address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	key, err := client.KeyGen(ctx, name, keyType)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\t%s\n", key.Name, key.ID)
+
+	return nil
+}
+
+// defaultIPFSPath is the Kubo repo, from $IPFS_PATH, or ~/.ipfs.
+func defaultIPFSPath() string {
+	ipfsPath := os.Getenv("IPFS_PATH")
+	if ipfsPath != "" {
+		return ipfsPath
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".ipfs"
+	}
+
+	return filepath.Join(home, ".ipfs")
+}
+
+// readRepoKey reads the private key called name from the Kubo repo, in the
+// libp2p-protobuf-cleartext format. The "self" key is the identity in the
+// repo's config, the others are in the keystore, in files named after the
+// lowercase, unpadded base32 of the name.
+func readRepoKey(repo string, name string) ([]byte, error) {
+	if name == "self" {
+		f, err := os.Open(filepath.Join(repo, "config"))
+		if err != nil {
+			return nil, fmt.Errorf("opening config failed: %w", err)
+		}
+		defer f.Close()
+
+		var config struct {
+			Identity struct {
+				PrivKey string
+			}
+		}
+
+		err = json.NewDecoder(f).Decode(&config)
+		if err != nil {
+			return nil, fmt.Errorf("decoding config failed: %w", err)
+		}
+
+		return base64.StdEncoding.DecodeString(config.Identity.PrivKey)
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(name))
+
+	key, err := os.ReadFile(filepath.Join(repo, "keystore", "key_"+strings.ToLower(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("reading key failed: %w", err)
+	}
+
+	return key, nil
+}
+
+func keyExport(repo string, name string, output string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if output == "" {
+		output = name + ".key"
+	}
+
+	key, err := readRepoKey(repo, name)
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL, so an existing key is never overwritten.
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating output failed: %w", err)
+	}
+
+	_, err = f.Write(key)
+	if err != nil {
+		f.Close()
+
+		return fmt.Errorf("writing key failed: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("writing key failed: %w", err)
+	}
+
+	fmt.Println(output)
+
+	return nil
+}
+
+func keyImport(address string, timeout time.Duration, name string, file string, format string) error {
+	if name == "" || file == "" {
+		return fmt.Errorf("name and file are required")
+	}
+
+	client, err := newKuboClient(address)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("opening key failed: %w", err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	key, err := client.KeyImport(ctx, name, f, format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\t%s\n", key.Name, key.ID)
+
+	return nil
+}
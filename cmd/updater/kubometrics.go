@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// kuboMetricsPrefix is prepended to the names of Kubo's metrics, so they
+// don't collide with the updater's own, like go_goroutines.
+const kuboMetricsPrefix = "kubo_"
+
+// kuboMetricsTarget is the metrics endpoint of a Kubo node.
+type kuboMetricsTarget struct {
+	node   string
+	url    string
+	client *http.Client
+}
+
+// kuboMetricsSample is the last metrics fetched from a node.
+type kuboMetricsSample struct {
+	families map[string]*dto.MetricFamily
+	time     time.Time
+}
+
+// kuboMetricsProxy re-exposes Kubo's own metrics, from
+// /debug/metrics/prometheus, on the updater's metrics endpoint, so only one
+// target has to be scraped. They're fetched in the background, like Kubo's
+// stats, and each metric gets the node label.
+type kuboMetricsProxy struct {
+	targets []kuboMetricsTarget
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	samples map[string]kuboMetricsSample
+}
+
+func newKuboMetricsProxy(targets []kuboMetricsTarget, ttl time.Duration) *kuboMetricsProxy {
+	return &kuboMetricsProxy{
+		targets: targets,
+		ttl:     ttl,
+		samples: map[string]kuboMetricsSample{},
+	}
+}
+
+// run fetches the metrics of the nodes every interval, until ctx is done.
+func (p *kuboMetricsProxy) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, target := range p.targets {
+			fetchCtx, cancel := context.WithTimeout(ctx, kuboStatsTimeout)
+			err := p.fetch(fetchCtx, target)
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				slog.Warn("fetching kubo metrics failed", "node", target.node, "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *kuboMetricsProxy) fetch(ctx context.Context, target kuboMetricsTarget) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+
+	resp, err := target.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return fmt.Errorf("parsing metrics failed: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.samples[target.node] = kuboMetricsSample{
+		families: families,
+		time:     time.Now(),
+	}
+
+	return nil
+}
+
+// Gather returns the last metrics of the nodes, renamed with
+// kuboMetricsPrefix. The metrics of a node which weren't fetched within the
+// ttl are left out, rather than served stale.
+func (p *kuboMetricsProxy) Gather() ([]*dto.MetricFamily, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	merged := map[string]*dto.MetricFamily{}
+
+	for _, target := range p.targets {
+		sample, ok := p.samples[target.node]
+		if !ok || time.Since(sample.time) > p.ttl {
+			continue
+		}
+
+		for name, family := range sample.families {
+			name = kuboMetricsPrefix + name
+
+			out, ok := merged[name]
+			if !ok {
+				out = &dto.MetricFamily{
+					Name: proto.String(name),
+					Help: family.Help,
+					Type: family.Type,
+					Unit: family.Unit,
+				}
+				merged[name] = out
+			}
+
+			for _, metric := range family.Metric {
+				metric = proto.Clone(metric).(*dto.Metric)
+				metric.Label = append(metric.Label, &dto.LabelPair{
+					Name:  proto.String("node"),
+					Value: proto.String(target.node),
+				})
+				sort.Slice(metric.Label, func(i, j int) bool {
+					return metric.Label[i].GetName() < metric.Label[j].GetName()
+				})
+
+				out.Metric = append(out.Metric, metric)
+			}
+		}
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(merged))
+	for _, family := range merged {
+		families = append(families, family)
+	}
+
+	return families, nil
+}
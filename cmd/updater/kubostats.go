@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// kuboStatsTimeout is how long a single stat can take. repo/stat walks the
+// whole repo, which takes a while when it's large.
+const kuboStatsTimeout = 2 * time.Minute
+
+// kuboStat is a group of metrics filled from one request to Kubo.
+type kuboStat struct {
+	name    string
+	collect func(ctx context.Context, u *updater.Updater) error
+	// vecs are the metrics of the group, which the node's series are
+	// removed from when they are too old to serve.
+	vecs []*prometheus.GaugeVec
+}
+
+var kuboStats = []kuboStat{
+	{
+		name: "peers",
+		collect: func(ctx context.Context, u *updater.Updater) error {
+			peers, err := u.Kubo().Peers(ctx)
+			if err != nil {
+				return err
+			}
+
+			metrics.IPFSPeers.WithLabelValues(u.Name()).Set(float64(peers))
+
+			return nil
+		},
+		vecs: []*prometheus.GaugeVec{metrics.IPFSPeers},
+	},
+	{
+		name: "repo stats",
+		collect: func(ctx context.Context, u *updater.Updater) error {
+			stats, err := u.Kubo().RepoStat(ctx)
+			if err != nil {
+				return err
+			}
+
+			node := u.Name()
+
+			metrics.IPFSRepoDiskUsage.WithLabelValues(node).Set(float64(stats.RepoSize))
+			metrics.IPFSRepoObjects.WithLabelValues(node).Set(float64(stats.NumObjects))
+			metrics.IPFSRepoStorageMax.WithLabelValues(node).Set(float64(stats.StorageMax))
+
+			return nil
+		},
+		vecs: []*prometheus.GaugeVec{
+			metrics.IPFSRepoDiskUsage,
+			metrics.IPFSRepoObjects,
+			metrics.IPFSRepoStorageMax,
+		},
+	},
+	{
+		name: "bitswap stats",
+		collect: func(ctx context.Context, u *updater.Updater) error {
+			bitswap, err := u.Kubo().BitswapStat(ctx)
+			if err != nil {
+				return err
+			}
+
+			node := u.Name()
+
+			metrics.BitswapBlocks.WithLabelValues(node, "received").Set(float64(bitswap.BlocksReceived))
+			metrics.BitswapBlocks.WithLabelValues(node, "sent").Set(float64(bitswap.BlocksSent))
+			metrics.BitswapBytes.WithLabelValues(node, "received").Set(float64(bitswap.DataReceived))
+			metrics.BitswapBytes.WithLabelValues(node, "sent").Set(float64(bitswap.DataSent))
+			metrics.BitswapDuplicateBlocks.WithLabelValues(node).Set(float64(bitswap.DupBlksReceived))
+			metrics.BitswapWantlist.WithLabelValues(node).Set(float64(len(bitswap.Wantlist)))
+
+			return nil
+		},
+		vecs: []*prometheus.GaugeVec{
+			metrics.BitswapBlocks,
+			metrics.BitswapBytes,
+			metrics.BitswapDuplicateBlocks,
+			metrics.BitswapWantlist,
+		},
+	},
+	{
+		name: "bandwidth stats",
+		collect: func(ctx context.Context, u *updater.Updater) error {
+			bandwidth, err := u.Kubo().BandwidthStat(ctx)
+			if err != nil {
+				return err
+			}
+
+			node := u.Name()
+
+			metrics.BandwidthBytes.WithLabelValues(node, "in").Set(float64(bandwidth.TotalIn))
+			metrics.BandwidthBytes.WithLabelValues(node, "out").Set(float64(bandwidth.TotalOut))
+			metrics.BandwidthRate.WithLabelValues(node, "in").Set(bandwidth.RateIn)
+			metrics.BandwidthRate.WithLabelValues(node, "out").Set(bandwidth.RateOut)
+
+			return nil
+		},
+		vecs: []*prometheus.GaugeVec{
+			metrics.BandwidthBytes,
+			metrics.BandwidthRate,
+		},
+	},
+}
+
+// collectKuboStats fills the metrics of Kubo's stats every interval, until
+// ctx is done. The scrapes serve the last values, rather than waiting for
+// Kubo. When a stat fails for longer than ttl, the node's series are removed,
+// so stale values aren't served as if they were current.
+func collectKuboStats(ctx context.Context, u *updater.Updater, interval time.Duration, ttl time.Duration) {
+	node := u.Name()
+	collected := make(map[string]time.Time, len(kuboStats))
+	start := time.Now()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, stat := range kuboStats {
+			statCtx, cancel := context.WithTimeout(ctx, kuboStatsTimeout)
+			err := stat.collect(statCtx, u)
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil {
+				collected[stat.name] = time.Now()
+
+				continue
+			}
+
+			slog.Warn("metrics could not get "+stat.name, "node", node, "err", err)
+
+			last, ok := collected[stat.name]
+			if !ok {
+				last = start
+			}
+
+			if time.Since(last) > ttl {
+				for _, vec := range stat.vecs {
+					vec.DeletePartialMatch(prometheus.Labels{"node": node})
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logFileConfig configures writing the logs to a file, which is rotated when
+// it gets too large.
+type logFileConfig struct {
+	path string
+	// maxSize in bytes before the file is rotated.
+	maxSize int
+	// maxAge in days of rotated files. Zero keeps them regardless of age.
+	maxAge int
+	// maxBackups is the number of rotated files kept. Zero keeps all.
+	maxBackups int
+}
+
+// newLogFile creates a writer to the log file, which rotates it.
+func newLogFile(config logFileConfig) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename:   config.path,
+		MaxSize:    max(config.maxSize/1_000_000, 1),
+		MaxAge:     config.maxAge,
+		MaxBackups: config.maxBackups,
+		LocalTime:  true,
+		Compress:   true,
+	}
+}
+
+// newLogHandler creates the slog handler for the log format, text or json,
+// which logs records at level and above.
+func newLogHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
+
+// loggingTransport logs the status and timing of requests at debug level.
+type loggingTransport struct {
+	name string
+	next http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !slog.Default().Enabled(req.Context(), slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	// Responses are streamed, so this is the time until the headers.
+	duration := time.Since(start)
+
+	if err != nil {
+		slog.Debug(t.name+" request failed", "method", req.Method, "path", req.URL.Path, "duration", duration, "err", err)
+
+		return nil, err
+	}
+
+	slog.Debug(t.name+" request", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "duration", duration)
+
+	return resp, nil
+}
@@ -1,847 +1,1264 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
+	"errors"
 	"flag"
-	"fmt"
 	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
-	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/cluster"
+	"github.com/angaz/ipfspodcasting/pkg/filecoin"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
 	"github.com/angaz/ipfspodcasting/pkg/metrics"
-	"github.com/ipfs/boxo/path"
-	"github.com/ipfs/go-cid"
-	"github.com/ipfs/kubo/client/rpc"
+	"github.com/angaz/ipfspodcasting/pkg/notify"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/angaz/ipfspodcasting/pkg/web3storage"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
 	"github.com/multiformats/go-multiaddr"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/time/rate"
 )
 
 func main() {
-	apiAddressStr := flag.String("api-address", "", "address of the IPFS API")
-	email := flag.String("email", "", "Email address for your IPFS Podcasting account")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Args[2:]))
+		case "version":
+			os.Exit(runVersion())
+		case "self-update":
+			os.Exit(runSelfUpdate(os.Args[2:]))
+		case "key":
+			os.Exit(runKey(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "setup-kubo":
+			os.Exit(runSetupKubo(os.Args[2:]))
+		}
+	}
+
+	apiAddressStr := flag.String("api-address", "", "address of the IPFS API, like /ip4/127.0.0.1/tcp/5001, 127.0.0.1:5001, http://localhost:5001, or /unix/run/ipfs/api.sock. Comma separated to run a work loop for each of several Kubo nodes")
+	apiSocket := flag.String(
+		"api-socket",
+		"",
+		"Path of the Unix socket of the IPFS API, like /run/ipfs/api.sock, instead of api-address. Comma separated for several Kubo nodes",
+	)
+	email := flag.String("email", "", "Email address for your IPFS Podcasting account. Comma separated to use a different email for each api-address, and semicolon separated for a node which works for several accounts, like a@example.com;b@example.com")
+	serverURL := flag.String(
+		"server-url",
+		workapi.DefaultBaseURL,
+		"URL of the work server, for a self-hosted coordinator",
+	)
+	workProtocol := flag.String(
+		"work-protocol",
+		string(workapi.ProtocolAuto),
+		"Encoding of the requests to the work server: auto switches to JSON if the server supports it, json only sends JSON, and fails if the server rejects it, and form only sends the legacy form encoding",
+	)
+	jobs := flag.String(
+		"jobs",
+		"download,pin,delete",
+		"Comma separated job types the node runs, like pin,delete to never download episodes over a metered connection. The others are declined, and not advertised to the server",
+	)
+	tagsFlag := flag.String(
+		"tags",
+		"",
+		"Tags sent with every work request, so the server can assign work by location or size, as comma separated key=value pairs, like region=eu,storage=large",
+	)
+	push := flag.Bool(
+		"push",
+		false,
+		"Keep a connection to the event stream of the work server, and check for work as soon as it announces some. While it's connected, checks only happen every max-update-frequency, and when it drops, the updater falls back to polling",
+	)
+	peering := flag.Bool(
+		"peering",
+		false,
+		"Add the backbone peers listed by the work server to Kubo's peering, which keeps connections to them, so content is found between the nodes without going through the DHT",
+	)
+	peeringInterval := flag.Duration(
+		"peering-interval",
+		time.Hour,
+		"How often the backbone peers are fetched from the work server",
+	)
+	serverRateLimit := flag.Int(
+		"server-rate-limit",
+		0,
+		"Maximum requests per minute to the work server, shared by the work loops of all the nodes. 0 disables the limit",
+	)
+	recordWork := flag.String(
+		"record-work",
+		"",
+		"Append the requests to the work server, and their responses, to this file, to reproduce protocol bugs with updater replay. The requests contain the email. Empty disables recording",
+	)
+	clientCert := flag.String(
+		"client-cert",
+		"",
+		"Certificate file for mutual TLS with the work server. Reloaded when it changes",
+	)
+	clientKey := flag.String(
+		"client-key",
+		"",
+		"Key file of --client-cert",
+	)
+	proxy := flag.String(
+		"proxy",
+		"",
+		"URL of a proxy for the downloads and the work server, like http://proxy:3128 or socks5://proxy:1080. Defaults to $HTTP_PROXY and $HTTPS_PROXY",
+	)
+	socks5 := flag.String(
+		"socks5",
+		"",
+		"Address of a SOCKS5 proxy for the downloads and the work server, as host:port. Same as --proxy=socks5://host:port",
+	)
+	downloadProxy := flag.String(
+		"download-proxy",
+		"",
+		"URL of a proxy for the episode downloads only. Overrides --proxy",
+	)
+	serverProxy := flag.String(
+		"server-proxy",
+		"",
+		"URL of a proxy for the work server only. Overrides --proxy",
+	)
+	nodeSecret := flag.String(
+		"node-secret",
+		"",
+		"Secret shared with the work server, which signs the requests. Empty sends them unsigned",
+	)
 	updateFrequency := flag.Duration(
 		"update-frequency",
 		10*time.Minute,
 		"How often to check for new work",
 	)
+	minUpdateFrequency := flag.Duration(
+		"min-update-frequency",
+		0,
+		"Shortest time between checks for new work, used while the server keeps returning work. Defaults to update-frequency, which keeps the time between checks fixed",
+	)
+	maxUpdateFrequency := flag.Duration(
+		"max-update-frequency",
+		0,
+		"Longest time between checks for new work, used after long idle streaks. Defaults to update-frequency, which keeps the time between checks fixed",
+	)
+	idleCycles := flag.Int(
+		"idle-cycles",
+		3,
+		"Checks in a row without work before the time between checks starts growing towards max-update-frequency",
+	)
+	pollAfterWork := flag.Bool(
+		"poll-after-work",
+		false,
+		"Check for new work right after a job completed, instead of waiting for the update frequency, so a backlog drains faster",
+	)
+	maxJobsPerHour := flag.Int(
+		"max-jobs-per-hour",
+		60,
+		"Jobs per hour after which poll-after-work stops, and the checks wait for the update frequency again. 0 doesn't cap them",
+	)
+	updateJitter := flag.Duration(
+		"update-jitter",
+		0,
+		"Maximum random time added to each wait between checks for new work, to spread the load on the server. 0 disables it",
+	)
+	followRetryAfter := flag.Bool(
+		"follow-retry-after",
+		true,
+		"Wait for the time in the server's Retry-After header, when it sends one, instead of the update frequency",
+	)
+	schedule := flag.String(
+		"schedule",
+		"",
+		"Cron spec of the times when checks for new work are allowed, e.g. \"*/5 8-23 * * *\". After waiting for the update frequency, the next check is at the next scheduled time. Empty allows checks at any time",
+	)
 	httpTimeout := flag.Duration(
 		"http-timeout",
 		10*time.Minute,
-		"Timeout for downloading epodes and communicating with ipfspodcasting.net",
+		"Timeout for fetching feeds and communicating with ipfspodcasting.net",
+	)
+	downloadTimeout := flag.Duration(
+		"download-timeout",
+		2*time.Minute,
+		"Time the origin of an episode may take to respond, or to send more of the file. Resets while bytes are flowing, so it doesn't limit the length of the whole download",
+	)
+	addTimeout := flag.Duration(
+		"add-timeout",
+		10*time.Minute,
+		"Time Kubo may take to read more of a download, or to respond once it has read all of it. Resets while bytes are flowing",
+	)
+	jobTimeout := flag.Duration(
+		"job-timeout",
+		2*time.Hour,
+		"Time a download or pin job may take as a whole, after which it's cancelled, and reported as a timeout",
+	)
+	maxRedirects := flag.Int(
+		"max-redirects",
+		10,
+		"Maximum number of redirects followed by episode downloads. 0 uses the default of 10, and a negative number doesn't follow any",
 	)
 	kuboHttpTimeout := flag.Duration(
 		"kubo-timeout",
 		6*time.Hour,
 		"Timeout for communicating with Kubo",
 	)
+	minEpisodeSize := flag.Int(
+		"min-episode-size",
+		1024,
+		"Smallest episode size in bytes which is accepted. Smaller files are almost always errors on the origin. Set to 0 to allow tiny files",
+	)
+	maxEpisodeSize := byteSizeFlag(
+		"max-episode-size",
+		0,
+		"Largest episode size which is accepted, like 500MB. Larger episodes are declined before downloading when the size is known. 0 allows any size",
+	)
+	sniffContent := flag.Bool(
+		"sniff-content",
+		false,
+		"Check that downloads start like an audio or video file, and fail the others, like HTML error pages served with 200 OK. Downloads with a document content type, like text/html, always fail",
+	)
+	jobLogs := flag.Bool(
+		"job-logs",
+		false,
+		"Send the log lines of a failed job, which can contain URLs, Kubo errors, and paths, with its results, so the owners of the feed can see why it failed. Only sent when the work server supports the v2 JSON protocol",
+	)
+	feedInterval := flag.Duration(
+		"feed-interval",
+		time.Hour,
+		"How often the feeds in the config file are checked for new episodes",
+	)
+	enclosureDir := flag.String(
+		"enclosure-dir",
+		"",
+		"Directory where a podcast:alternateEnclosure snippet is written for each pinned episode. Empty disables it",
+	)
+	enclosureGateway := flag.String(
+		"enclosure-gateway",
+		"",
+		"URL of an IPFS gateway, like https://ipfs.io, added as a source of the alternate enclosures",
+	)
+	provide := flag.Bool(
+		"provide",
+		false,
+		"Announce episodes to the DHT right after they're pinned, so other peers can find them without waiting for Kubo's next reprovide",
+	)
+	provideTimeout := flag.Duration(
+		"provide-timeout",
+		5*time.Minute,
+		"How long the announcement of an episode to the DHT may take",
+	)
+	retrievalGateway := flag.String(
+		"retrieval-gateway",
+		"",
+		"URL of a public IPFS gateway, like https://ipfs.io, through which pinned episodes are checked to be retrievable, to catch NAT or firewall problems. Empty disables the check",
+	)
+	retrievalTimeout := flag.Duration(
+		"retrieval-timeout",
+		2*time.Minute,
+		"How long the retrieval gateway may take to fetch a pinned episode",
+	)
+	mfsDir := flag.String(
+		"mfs-dir",
+		"",
+		"MFS directory, like /podcasts, where pinned episodes are copied to as <show>/<episode>, to browse them in the WebUI. Empty disables it",
+	)
+	manifestKey := flag.String(
+		"manifest-key",
+		"",
+		"Name of the Kubo key, like self, under which a manifest of the hosted episodes is published to IPNS. Empty disables it",
+	)
+	manifestRepublish := flag.Duration(
+		"manifest-republish",
+		12*time.Hour,
+		"How often the manifest is published again when it didn't change, so the IPNS record doesn't expire",
+	)
+	s3Endpoint := flag.String(
+		"s3-endpoint",
+		"",
+		"URL of an S3 compatible API, like https://s3.us-west-002.backblazeb2.com, which downloaded episodes are mirrored to. Empty disables it",
+	)
+	s3Region := flag.String(
+		"s3-region",
+		"",
+		"Region of the S3 bucket. Empty looks it up",
+	)
+	s3Bucket := flag.String(
+		"s3-bucket",
+		"",
+		"S3 bucket which downloaded episodes are mirrored to",
+	)
+	s3Prefix := flag.String(
+		"s3-prefix",
+		"",
+		"Prefix of the mirrored episodes' object keys, like podcasts/",
+	)
+	s3AccessKey := flag.String(
+		"s3-access-key",
+		"",
+		"Access key of the S3 bucket. Defaults to $AWS_ACCESS_KEY_ID",
+	)
+	s3SecretKey := flag.String(
+		"s3-secret-key",
+		"",
+		"Secret key of the S3 bucket. Defaults to $AWS_SECRET_ACCESS_KEY",
+	)
+	web3StorageToken := flag.String(
+		"web3storage-token",
+		"",
+		"API token of web3.storage. Hosted episodes are uploaded to it, so they stay available while the node is offline. Empty disables it",
+	)
+	web3StorageEndpoint := flag.String(
+		"web3storage-endpoint",
+		web3storage.DefaultEndpoint,
+		"URL of the web3.storage API, or a service with a compatible API",
+	)
+	archiveAPIAddress := flag.String(
+		"archive-api-address",
+		"",
+		"URL of an Estuary compatible Filecoin onboarding API, e.g. https://api.estuary.tech. Hosted episodes are archived to Filecoin through it. Empty disables it",
+	)
+	archiveToken := flag.String(
+		"archive-token",
+		"",
+		"API token of the Filecoin onboarding API",
+	)
+	archiveAfter := flag.Duration(
+		"archive-after",
+		30*24*time.Hour,
+		"How long an episode has to be hosted before it's archived to Filecoin",
+	)
+	archiveInterval := flag.Duration(
+		"archive-interval",
+		6*time.Hour,
+		"How often episodes are submitted for archival, and their deals are checked",
+	)
+	servedSampleInterval := flag.Duration(
+		"served-sample-interval",
+		5*time.Minute,
+		"How often the bitswap ledgers of the connected peers are sampled, to count the bytes the node served. 0 disables it",
+	)
+	reportServed := flag.Bool(
+		"report-served",
+		false,
+		"Send the bytes served to peers since the updater started with the work requests",
+	)
+	reproviderCheckInterval := flag.Duration(
+		"reprovider-check-interval",
+		time.Hour,
+		"How often Kubo's reprovider statistics are checked, to warn when it can't announce the pinned content to the DHT within its reprovide interval. 0 disables it",
+	)
+	bandwidthClass := flag.String(
+		"bandwidth-class",
+		"",
+		"Hint of the node's download speed sent to the server: low, medium, or high. Defaults to the class of the bandwidth profile's rate",
+	)
+	minFreeSpace := byteSizeFlag(
+		"min-free-space",
+		0,
+		"Minimum free space on the disk of the Kubo repo, like 10GB. Downloads and pins are declined while there is less. 0 disables the check",
+	)
+	cidVersion := flag.Int(
+		"cid-version",
+		0,
+		"CID version of added episodes, 0 or 1. CIDv1 also implies raw-leaves",
+	)
+	rawLeaves := flag.Bool(
+		"raw-leaves",
+		false,
+		"Use raw blocks for the leaf nodes of added episodes",
+	)
+	hashFunction := flag.String(
+		"hash",
+		"",
+		"Hash function of added episodes, like sha2-256 or blake3. Empty uses Kubo's default",
+	)
+	chunker := flag.String(
+		"chunker",
+		"",
+		"Chunker of added episodes, like size-262144, buzhash, or rabin-min-avg-max. Nodes have to chunk the same way for their blocks to be deduplicated. Empty uses Kubo's default",
+	)
+	maxStorage := byteSizeFlag(
+		"max-storage",
+		0,
+		"Storage quota for hosted episodes, e.g. 500GB. Jobs which would go over the quota are declined. 0 disables the quota",
+	)
+	evictHighWater := flag.Float64(
+		"evict-high-water",
+		0,
+		"Fraction of --max-storage, e.g. 0.95, above which episodes are unpinned, and reported as deleted, until they use less than --evict-low-water. 0 disables eviction",
+	)
+	evictLowWater := flag.Float64(
+		"evict-low-water",
+		0,
+		"Fraction of --max-storage eviction stops at. 0 uses 90% of --evict-high-water",
+	)
+	evictPolicy := flag.String(
+		"evict-policy",
+		updater.EvictLRU,
+		"Which episodes are evicted first: lru, the least recently assigned, largest, or oldest",
+	)
+	pinTTL := flag.Duration(
+		"pin-ttl",
+		0,
+		"How long episodes are hosted, when the work server doesn't send a TTL, like 720h. Expired episodes are unpinned, and reported as deleted. 0 hosts them until the server deletes them",
+	)
+	gcAfterDelete := flag.Bool(
+		"gc-after-delete",
+		false,
+		"Run the Kubo repo garbage collector after delete jobs",
+	)
+	gcThreshold := flag.Float64(
+		"gc-threshold",
+		0,
+		"Run the Kubo repo garbage collector when the repo uses more than this fraction of StorageMax, e.g. 0.9. 0 disables it",
+	)
+	maintenanceWindows := flag.String(
+		"maintenance-windows",
+		"",
+		"Comma separated daily windows in local time, e.g. 02:00-05:00, in which heavy maintenance tasks, like gc, reconciliation, and verification, are allowed to run. Empty allows them at any time",
+	)
+	reconcileInterval := flag.Duration(
+		"reconcile-interval",
+		24*time.Hour,
+		"How often the catalog of hosted episodes is reconciled with the pins on Kubo. Negative disables it",
+	)
+	verifyInterval := flag.Duration(
+		"verify-interval",
+		0,
+		"How often the blocks of the pinned episodes are verified, and broken episodes are repinned. 0 disables it",
+	)
+	stateDir := flag.String(
+		"state-dir",
+		defaultStateDir(),
+		"Directory where the catalog of hosted episodes, and the results which couldn't be sent to the server yet, are kept",
+	)
+	clusterAPIAddress := flag.String(
+		"cluster-api-address",
+		"",
+		"URL of an ipfs-cluster REST API, e.g. http://127.0.0.1:9094. Pins and unpins go through the cluster instead of only the Kubo node",
+	)
+	clusterBasicAuth := flag.String(
+		"cluster-basic-auth",
+		"",
+		"Basic auth credentials for the ipfs-cluster REST API, as user:password",
+	)
+	noWorkAlertCycles := flag.Int(
+		"no-work-alert-cycles",
+		0,
+		"Notify the webhooks after this many work cycles in a row without any work. 0 disables it",
+	)
+	failureAlertAfter := flag.Duration(
+		"failure-alert-after",
+		time.Hour,
+		"Notify once every work cycle failed for this long, with the last error. 0 disables it",
+	)
+	kuboRetryMax := flag.Duration(
+		"kubo-retry-max",
+		5*time.Minute,
+		"Longest wait between the checks if Kubo is reachable again, after a work cycle failed to reach it. The checks start after 5 seconds, and back off up to this",
+	)
+	serverFailures := flag.Int(
+		"server-failures",
+		5,
+		"Number of requests to the work server in a row which may fail, before work requests are paused for the server-cooldown",
+	)
+	serverCooldown := flag.Duration(
+		"server-cooldown",
+		10*time.Minute,
+		"How long work requests are paused after the work server failed server-failures times in a row. The local tasks, like the reconciliation, keep running",
+	)
+	maxShowLabels := flag.Int(
+		"max-show-labels",
+		0,
+		"Number of distinct shows which get their own label on the show_jobs_total metric. Later shows are counted as other. 0 disables the metric",
+	)
+	autoUpdateEnabled := flag.Bool(
+		"auto-update",
+		false,
+		"Replace the binary with the latest signed release from GitHub, and restart. Requires update-public-key",
+	)
+	autoUpdateInterval := flag.Duration(
+		"auto-update-interval",
+		24*time.Hour,
+		"How often to check for a new release",
+	)
+	updatePublicKey := flag.String(
+		"update-public-key",
+		"",
+		"Base64 ed25519 public key which the signatures of releases are verified with",
+	)
 	metricsAddress := flag.String(
 		"metrics-address",
 		":9196",
 		"address for the prometheus metrics endpoint",
 	)
+	kuboStatsInterval := flag.Duration(
+		"kubo-stats-interval",
+		30*time.Second,
+		"How often Kubo's stats, like the peers and the repo size, are collected for the metrics. Scrapes serve the last values",
+	)
+	kuboStatsTTL := flag.Duration(
+		"kubo-stats-ttl",
+		5*time.Minute,
+		"How long the last values of Kubo's stats are served while collecting them fails, before they're removed from the metrics",
+	)
+	proxyKuboMetrics := flag.Bool(
+		"proxy-kubo-metrics",
+		false,
+		"Serve Kubo's own metrics, from /debug/metrics/prometheus on the API, on the metrics-address too, prefixed with kubo_, so only one target has to be scraped",
+	)
+	adminAddress := flag.String(
+		"admin-address",
+		"127.0.0.1:9197",
+		"Address for the admin endpoints, like /status and the dashboard, which can trigger work cycles. Keep it on a loopback address, or set metrics-basic-auth. Empty disables them",
+	)
+	logLevel := flag.String(
+		"log-level",
+		"info",
+		"Minimum level of the logs, debug, info, warn, or error. debug includes the work from the server, and the timings of Kubo requests",
+	)
+	logFormat := flag.String(
+		"log-format",
+		"text",
+		"Format of the logs, text or json",
+	)
+	logFile := flag.String(
+		"log-file",
+		"",
+		"File to write the logs to, instead of stderr. The file is rotated when it reaches log-max-size",
+	)
+	logMaxSize := byteSizeFlag(
+		"log-max-size",
+		100_000_000,
+		"Size of the log file, at which it's rotated, e.g. 100MB",
+	)
+	logMaxAge := flag.Int(
+		"log-max-age",
+		0,
+		"Days to keep rotated log files. 0 keeps them regardless of age",
+	)
+	logMaxBackups := flag.Int(
+		"log-max-backups",
+		5,
+		"Number of rotated log files to keep. 0 keeps all of them",
+	)
+	otlpEndpoint := flag.String(
+		"otlp-endpoint",
+		"",
+		"OTLP HTTP endpoint to export traces of the work cycles to, e.g. http://localhost:4318. Empty disables tracing",
+	)
+	debugAddress := flag.String(
+		"debug-address",
+		"",
+		"Loopback address for the pprof endpoints, e.g. 127.0.0.1:6060. Empty disables them",
+	)
+	metricsBasicAuth := flag.String(
+		"metrics-basic-auth",
+		"",
+		"Basic auth credentials required by the metrics and admin servers, as user:password. /healthz and /readyz are served without it, for the probes. Empty disables auth",
+	)
+	metricsTLSCert := flag.String(
+		"metrics-tls-cert",
+		"",
+		"TLS certificate file of the metrics and admin servers. Requires metrics-tls-key",
+	)
+	metricsTLSKey := flag.String(
+		"metrics-tls-key",
+		"",
+		"TLS key file of the metrics and admin servers. Requires metrics-tls-cert",
+	)
+	configPath := flag.String(
+		"config",
+		"",
+		"JSON config file, which can set the flags, and the bandwidth profiles. Flags on the command line take precedence",
+	)
+	addSecretFileFlags(flag.CommandLine)
+
 	flag.Parse()
 
-	if *apiAddressStr == "" {
-		slog.Error("api-address missing. This flag is required.")
-		os.Exit(2)
+	var fileConf fileConfig
+
+	if *configPath != "" {
+		loaded, err := loadConfig(*configPath)
+		if err != nil {
+			slog.Error("loading config failed", "err", err)
+			os.Exit(2)
+		}
+
+		err = loaded.applyFlags(flag.CommandLine)
+		if err != nil {
+			slog.Error("applying config failed", "err", err)
+			os.Exit(2)
+		}
+
+		fileConf = *loaded
 	}
 
-	if *email == "" {
-		slog.Error("email missing. This flag is required. Set to email@example.com if you don't want to set it.")
+	err := applySecretFiles(flag.CommandLine)
+	if err != nil {
+		slog.Error("reading secret files failed", "err", err)
 		os.Exit(2)
 	}
 
-	slog.Info("starting", "api-address", *apiAddressStr, "email", *email)
-
-	apiAddress, err := multiaddr.NewMultiaddr(*apiAddressStr)
+	bandwidthProfiles, err := fileConf.bandwidthProfiles()
 	if err != nil {
-		slog.Error("parsing api-address failed", "err", err)
-		os.Exit(1)
+		slog.Error("parsing bandwidth_profiles failed", "err", err)
+		os.Exit(2)
 	}
 
-	httpClient := &http.Client{
-		Timeout: *httpTimeout,
+	var jobTypes []string
+
+	for _, jobType := range strings.Split(*jobs, ",") {
+		switch jobType {
+		case "download", "pin", "delete":
+			jobTypes = append(jobTypes, jobType)
+		case "":
+		default:
+			slog.Error("jobs must be download, pin, or delete", "job", jobType)
+			os.Exit(2)
+		}
 	}
 
-	kuboHTTPClient := &http.Client{
-		Timeout: *kuboHttpTimeout,
+	if jobTypes == nil {
+		jobTypes = []string{}
 	}
 
-	client, err := rpc.NewApiWithClient(apiAddress, kuboHTTPClient)
+	tags, err := fileConf.tags(*tagsFlag)
 	if err != nil {
-		slog.Error("creating api client failed", "err", err)
-		os.Exit(1)
+		slog.Error("parsing tags failed", "err", err)
+		os.Exit(2)
 	}
 
-	go runMetricsServer(client, *metricsAddress)
+	var level slog.Level
 
-	workRequest := WorkResponse{
-		Email:   *email,
-		Version: "0.6g", // g postfix used for this Go client.
+	err = level.UnmarshalText([]byte(*logLevel))
+	if err != nil {
+		slog.Error("invalid log-level", "err", err)
+		os.Exit(2)
 	}
 
-	for {
-		nextUpdate := time.Now().Add(*updateFrequency)
+	var logOutput io.Writer = os.Stderr
 
-		complete, err := doWork(client, httpClient, workRequest)
-		if err != nil {
-			slog.Error("job failed", "err", err)
-		}
+	if *logFile != "" {
+		file := newLogFile(logFileConfig{
+			path:       *logFile,
+			maxSize:    *logMaxSize,
+			maxAge:     *logMaxAge,
+			maxBackups: *logMaxBackups,
+		})
+		defer file.Close()
 
-		slog.Info("job finished", "complete", complete)
+		logOutput = file
+	}
 
-		time.Sleep(time.Until(nextUpdate))
+	logHandler, err := newLogHandler(logOutput, *logFormat, level)
+	if err != nil {
+		slog.Error("invalid log-format", "err", err)
+		os.Exit(2)
 	}
-}
 
-func runMetricsServer(client *rpc.HttpApi, metricsAddress string) {
-	handler := promhttp.Handler()
+	slog.SetDefault(slog.New(logHandler))
 
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		peers, err := getPeers(client)
-		if err != nil {
-			slog.Warn("metrics could not get peers")
-		} else {
-			metrics.IPFSPeers.Set(float64(peers))
+	if *apiSocket != "" {
+		if *apiAddressStr != "" {
+			slog.Error("api-address and api-socket can't be used together")
+			os.Exit(2)
 		}
 
-		stats, err := repoStats(client)
-		if err != nil {
-			slog.Warn("metrics could not get repo stats")
-		} else {
-			metrics.IPFSRepoDiskUsage.Set(float64(stats.RepoSize))
-			metrics.IPFSRepoObjects.Set(float64(stats.NumObjects))
-			metrics.IPFSRepoStorageMax.Set(float64(stats.StorageMax))
-		}
+		var addresses []string
 
-		handler.ServeHTTP(w, r)
-	})
+		for _, socket := range strings.Split(*apiSocket, ",") {
+			path, err := filepath.Abs(socket)
+			if err != nil {
+				slog.Error("invalid api-socket", "err", err)
+				os.Exit(2)
+			}
 
-	slog.Info("starting metrics server", "address", metricsAddress, "path", "/metrics")
+			addresses = append(addresses, "/unix"+path)
+		}
 
-	err := http.ListenAndServe(metricsAddress, nil)
-	if err != nil {
-		slog.Error("metrics server failed", "err", err)
+		*apiAddressStr = strings.Join(addresses, ",")
 	}
-}
 
-func getKuboStats(client *rpc.HttpApi, workResponse *WorkResponse) error {
-	nID, err := nodeID(client)
-	if err != nil {
-		return fmt.Errorf("getting node id failed: %w", err)
+	if *apiAddressStr == "" {
+		slog.Error("api-address missing. This flag is required.")
+		os.Exit(2)
 	}
 
-	workResponse.IPFSID = nID.ID
-
-	sys, err := diagSys(client)
-	if err != nil {
-		return fmt.Errorf("getting diag/sys failed: %w", err)
+	if *email == "" {
+		slog.Error("email missing. This flag is required. Set to email@example.com if you don't want to set it.")
+		os.Exit(2)
 	}
 
-	workResponse.IPFSVersion = sys.IPFSVersion
-	workResponse.Online = sys.Net.Online
-
-	peers, err := getPeers(client)
-	if err != nil {
-		return fmt.Errorf("fetching peers failed: %w", err)
+	if (*metricsTLSCert == "") != (*metricsTLSKey == "") {
+		slog.Error("metrics-tls-cert and metrics-tls-key must be set together")
+		os.Exit(2)
 	}
 
-	workResponse.Peers = peers
-
-	return nil
-}
-
-// first return value is if the operation was complete, or false if it exited early for any reason
-func doWork(client *rpc.HttpApi, httpClient *http.Client, workResponse WorkResponse) (bool, error) {
-	start := time.Now()
-	defer workResponse.ObserveJob(start)
-
-	errInt := 1
-
-	err := getKuboStats(client, &workResponse)
-	if err != nil {
-		return false, fmt.Errorf("get kubo stats failed: %w", err)
+	if *kuboStatsInterval <= 0 {
+		slog.Error("kubo-stats-interval must be positive")
+		os.Exit(2)
 	}
 
-	work, err := requestWork(httpClient, workResponse)
-	if err != nil {
-		return false, fmt.Errorf("requesting work failed: %w", err)
+	if *metricsBasicAuth != "" && !strings.Contains(*metricsBasicAuth, ":") {
+		slog.Error("metrics-basic-auth must be user:password")
+		os.Exit(2)
 	}
 
-	if work.Message == "No Work" {
-		return false, nil
+	if *proxy != "" && *socks5 != "" {
+		slog.Error("proxy and socks5 can't both be set")
+		os.Exit(2)
 	}
 
-	if work.Download != "" && work.Filename != "" {
-		slog.Info("Got download job", "download", work.Download, "filename", work.Filename)
+	if *socks5 != "" {
+		*proxy = "socks5://" + *socks5
+	}
 
-		downloaded, err := downloadOrPinFile(client, httpClient, work.Download, work.Filename)
+	if *debugAddress != "" {
+		err := checkLoopback(*debugAddress)
 		if err != nil {
-			slog.Error("downloading file failed", "file", work.Download, "err", err)
-			workResponse.Error = &errInt
-		} else {
-			workResponse.Downloaded = &downloaded.DownloadedFile
-			workResponse.Length = &downloaded.Length
+			slog.Error("debug-address must be a loopback address", "err", err)
+			os.Exit(2)
 		}
 	}
 
-	if work.Pin != "" {
-		slog.Info("Got pin job", "pin", work.Pin)
-
-		pinned, err := pinFile(client, work.Pin)
+	// The admin endpoints can trigger work, so they aren't exposed without
+	// credentials.
+	if *adminAddress != "" && *metricsBasicAuth == "" {
+		err := checkLoopback(*adminAddress)
 		if err != nil {
-			slog.Error("pin add failed", "err", err)
-			workResponse.Error = &errInt
-		} else {
-			workResponse.Pinned = &pinned.Pinned
-			workResponse.Length = &pinned.Length
+			slog.Error("admin-address must be a loopback address, unless metrics-basic-auth is set", "err", err)
+			os.Exit(2)
 		}
 	}
 
-	if work.Delete != "" {
-		slog.Info("Got delete job", "delete", work.Delete)
+	var publicKey ed25519.PublicKey
 
-		err := pinDelete(client, work.Delete)
+	if *autoUpdateEnabled {
+		publicKey, err = parsePublicKey(*updatePublicKey)
 		if err != nil {
-			slog.Error("pin delete failed", "err", err)
-			workResponse.Error = &errInt
-		} else {
-			workResponse.Deleted = &work.Delete
+			slog.Error("auto-update requires a valid update-public-key", "err", err)
+			os.Exit(2)
 		}
-	}
 
-	stats, err := repoStats(client)
-	if err != nil {
-		slog.Error("repo stat failed", "err", err)
-	} else {
-		workResponse.Avail = &stats.StorageMax
-		workResponse.Used = &stats.RepoSize
+		// A build without a release version, like a go build of a checkout,
+		// can't tell whether a release is newer.
+		_, err = parseSemver(version)
+		if err != nil {
+			slog.Error("auto-update requires a release build", "version", version, "err", err)
+			os.Exit(2)
+		}
 	}
 
-	err = responseWork(httpClient, workResponse)
-	if err != nil {
-		return false, fmt.Errorf("post stats failed: %w", err)
+	if *mfsDir != "" && (!strings.HasPrefix(*mfsDir, "/") || *mfsDir == "/") {
+		slog.Error("mfs-dir must be an absolute path below /, like /podcasts")
+		os.Exit(2)
 	}
 
-	if workResponse.Error != nil {
-		return false, nil
+	if *enclosureDir != "" {
+		err := os.MkdirAll(*enclosureDir, 0o755)
+		if err != nil {
+			slog.Error("creating enclosure-dir failed", "err", err)
+			os.Exit(2)
+		}
 	}
 
-	return true, nil
-}
-
-type PinFileResponse struct {
-	Pinned string
-	Length int
-}
-
-func pinFile(client *rpc.HttpApi, hash string) (*PinFileResponse, error) {
-	err := pinAdd(client, hash)
-	if err != nil {
-		return nil, fmt.Errorf("pin add failed: %w", err)
+	switch *bandwidthClass {
+	case "", updater.BandwidthLow, updater.BandwidthMedium, updater.BandwidthHigh:
+	default:
+		slog.Error("bandwidth-class must be low, medium, or high")
+		os.Exit(2)
 	}
 
-	lsResp, err := ls(client, hash)
-	if err != nil {
-		return nil, fmt.Errorf("ls failed: %w", err)
+	if *idleCycles < 1 {
+		slog.Error("idle-cycles must be at least 1")
+		os.Exit(2)
 	}
 
-	if len(lsResp.Objects) != 1 && len(lsResp.Objects[0].Links) != 1 {
-		return nil, fmt.Errorf("ls objects or links is not 1")
+	if *maxJobsPerHour < 0 {
+		slog.Error("max-jobs-per-hour can't be negative")
+		os.Exit(2)
 	}
 
-	link := lsResp.Objects[0].Links[0]
-	pinned := link.Hash + "/" + hash
-
-	return &PinFileResponse{
-		Pinned: pinned,
-		Length: link.Size,
-	}, nil
-}
-
-type repoStatsResponse struct {
-	RepoSize   int    `json:"RepoSize"`
-	StorageMax int    `json:"StorageMax"`
-	NumObjects int    `json:"NumObjects"`
-	RepoPath   string `json:"RepoPath"`
-	Version    string `json:"Version"`
-}
-
-func repoStats(client *rpc.HttpApi) (*repoStatsResponse, error) {
-	resp, err := client.Request("repo/stat").Send(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if *pinTTL < 0 {
+		slog.Error("pin-ttl can't be negative")
+		os.Exit(2)
 	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+
+	if *cidVersion != 0 && *cidVersion != 1 {
+		slog.Error("cid-version must be 0 or 1")
+		os.Exit(2)
 	}
-	defer resp.Output.Close()
 
-	decoder := json.NewDecoder(resp.Output)
-	stats := new(repoStatsResponse)
+	if *chunker != "" {
+		err := kubo.ValidateChunker(*chunker)
+		if err != nil {
+			slog.Error("invalid chunker", "err", err)
+			os.Exit(2)
+		}
+	}
 
-	err = decoder.Decode(stats)
+	protocol, err := workapi.ParseProtocol(*workProtocol)
 	if err != nil {
-		return nil, fmt.Errorf("decoding json failed: %w", err)
+		slog.Error("invalid work-protocol", "err", err)
+		os.Exit(2)
 	}
 
-	return stats, nil
-}
+	var windows []updater.Window
 
-func pinDelete(client *rpc.HttpApi, hash string) error {
-	hashPath, err := path.NewPath(hash)
-	if err != nil {
-		return fmt.Errorf("hash to path: %w", err)
-	}
+	if *maintenanceWindows != "" {
+		for _, windowStr := range strings.Split(*maintenanceWindows, ",") {
+			window, err := updater.ParseWindow(windowStr)
+			if err != nil {
+				slog.Error("parsing maintenance-windows failed", "err", err)
+				os.Exit(2)
+			}
 
-	err = client.Pin().Rm(context.Background(), hashPath)
-	if err != nil {
-		// This error is OK for us. Sometimes we get delete requests for
-		// files we don't have pinned. That's OK.
-		if strings.Contains(err.Error(), "not pinned or pinned indirectly") {
-			return nil
+			windows = append(windows, window)
 		}
-		return fmt.Errorf("request failed: %w", err)
 	}
 
-	return nil
-}
+	apiAddresses := strings.Split(*apiAddressStr, ",")
+	emails := strings.Split(*email, ",")
 
-func pinAdd(client *rpc.HttpApi, hash string) error {
-	hashPath, err := path.NewPath(hash)
-	if err != nil {
-		return fmt.Errorf("hash to path: %w", err)
+	if len(emails) != 1 && len(emails) != len(apiAddresses) {
+		slog.Error("email must be a single address, or one for each api-address")
+		os.Exit(2)
 	}
 
-	err = client.Pin().Add(context.Background(), hashPath)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+	for _, nodeEmails := range emails {
+		if slices.Contains(strings.Split(nodeEmails, ";"), "") {
+			slog.Error("email has an empty address")
+			os.Exit(2)
+		}
 	}
 
-	return nil
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-type lsResponse struct {
-	Objects []struct {
-		Hash  string `json:"Hash"`
-		Links []struct {
-			Name   string `json:"Name"`
-			Hash   string `json:"Hash"`
-			Size   int    `json:"Size"`
-			Type   int    `json:"Type"`
-			Target string `json:"Target"`
-		} `json:"links"`
-	} `json:"Objects"`
-}
+	if *otlpEndpoint != "" {
+		shutdownTracing, err := setupTracing(ctx, *otlpEndpoint)
+		if err != nil {
+			slog.Error("setting up tracing failed", "err", err)
+			os.Exit(1)
+		}
 
-func ls(client *rpc.HttpApi, hash string) (*lsResponse, error) {
-	resp, err := client.Request("ls", hash).Send(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+		defer func() {
+			err := shutdownTracing(context.Background())
+			if err != nil {
+				slog.Warn("flushing traces failed", "err", err)
+			}
+		}()
 	}
-	defer resp.Output.Close()
 
-	decoder := json.NewDecoder(resp.Output)
-	ls := new(lsResponse)
+	info := getBuildInfo()
 
-	err = decoder.Decode(ls)
-	if err != nil {
-		return nil, fmt.Errorf("json decode failed: %w", err)
-	}
+	metrics.BuildInfo.WithLabelValues(info.Version, info.Commit, info.Date, info.GoVersion).Set(1)
 
-	return ls, nil
-}
+	// Each node gets its own transport, which dials the socket of /unix
+	// addresses.
+	newKuboHTTPClient := func(apiAddress multiaddr.Multiaddr) *http.Client {
+		return &http.Client{
+			Timeout: *kuboHttpTimeout,
+			Transport: otelhttp.NewTransport(&loggingTransport{
+				name: "kubo",
+				next: kubo.NewTransport(apiAddress),
+			}),
+		}
+	}
 
-func fileSize(client *rpc.HttpApi, hash string) (int, error) {
-	lsResp, err := ls(client, hash)
+	senders, err := fileConf.senders(&http.Client{
+		Timeout: 30 * time.Second,
+	})
 	if err != nil {
-		return 0, fmt.Errorf("ls failed: %w", err)
+		slog.Error("parsing notifications failed", "err", err)
+		os.Exit(2)
 	}
 
-	total := 0
-	for _, object := range lsResp.Objects {
-		for _, link := range object.Links {
-			total += link.Size
-		}
-	}
+	notifier := notify.New(senders...)
 
-	return total, nil
-}
+	var serverLimiter workapi.Limiter
 
-type addResponse struct {
-	Name string `json:"Name"`
-	Hash string `json:"Hash"`
-	Size int    `json:"Size,string"`
-}
+	if *serverRateLimit > 0 {
+		serverLimiter = rate.NewLimiter(rate.Limit(float64(*serverRateLimit)/60), 1)
 
-type downloadFileResponse struct {
-	DownloadedFile string
-	Length         int
-}
-
-func downloadOrPinFile(client *rpc.HttpApi, httpClient *http.Client, download string, filename string) (*downloadFileResponse, error) {
-	downloadResp, err := downloadFile(client, httpClient, download, filename)
-	if err == nil {
-		return downloadResp, nil
+		slog.Info("limiting requests to the work server", "per-minute", *serverRateLimit)
 	}
 
-	slog.Error("download failed, try pin", "err", err, "download", download)
+	var workRecorder *workapi.Recorder
 
-	url, err := url.Parse(download)
-	if err != nil {
-		slog.Info("parse download url failed", "err", err, "download", download)
+	if *recordWork != "" {
+		file, err := os.OpenFile(*recordWork, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			slog.Error("opening record-work failed", "err", err)
+			os.Exit(1)
+		}
+		defer file.Close()
 
-		return downloadFile(client, httpClient, download, filename)
-	}
+		workRecorder = workapi.NewRecorder(file)
 
-	if strings.HasPrefix(url.Path, "/ipfs/") {
-		slog.Info("found ipfs file", "download", download)
+		slog.Warn("recording the exchanges with the work server", "record-work", *recordWork)
+	}
 
-		// /ipfs/<cid = 46>/...
-		//      ^5         ^52
-		downloadCid, err := cid.Decode(url.Path[6:52])
-		if err != nil {
-			slog.Info("parse cid failed", "err", err, "download", download)
+	updaters := make([]*updater.Updater, 0, len(apiAddresses))
+	kuboMetricsTargets := make([]kuboMetricsTarget, 0, len(apiAddresses))
 
-			return downloadFile(client, httpClient, download, filename)
+	for i, apiAddressStr := range apiAddresses {
+		email := emails[0]
+		if len(emails) != 1 {
+			email = emails[i]
 		}
 
-		pin, err := pinFile(client, downloadCid.String())
+		apiAddress, err := kubo.ParseAddress(apiAddressStr)
 		if err != nil {
-			slog.Error("pin instead of download failed", "err", err)
-
-			return downloadFile(client, httpClient, download, filename)
+			slog.Error("parsing api-address failed", "err", err)
+			os.Exit(2)
 		}
 
-		return &downloadFileResponse{
-			DownloadedFile: pin.Pinned,
-			Length:         pin.Length,
-		}, nil
-	}
-
-	return downloadFile(client, httpClient, download, filename)
-}
-
-func downloadFile(client *rpc.HttpApi, httpClient *http.Client, download string, filename string) (*downloadFileResponse, error) {
-	downloadResp, err := httpClient.Get(download)
-	if err != nil {
-		return nil, fmt.Errorf("download failed: %w", err)
-	}
-	defer downloadResp.Body.Close()
-
-	if downloadResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download file not OK: %d", downloadResp.StatusCode)
-	}
+		// host:port and URLs are named by their multiaddr, so the state
+		// dir doesn't depend on how the address was written.
+		apiAddressStr = apiAddress.String()
 
-	body, writer := io.Pipe()
-	reqMultipart := multipart.NewWriter(writer)
+		slog.Info("starting", "api-address", apiAddressStr, "email", email)
 
-	req := client.Request("add")
-	req = req.Option("wrap-with-directory", true)
-	req.Header("Content-Type", reqMultipart.FormDataContentType())
-	req.Body(body)
+		nodeEmails := strings.Split(email, ";")
 
-	var mpwCreateFormFileErr, copyErr, mpwCloseErr error
+		kuboHTTPClient := newKuboHTTPClient(apiAddress)
 
-	go func() {
-		w, err := reqMultipart.CreateFormFile("file", filename)
+		api, err := kubo.NewAPI(apiAddress, kuboHTTPClient)
 		if err != nil {
-			mpwCreateFormFileErr = err
-			return
+			slog.Error("creating api client failed", "err", err)
+			os.Exit(1)
 		}
 
-		_, copyErr = io.Copy(w, downloadResp.Body)
+		client := kubo.New(api)
+
+		baseURL, err := kubo.BaseURL(apiAddress)
 		if err != nil {
-			return
+			slog.Error("creating api client failed", "err", err)
+			os.Exit(1)
 		}
 
-		mpwCloseErr = reqMultipart.Close()
-	}()
+		kuboMetricsTargets = append(kuboMetricsTargets, kuboMetricsTarget{
+			node:   apiAddressStr,
+			url:    baseURL + "/debug/metrics/prometheus",
+			client: kuboHTTPClient,
+		})
 
-	resp, err := req.Send(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
-	}
-	defer resp.Output.Close()
+		nodeStateDir := *stateDir
+		if len(apiAddresses) != 1 {
+			nodeStateDir = filepath.Join(*stateDir, nodeDirName(apiAddressStr))
+		}
 
-	if mpwCreateFormFileErr != nil {
-		return nil, fmt.Errorf("creating form file failed: %w", mpwCreateFormFileErr)
-	}
-	if copyErr != nil {
-		return nil, fmt.Errorf("copy download failed: %w", copyErr)
-	}
-	if mpwCloseErr != nil {
-		return nil, fmt.Errorf("closing mutlipart writer failed: %w", mpwCloseErr)
-	}
+		episodeCatalog, err := catalog.Open(filepath.Join(nodeStateDir, "catalog.json"))
+		if err != nil {
+			slog.Error("opening catalog failed", "err", err)
+			os.Exit(1)
+		}
 
-	decoder := json.NewDecoder(resp.Output)
+		opts := updater.Options{
+			Email:                   nodeEmails[0],
+			Emails:                  nodeEmails[1:],
+			Name:                    apiAddressStr,
+			UserAgent:               info.userAgent(),
+			NodeSecret:              *nodeSecret,
+			ServerURL:               *serverURL,
+			Protocol:                protocol,
+			WorkRecorder:            workRecorder,
+			Jobs:                    jobTypes,
+			Tags:                    tags,
+			Push:                    *push,
+			Peering:                 *peering,
+			PeeringInterval:         *peeringInterval,
+			ServerLimiter:           serverLimiter,
+			ClientCert:              *clientCert,
+			ClientKey:               *clientKey,
+			DownloadProxy:           cmp.Or(*downloadProxy, *proxy),
+			MaxRedirects:            *maxRedirects,
+			ServerProxy:             cmp.Or(*serverProxy, *proxy),
+			HTTPTimeout:             *httpTimeout,
+			DownloadTimeout:         *downloadTimeout,
+			AddTimeout:              *addTimeout,
+			JobTimeout:              *jobTimeout,
+			UpdateFrequency:         *updateFrequency,
+			MinUpdateFrequency:      *minUpdateFrequency,
+			MaxUpdateFrequency:      *maxUpdateFrequency,
+			IdleCycles:              *idleCycles,
+			PollAfterWork:           *pollAfterWork,
+			MaxJobsPerHour:          *maxJobsPerHour,
+			UpdateJitter:            *updateJitter,
+			FollowRetryAfter:        *followRetryAfter,
+			Schedule:                *schedule,
+			MinEpisodeSize:          *minEpisodeSize,
+			MaxEpisodeSize:          *maxEpisodeSize,
+			SniffContent:            *sniffContent,
+			JobLogs:                 *jobLogs,
+			BandwidthProfiles:       bandwidthProfiles,
+			BandwidthClass:          *bandwidthClass,
+			Feeds:                   fileConf.Feeds,
+			EnclosureDir:            *enclosureDir,
+			ManifestKey:             *manifestKey,
+			MFSDir:                  strings.TrimSuffix(*mfsDir, "/"),
+			ManifestRepublish:       *manifestRepublish,
+			EnclosureGateway:        strings.TrimSuffix(*enclosureGateway, "/"),
+			Provide:                 *provide,
+			ProvideTimeout:          *provideTimeout,
+			RetrievalGateway:        strings.TrimSuffix(*retrievalGateway, "/"),
+			RetrievalTimeout:        *retrievalTimeout,
+			FeedInterval:            *feedInterval,
+			ArchiveAfter:            *archiveAfter,
+			ArchiveInterval:         *archiveInterval,
+			ServedSampleInterval:    *servedSampleInterval,
+			ReportServed:            *reportServed,
+			ReproviderCheckInterval: *reproviderCheckInterval,
+			AddOptions: kubo.AddOptions{
+				CIDVersion: *cidVersion,
+				RawLeaves:  *rawLeaves,
+				Hash:       *hashFunction,
+				Chunker:    *chunker,
+			},
+			MinFreeSpace:       *minFreeSpace,
+			MaxStorage:         *maxStorage,
+			EvictHighWater:     *evictHighWater,
+			EvictLowWater:      *evictLowWater,
+			EvictPolicy:        *evictPolicy,
+			PinTTL:             *pinTTL,
+			GCAfterDelete:      *gcAfterDelete,
+			GCThreshold:        *gcThreshold,
+			MaintenanceWindows: windows,
+			ReconcileInterval:  *reconcileInterval,
+			VerifyInterval:     *verifyInterval,
+			MaxShowLabels:      *maxShowLabels,
+			Notifier:           notifier,
+			NoWorkAlertCycles:  *noWorkAlertCycles,
+			FailureAlertAfter:  *failureAlertAfter,
+			KuboRetryMax:       *kuboRetryMax,
+			ServerFailures:     *serverFailures,
+			ServerCooldown:     *serverCooldown,
+			Catalog:            episodeCatalog,
+			PendingPath:        filepath.Join(nodeStateDir, "pending.json"),
+		}
 
-	added := [2]addResponse{}
+		if *clusterAPIAddress != "" {
+			// The cluster API is an HTTP URL, so it can't share the
+			// transport of a /unix api-address.
+			clusterTransport := http.DefaultTransport.(*http.Transport).Clone()
+			clusterTransport.Proxy = nil
+
+			clusterHTTPClient := &http.Client{
+				Timeout: *kuboHttpTimeout,
+				Transport: otelhttp.NewTransport(&loggingTransport{
+					name: "cluster",
+					next: clusterTransport,
+				}),
+			}
 
-	err = decoder.Decode(&added[0])
-	if err != nil {
-		return nil, fmt.Errorf("json decode failed: %w", err)
-	}
+			clusterClient, err := cluster.New(clusterHTTPClient, *clusterAPIAddress, *clusterBasicAuth)
+			if err != nil {
+				slog.Error("creating cluster client failed", "err", err)
+				os.Exit(1)
+			}
 
-	err = decoder.Decode(&added[1])
-	if err != nil {
-		return nil, fmt.Errorf("json decode failed: %w", err)
-	}
+			opts.Pinner = updater.NewClusterPinner(clusterClient, client)
 
-	size, err := fileSize(client, added[0].Hash)
-	if err != nil {
-		return nil, fmt.Errorf("getting file size failed: %w", err)
-	}
+			slog.Info("using ipfs-cluster for pins", "cluster-api-address", *clusterAPIAddress)
+		}
 
-	return &downloadFileResponse{
-		DownloadedFile: added[0].Hash + "/" + added[1].Hash,
-		Length:         size,
-	}, nil
-}
+		if *s3Endpoint != "" {
+			prefix := *s3Prefix
 
-func getPeers(client *rpc.HttpApi) (int, error) {
-	connectionInfo, err := client.Swarm().Peers(context.Background())
-	if err != nil {
-		return 0, fmt.Errorf("requesting peers failed: %w", err)
-	}
+			// Each node deletes its own episodes, so they can't share the
+			// objects.
+			if len(apiAddresses) != 1 {
+				prefix += nodeDirName(apiAddressStr) + "/"
+			}
 
-	return len(connectionInfo), nil
-}
+			mirror, err := updater.NewS3Mirror(updater.S3Config{
+				Endpoint:  *s3Endpoint,
+				Region:    *s3Region,
+				Bucket:    *s3Bucket,
+				Prefix:    prefix,
+				AccessKey: *s3AccessKey,
+				SecretKey: *s3SecretKey,
+			})
+			if err != nil {
+				slog.Error("creating s3 mirror failed", "err", err)
+				os.Exit(1)
+			}
 
-//	{
-//	  "diskinfo": {
-//	    "free_space": 45147315712,
-//	    "fstype": "3393526350",
-//	    "total_space": 44452741120
-//	  },
-//	  "environment": {
-//	    "GOPATH": "",
-//	    "IPFS_PATH": ""
-//	  },
-//	  "ipfs_commit": "",
-//	  "ipfs_version": "0.23.0",
-//	  "memory": {
-//	    "swap": 0,
-//	    "virt": 2983384000
-//	  },
-//	  "net": {
-//	    "interface_addresses": [
-//	      "/ip4/127.0.0.1",
-//	      "/ip4/192.168.0.160",
-//	      "/ip4/192.168.122.1",
-//	      "/ip4/100.89.52.31",
-//	      "/ip4/172.18.0.1",
-//	      "/ip4/172.17.0.1",
-//	      "/ip6/::1",
-//	      "/ip6/fe80::f2eb:eebb:44f5:837a",
-//	      "/ip6/fd7a:115c:a1e0:ab12:4843:cd96:6259:341f",
-//	      "/ip6/fe80::49b2:7ef3:ee2:ca18"
-//	    ],
-//	    "online": true
-//	  },
-//	  "runtime": {
-//	    "arch": "amd64",
-//	    "compiler": "gc",
-//	    "gomaxprocs": 16,
-//	    "numcpu": 16,
-//	    "numgoroutines": 283,
-//	    "os": "linux",
-//	    "version": "go1.21.3"
-//	  }
-//	}
-type DiagSysResponse struct {
-	DiskInfo struct {
-		FreeSpace  int64  `json:"free_space"`
-		FSType     string `json:"fstype"`
-		TotalSpace int64  `json:"total_space"`
-	} `json:"diskinfo"`
-	Environment struct {
-		GoPath   string `json:"GOPATH"`
-		IPFSPath string `json:"IPFS_PATH"`
-	} `json:"environment"`
-	IPFSCommit  string `json:"ipfs_commit"`
-	IPFSVersion string `json:"ipfs_version"`
-	Memory      struct {
-		Swap int64 `json:"swap"`
-		Virt int64 `json:"virt"`
-	} `json:"memory"`
-	Net struct {
-		InterfaceAddresses []string `json:"interface_addresses"`
-		Online             bool     `json:"online"`
-	} `json:"net"`
-	Runtime struct {
-		Arch          string `json:"arch"`
-		Compiler      string `json:"compiler"`
-		GoMacProcs    int    `json:"gomaxprocs"`
-		NumCPUs       int    `json:"numcpu"`
-		NumGoroutines int    `json:"numgoroutines"`
-		OS            string `json:"os"`
-		Version       string `json:"version"`
-	}
-}
+			opts.Mirror = mirror
+		}
 
-//	{
-//	  "ID": "12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	  "PublicKey": "CAESIJiZuBDyMqYaXmHzPgbKoOKHhKhPAgFkU/xt0563KZ81",
-//	  "Addresses": [
-//	    "/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/127.0.0.1/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/127.0.0.1/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/144.202.116.156/tcp/4001/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",    "/ip4/144.202.116.156/udp/4001/quic-v1/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/144.202.116.156/udp/4001/quic/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/192.168.0.160/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/192.168.0.160/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/192.168.0.160/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/64.20.50.242/tcp/4001/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/64.20.50.242/udp/4001/quic-v1/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip4/64.20.50.242/udp/4001/quic-v1/webtransport/certhash/uEiDaxiUKVD_6DcKDiWcumyWrtIkIXT2rNlo0k8EgpyT0Og/certhash/uEiArSVE3Q14fQzk2NU8CtG_xATGO1XrzTRWBglw5IbNKxg/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/tcp/4001/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/udp/4001/quic-v1/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/udp/4001/quic-v1/webtransport/certhash/uEiDaxiUKVD_6DcKDiWcumyWrtIkIXT2rNlo0k8EgpyT0Og/certhash/uEiArSVE3Q14fQzk2NU8CtG_xATGO1XrzTRWBglw5IbNKxg/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/::1/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/::1/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
-//	    "/ip6/::1/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg"
-//	  ],
-//	  "AgentVersion": "kubo/0.23.0/",
-//	  "Protocols": [
-//	    "/ipfs/bitswap",
-//	    "/ipfs/bitswap/1.0.0",
-//	    "/ipfs/bitswap/1.1.0",
-//	    "/ipfs/bitswap/1.2.0",
-//	    "/ipfs/id/1.0.0",
-//	    "/ipfs/id/push/1.0.0",
-//	    "/ipfs/lan/kad/1.0.0",
-//	    "/ipfs/ping/1.0.0",
-//	    "/libp2p/circuit/relay/0.2.0/stop",
-//	    "/x/"
-//	  ]
-//	}
-type IDResponse struct {
-	ID           string   `json:"ID"`
-	PublicKey    string   `json:"PublicKey"`
-	Addresses    []string `json:"Addresses"`
-	AgentVersion string   `json:"AgentVersion"`
-	Protocols    []string `json:"Protocols"`
-}
+		if *web3StorageToken != "" {
+			replicator, err := web3storage.New(&http.Client{Timeout: *httpTimeout}, *web3StorageEndpoint, *web3StorageToken)
+			if err != nil {
+				slog.Error("creating web3.storage client failed", "err", err)
+				os.Exit(1)
+			}
 
-func nodeID(client *rpc.HttpApi) (*IDResponse, error) {
-	resp, err := client.Request("id").Send(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("response error: %s", resp.Error.Message)
-	}
+			opts.Replicator = replicator
+		}
 
-	decoder := json.NewDecoder(resp.Output)
-	idResp := new(IDResponse)
+		if *archiveAPIAddress != "" {
+			archiver, err := filecoin.New(&http.Client{Timeout: time.Minute}, *archiveAPIAddress, *archiveToken)
+			if err != nil {
+				slog.Error("creating archive client failed", "err", err)
+				os.Exit(1)
+			}
 
-	err = decoder.Decode(idResp)
-	if err != nil {
-		return nil, fmt.Errorf("decoding diag/sys response failed: %w", err)
-	}
+			opts.Archiver = archiver
+		}
 
-	return idResp, nil
-}
+		u, err := updater.New(client, opts)
+		if err != nil {
+			slog.Error("creating updater failed", "err", err)
+			os.Exit(2)
+		}
 
-func diagSys(client *rpc.HttpApi) (*DiagSysResponse, error) {
-	resp, err := client.Request("diag/sys").Send(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("response error: %s", resp.Error.Message)
+		updaters = append(updaters, u)
 	}
 
-	decoder := json.NewDecoder(resp.Output)
-	diagSysResp := new(DiagSysResponse)
+	config := serverConfig{
+		basicAuth: *metricsBasicAuth,
+		tlsCert:   *metricsTLSCert,
+		tlsKey:    *metricsTLSKey,
+	}
 
-	err = decoder.Decode(diagSysResp)
-	if err != nil {
-		return nil, fmt.Errorf("decoding diag/sys response failed: %w", err)
+	var kuboMetrics *kuboMetricsProxy
+	if *proxyKuboMetrics {
+		kuboMetrics = newKuboMetricsProxy(kuboMetricsTargets, *kuboStatsTTL)
 	}
 
-	return diagSysResp, nil
-}
+	metricsMux := newMetricsMux(updaters, kuboMetrics)
 
-type WorkResponse struct {
-	Email       string `json:"email"`
-	Version     string `json:"version"`
-	IPFSID      string `json:"ipfs_id"`
-	IPFSVersion string `json:"ipfs_ver"`
-	Online      bool   `json:"online"`
-	Peers       int    `json:"peers,string"`
-
-	Downloaded *string `json:"downloaded,omitempty"`
-	Length     *int    `json:"length,omitempty"`
-	Error      *int    `json:"error,omitempty"`
-	Pinned     *string `json:"pinned,omitempty"`
-	Deleted    *string `json:"deleted,omitempty"`
-
-	Used  *int `json:"used,omitempty"`
-	Avail *int `json:"avail,omitempty"`
-}
+	wg := new(sync.WaitGroup)
+	unsupported := new(atomic.Bool)
 
-func (r WorkResponse) String() string {
-	sb := new(strings.Builder)
+	runServer := func(name string, address string, handler http.Handler, config serverConfig) {
+		wg.Add(1)
 
-	encoder := json.NewEncoder(sb)
+		go func() {
+			defer wg.Done()
 
-	_ = encoder.Encode(r)
+			err := serve(ctx, name, address, handler, config)
+			if err != nil {
+				slog.Error("server failed", "server", name, "err", err)
+			}
+		}()
+	}
 
-	return sb.String()
-}
+	runServer("metrics", *metricsAddress, metricsMux, config)
 
-func (r WorkResponse) ObserveJob(start time.Time) {
-	duration := time.Since(start)
-	isErr := r.Error != nil
+	if *adminAddress != "" {
+		adminMux := http.NewServeMux()
+		registerAdminHandlers(adminMux, updaters)
 
-	if r.Downloaded != nil {
-		metrics.ObserveJob("download", isErr, duration)
-	}
-	if r.Pinned != nil {
-		metrics.ObserveJob("pin", isErr, duration)
-	}
-	if r.Deleted != nil {
-		metrics.ObserveJob("delete", isErr, duration)
+		runServer("admin", *adminAddress, adminMux, config)
 	}
-}
 
-type Work struct {
-	Show     string `json:"show"`
-	Episode  string `json:"episode"`
-	Download string `json:"download"`
-	Pin      string `json:"pin"`
-	Filename string `json:"filename"`
-	Delete   string `json:"delete"`
-	Message  string `json:"message"`
-}
+	// Only reachable from the host, so it's served without auth or TLS.
+	if *debugAddress != "" {
+		runServer("debug", *debugAddress, newDebugMux(), serverConfig{})
+	}
 
-func (w Work) String() string {
-	sb := new(strings.Builder)
+	if kuboMetrics != nil {
+		wg.Add(1)
 
-	encoder := json.NewEncoder(sb)
+		go func() {
+			defer wg.Done()
 
-	_ = encoder.Encode(w)
+			kuboMetrics.run(ctx, *kuboStatsInterval)
+		}()
+	}
 
-	return sb.String()
-}
+	for _, u := range updaters {
+		wg.Add(2)
 
-func boolToStr(b bool) string {
-	if b {
-		return "true"
-	}
+		go func() {
+			defer wg.Done()
 
-	return "false"
-}
+			collectKuboStats(ctx, u, *kuboStatsInterval, *kuboStatsTTL)
+		}()
 
-func (r WorkResponse) Reader() io.Reader {
-	data := url.Values{
-		"email":    {r.Email},
-		"version":  {r.Version},
-		"ipfs_id":  {r.IPFSID},
-		"ipfs_ver": {r.IPFSVersion},
-		"online":   {boolToStr(r.Online)},
-		"peers":    {strconv.Itoa(r.Peers)},
-	}
+		go func() {
+			defer wg.Done()
 
-	if r.Downloaded != nil {
-		data.Set("downloaded", *r.Downloaded)
-	}
-	if r.Length != nil {
-		data.Set("length", strconv.Itoa(*r.Length))
-	}
-	if r.Error != nil {
-		data.Set("error", strconv.Itoa(*r.Error))
-	}
-	if r.Pinned != nil {
-		data.Set("pinned", *r.Pinned)
-	}
-	if r.Deleted != nil {
-		data.Set("deleted", *r.Deleted)
-	}
-	if r.Used != nil {
-		data.Set("used", strconv.Itoa(*r.Used))
-	}
-	if r.Avail != nil {
-		data.Set("avail", strconv.Itoa(*r.Avail))
+			err := u.Run(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("updater stopped", "node", u.Name(), "err", err)
+			}
+			// Refuse to run, rather than failing every cycle, until
+			// Kubo is upgraded.
+			if errors.Is(err, kubo.ErrUnsupportedVersion) {
+				unsupported.Store(true)
+				stop()
+			}
+		}()
 	}
 
-	slog.Info("work response", "data", data)
-
-	return strings.NewReader(data.Encode())
-}
+	updated := false
 
-func requestWork(client *http.Client, workResponse WorkResponse) (*Work, error) {
-	retries := 5
+	if *autoUpdateEnabled {
+		wg.Add(1)
 
-	for {
-		resp, err := client.Post(
-			"https://ipfspodcasting.net/request",
-			"application/x-www-form-urlencoded",
-			workResponse.Reader(),
-		)
-		if err != nil {
-			if retries > 0 && strings.Contains(err.Error(), "EOF") {
-				slog.Info("ipfspodcasting.net/request failed, retrying", "err", err, "retries_left", retries)
-				time.Sleep(5 * time.Second)
-				retries -= 1
+		go func() {
+			defer wg.Done()
 
-				continue
+			updated = autoUpdate(ctx, *autoUpdateInterval, publicKey)
+			if updated {
+				stop()
 			}
+		}()
+	}
 
-			return nil, fmt.Errorf("fetching work failed: %w", err)
-		}
-		defer resp.Body.Close()
+	wg.Wait()
 
-		decoder := json.NewDecoder(resp.Body)
-		var work Work
+	if unsupported.Load() {
+		os.Exit(1)
+	}
 
-		err = decoder.Decode(&work)
+	if updated {
+		err := restart()
 		if err != nil {
-			return nil, fmt.Errorf("decoding work failed: %w", err)
+			slog.Error("restart after update failed", "err", err)
+			os.Exit(1)
 		}
-
-		return &work, nil
 	}
 }
 
-func responseWork(client *http.Client, workResponse WorkResponse) error {
-	retries := 5
-
-	for {
-		resp, err := client.Post(
-			"https://ipfspodcasting.net/response",
-			"application/x-www-form-urlencoded",
-			workResponse.Reader(),
-		)
+// defaultStateDir is $XDG_STATE_HOME/ipfspodcasting, falling back to
+// ~/.local/state/ipfspodcasting.
+func defaultStateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
 		if err != nil {
-			if retries > 0 && strings.Contains(err.Error(), "EOF") {
-				slog.Info("ipfspodcasting.net/response failed, retrying", "err", err, "retries_left", retries)
-				time.Sleep(5 * time.Second)
-				retries -= 1
-
-				continue
-			}
-
-			return fmt.Errorf("fetching work failed: %w", err)
+			return "ipfspodcasting"
 		}
 
-		resp.Body.Close()
-
-		return nil
+		stateHome = filepath.Join(home, ".local", "state")
 	}
+
+	return filepath.Join(stateHome, "ipfspodcasting")
+}
+
+// nodeDirName is the name of the state directory of a node, when there is
+// more than one.
+func nodeDirName(apiAddress string) string {
+	return strings.ReplaceAll(strings.Trim(apiAddress, "/"), "/", "_")
 }
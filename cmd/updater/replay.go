@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubotest"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/angaz/ipfspodcasting/pkg/workapitest"
+)
+
+// defaultReplaySize is the size of the replaced downloads, when neither the
+// work, nor the recorded result, has the length.
+const defaultReplaySize = 1 << 20
+
+// runReplay replays a capture of the exchanges with the work server, recorded
+// with --record-work, through the work loop, against a fake work server and
+// a fake Kubo node. It logs the results of the replay next to the recorded
+// ones, and returns the exit code, 1 if any differ.
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+
+	fetchDownloads := flags.Bool(
+		"fetch-downloads",
+		false,
+		"Download the episodes from their origins. Otherwise they are replaced with random content of the recorded length, served locally",
+	)
+	timeout := flags.Duration(
+		"timeout",
+		10*time.Minute,
+		"Timeout of the replay",
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: updater replay [flags] <capture>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	differences, err := replay(ctx, flags.Arg(0), *fetchDownloads)
+	if err != nil {
+		slog.Error("replay failed", "err", err)
+
+		return 1
+	}
+
+	if differences != 0 {
+		slog.Error("replayed results differ from the capture", "differences", differences)
+
+		return 1
+	}
+
+	slog.Info("replayed results match the capture")
+
+	return 0
+}
+
+// replayCapture is a capture, prepared for the fake work server.
+type replayCapture struct {
+	replies []workapitest.Reply
+	// results are the recorded results, sent to /response.
+	results []workapi.WorkResponse
+	email   string
+	json    bool
+	// downloads are the replaced downloads, by path on the origin.
+	downloads map[string]replayDownload
+}
+
+// replayDownload is a replaced download, served by the origin.
+type replayDownload struct {
+	size int
+	// status is the error status of the recorded download, or zero.
+	status int
+}
+
+func replay(ctx context.Context, capturePath string, fetchDownloads bool) (int, error) {
+	file, err := os.Open(capturePath)
+	if err != nil {
+		return 0, fmt.Errorf("opening capture failed: %w", err)
+	}
+	defer file.Close()
+
+	exchanges, err := workapi.ReadExchanges(file)
+	if err != nil {
+		return 0, err
+	}
+
+	origin := httptest.NewUnstartedServer(nil)
+	defer origin.Close()
+
+	capture, err := prepareCapture(exchanges, "http://"+origin.Listener.Addr().String(), fetchDownloads)
+	if err != nil {
+		return 0, err
+	}
+
+	origin.Config.Handler = capture.originHandler()
+	origin.Start()
+
+	slog.Info(
+		"replaying capture",
+		"exchanges", len(exchanges),
+		"requests", len(capture.replies),
+		"results", len(capture.results),
+	)
+
+	server := workapitest.NewServer()
+	defer server.Close()
+
+	server.SetJSON(capture.json)
+	server.QueueReply(capture.replies...)
+
+	node := kubotest.NewServer()
+	defer node.Close()
+
+	k, err := node.Client()
+	if err != nil {
+		return 0, fmt.Errorf("creating kubo client failed: %w", err)
+	}
+
+	u, err := updater.New(k, updater.Options{
+		Email:              cmp.Or(capture.email, "replay@example.com"),
+		Name:               "replay",
+		ServerURL:          server.URL,
+		UpdateFrequency:    time.Second,
+		MinUpdateFrequency: time.Second,
+		MaxUpdateFrequency: time.Second,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating updater failed: %w", err)
+	}
+
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+
+	go func() {
+		_ = u.Run(runCtx)
+	}()
+
+	// The work loop requests work again after it sent the result of the
+	// last reply, and gets "No Work".
+	err = waitRequests(ctx, server, len(capture.replies)+1)
+	if err != nil {
+		return 0, err
+	}
+
+	stopRun()
+
+	return compareResults(capture.results, server.Responses()), nil
+}
+
+// prepareCapture turns the recorded responses to /request into replies. The
+// downloads are replaced with paths on originURL, unless fetchDownloads.
+// Requests which failed without a response can't be replayed, and are
+// skipped.
+func prepareCapture(exchanges []workapi.Exchange, originURL string, fetchDownloads bool) (*replayCapture, error) {
+	capture := &replayCapture{
+		downloads: map[string]replayDownload{},
+	}
+
+	for i, exchange := range exchanges {
+		if strings.HasPrefix(exchange.ResponseContentType, workapi.JSONContentType) {
+			capture.json = true
+		}
+
+		if exchange.Error != "" {
+			slog.Warn("skipping request which failed without a response", "exchange", i+1, "path", exchange.Path, "err", exchange.Error)
+
+			continue
+		}
+
+		request, err := workapitest.Decode(exchange.ContentType, []byte(exchange.Request))
+		if err != nil {
+			return nil, fmt.Errorf("decoding request of exchange %d failed: %w", i+1, err)
+		}
+
+		if capture.email == "" {
+			capture.email = request.Email
+		}
+
+		switch exchange.Path {
+		case "/response":
+			if exchange.Status < http.StatusInternalServerError {
+				capture.results = append(capture.results, request)
+			}
+		case "/request":
+			reply := workapitest.Reply{
+				Status:     exchange.Status,
+				RetryAfter: workapi.ParseRetryAfter(exchange.RetryAfter, exchange.Time),
+			}
+
+			if exchange.Status == http.StatusOK {
+				err := json.Unmarshal([]byte(exchange.Response), &reply.Work)
+				if err != nil {
+					return nil, fmt.Errorf("decoding work of exchange %d failed: %w", i+1, err)
+				}
+			}
+
+			if reply.Work.Download != "" && !fetchDownloads {
+				name := strconv.Itoa(len(capture.replies)) + "/" + path.Base(reply.Work.Download)
+
+				capture.downloads["/"+name] = newReplayDownload(reply.Work, exchanges[i+1:])
+				reply.Work.Download = originURL + "/" + name
+			}
+
+			capture.replies = append(capture.replies, reply)
+		}
+	}
+
+	return capture, nil
+}
+
+// newReplayDownload replaces the download of work, with the length of the
+// work, or the length in the recorded result of the job, the first result in
+// next. Downloads which failed with an HTTP status fail again with it.
+func newReplayDownload(work workapi.Work, next []workapi.Exchange) replayDownload {
+	download := replayDownload{
+		size: work.Length,
+	}
+
+	for _, exchange := range next {
+		if exchange.Path != "/response" {
+			continue
+		}
+
+		result, err := workapitest.Decode(exchange.ContentType, []byte(exchange.Request))
+		if err != nil {
+			break
+		}
+
+		if download.size == 0 && result.Length != nil {
+			download.size = *result.Length
+		}
+
+		status, ok := strings.CutPrefix(result.ErrorReason, "download_")
+		if ok {
+			download.status, _ = strconv.Atoi(status)
+		}
+
+		break
+	}
+
+	if download.size <= 0 {
+		download.size = defaultReplaySize
+	}
+
+	return download
+}
+
+// originHandler serves random content, of the recorded size, for the
+// replaced downloads, or the recorded error status.
+func (c *replayCapture) originHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		download, ok := c.downloads[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if download.status != 0 {
+			w.WriteHeader(download.status)
+
+			return
+		}
+
+		content := make([]byte, download.size)
+		_, _ = rand.NewChaCha8([32]byte{}).Read(content)
+
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(content))
+	})
+}
+
+// waitRequests waits until the server received n requests for work.
+func waitRequests(ctx context.Context, server *workapitest.Server, n int) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		requests := 0
+
+		for _, request := range server.Requests() {
+			if request.Path == "/request" {
+				requests += 1
+			}
+		}
+
+		if requests >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the work loop failed, after %d of %d requests: %w", requests, n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// compareResults logs the recorded and replayed results, and returns the
+// number which differ. The hashes aren't compared, because the fake node
+// doesn't compute the same CIDs as Kubo, only which jobs succeeded, and the
+// errors.
+func compareResults(recorded []workapi.WorkResponse, replayed []workapi.WorkResponse) int {
+	differences := 0
+
+	for i := range max(len(recorded), len(replayed)) {
+		if i >= len(recorded) {
+			slog.Warn("extra result in the replay", "result", i+1, "replayed", replayed[i].String())
+			differences += 1
+
+			continue
+		}
+
+		if i >= len(replayed) {
+			slog.Warn("result missing from the replay", "result", i+1, "recorded", recorded[i].String())
+			differences += 1
+
+			continue
+		}
+
+		diff := diffResult(recorded[i], replayed[i])
+		if len(diff) != 0 {
+			slog.Warn(
+				"replayed result differs",
+				"result", i+1,
+				"fields", strings.Join(diff, ","),
+				"recorded", recorded[i].String(),
+				"replayed", replayed[i].String(),
+			)
+			differences += 1
+
+			continue
+		}
+
+		slog.Info("replayed result matches", "result", i+1)
+	}
+
+	return differences
+}
+
+// diffResult returns the names of the fields which differ.
+func diffResult(a workapi.WorkResponse, b workapi.WorkResponse) []string {
+	var diff []string
+
+	if (a.Downloaded == nil) != (b.Downloaded == nil) {
+		diff = append(diff, "downloaded")
+	}
+	if (a.Pinned == nil) != (b.Pinned == nil) {
+		diff = append(diff, "pinned")
+	}
+	if (a.Deleted == nil) != (b.Deleted == nil) {
+		diff = append(diff, "deleted")
+	}
+	if a.Length != nil && b.Length != nil && *a.Length != *b.Length {
+		diff = append(diff, "length")
+	}
+	if (a.Error == nil) != (b.Error == nil) {
+		diff = append(diff, "error")
+	}
+	if a.ErrorReason != b.ErrorReason {
+		diff = append(diff, "error_code")
+	}
+	if a.ErrorPermanent != b.ErrorPermanent {
+		diff = append(diff, "error_permanent")
+	}
+
+	return diff
+}
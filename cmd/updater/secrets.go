@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretFlags can be read from files, so they don't show up in the process
+// list. Each gets a <name>-file flag, and an IPFSPODCASTING_<NAME>_FILE
+// environment variable, like Docker secrets.
+var secretFlags = []string{
+	"email",
+	"node-secret",
+	"cluster-basic-auth",
+	"metrics-basic-auth",
+	"archive-token",
+	"s3-secret-key",
+	"web3storage-token",
+}
+
+// secretEnv is the environment variable with the file of the flag.
+func secretEnv(name string) string {
+	return "IPFSPODCASTING_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_FILE"
+}
+
+// addSecretFileFlags adds the <name>-file flags of the secretFlags.
+func addSecretFileFlags(flags *flag.FlagSet) {
+	for _, name := range secretFlags {
+		flags.String(
+			name+"-file",
+			"",
+			fmt.Sprintf("File to read --%s from. Also read from $%s", name, secretEnv(name)),
+		)
+	}
+}
+
+// applySecretFiles sets the secret flags from their files. The environment
+// variable is only used if neither the flag nor its file flag were set.
+func applySecretFiles(flags *flag.FlagSet) error {
+	set := map[string]bool{}
+
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range secretFlags {
+		fileFlag := name + "-file"
+
+		if set[name] && set[fileFlag] {
+			return fmt.Errorf("--%s and --%s can't both be set", name, fileFlag)
+		}
+
+		path := flags.Lookup(fileFlag).Value.String()
+
+		if path == "" && !set[name] {
+			path = os.Getenv(secretEnv(name))
+		}
+
+		if path == "" {
+			continue
+		}
+
+		value, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s failed: %w", fileFlag, err)
+		}
+
+		err = flags.Set(name, strings.TrimRight(string(value), "\r\n"))
+		if err != nil {
+			return fmt.Errorf("setting %s failed: %w", name, err)
+		}
+	}
+
+	return nil
+}
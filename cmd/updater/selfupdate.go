@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// releasesURL is the latest release of the updater on GitHub.
+const releasesURL = "https://api.github.com/repos/angaz/ipfspodcasting/releases/latest"
+
+// ErrUpToDate is returned by selfUpdate when the running version is the
+// latest release, or newer.
+var ErrUpToDate = errors.New("already up to date")
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL is the download URL of the release asset with the name.
+func (r githubRelease) assetURL(name string) (string, error) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, nil
+		}
+	}
+
+	return "", fmt.Errorf("release %s has no asset %s", r.TagName, name)
+}
+
+// parsePublicKey decodes a base64 ed25519 public key.
+func parsePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 failed: %w", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("key is %d bytes, expected %d", len(key), ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// selfUpdate replaces the running binary with the latest release for the
+// platform, and returns the release's tag.
+//
+// The release has to contain the binary as updater-<GOOS>-<GOARCH>, and its
+// signature as updater-<GOOS>-<GOARCH>.sig, which is the base64 ed25519
+// signature of the binary's SHA-256 digest. The binary is only replaced if
+// the signature is valid for publicKey.
+func selfUpdate(ctx context.Context, client *http.Client, publicKey ed25519.PublicKey, force bool) (string, error) {
+	if version == "dev" && !force {
+		return "", fmt.Errorf("development builds are only replaced with force")
+	}
+
+	release, err := latestRelease(ctx, client)
+	if err != nil {
+		return "", fmt.Errorf("getting latest release failed: %w", err)
+	}
+
+	if !force {
+		current, err := parseSemver(version)
+		if err != nil {
+			return "", fmt.Errorf("parsing version failed: %w", err)
+		}
+
+		latest, err := parseSemver(release.TagName)
+		if err != nil {
+			return "", fmt.Errorf("parsing release tag failed: %w", err)
+		}
+
+		// Only a newer release replaces the binary, so a release which was
+		// pulled doesn't downgrade the nodes which already updated.
+		if !latest.newerThan(current) {
+			return release.TagName, ErrUpToDate
+		}
+	}
+
+	assetName := fmt.Sprintf("updater-%s-%s", runtime.GOOS, runtime.GOARCH)
+
+	binaryURL, err := release.assetURL(assetName)
+	if err != nil {
+		return "", err
+	}
+
+	signatureURL, err := release.assetURL(assetName + ".sig")
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := downloadSignature(ctx, client, signatureURL)
+	if err != nil {
+		return "", fmt.Errorf("downloading signature failed: %w", err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("finding executable failed: %w", err)
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", fmt.Errorf("resolving executable failed: %w", err)
+	}
+
+	// The new binary is written next to the old one, so the rename which
+	// replaces it is atomic.
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".updater-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digest, err := downloadBinary(ctx, client, binaryURL, tmp)
+	if err != nil {
+		return "", fmt.Errorf("downloading binary failed: %w", err)
+	}
+
+	if !ed25519.Verify(publicKey, digest, signature) {
+		return "", fmt.Errorf("signature of %s is invalid", assetName)
+	}
+
+	err = tmp.Chmod(0o755)
+	if err != nil {
+		return "", fmt.Errorf("chmod failed: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return "", fmt.Errorf("closing temp file failed: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), executable)
+	if err != nil {
+		return "", fmt.Errorf("replacing executable failed: %w", err)
+	}
+
+	return release.TagName, nil
+}
+
+func latestRelease(ctx context.Context, client *http.Client) (*githubRelease, error) {
+	resp, err := get(ctx, client, releasesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var release githubRelease
+
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		return nil, fmt.Errorf("decoding release failed: %w", err)
+	}
+
+	return &release, nil
+}
+
+func downloadSignature(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return nil, fmt.Errorf("reading body failed: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 failed: %w", err)
+	}
+
+	return signature, nil
+}
+
+// downloadBinary writes the binary to w, and returns its SHA-256 digest.
+func downloadBinary(ctx context.Context, client *http.Client, url string, w io.Writer) ([]byte, error) {
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(w, hash), resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("copy failed: %w", err)
+	}
+
+	return hash.Sum(nil), nil
+}
+
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	req.Header.Set("User-Agent", getBuildInfo().userAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// restart replaces the process with the, possibly updated, executable.
+func restart() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding executable failed: %w", err)
+	}
+
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
+
+// runSelfUpdate is the self-update subcommand.
+func runSelfUpdate(args []string) int {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError)
+
+	publicKeyStr := flags.String(
+		"update-public-key",
+		"",
+		"Base64 ed25519 public key which the signatures of releases are verified with. Required",
+	)
+	force := flags.Bool(
+		"force",
+		false,
+		"Replace the binary, even if it's the latest version",
+	)
+	flags.Parse(args)
+
+	publicKey, err := parsePublicKey(*publicKeyStr)
+	if err != nil {
+		slog.Error("invalid update-public-key", "err", err)
+
+		return 2
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Minute,
+	}
+
+	tag, err := selfUpdate(context.Background(), client, publicKey, *force)
+	if errors.Is(err, ErrUpToDate) {
+		slog.Info("already up to date", "version", tag)
+
+		return 0
+	}
+	if err != nil {
+		slog.Error("self-update failed", "err", err)
+
+		return 1
+	}
+
+	slog.Info("updated", "from", version, "to", tag)
+
+	return 0
+}
+
+// autoUpdate checks for a new release every interval, until ctx is done. It
+// returns true once the binary was replaced.
+func autoUpdate(ctx context.Context, interval time.Duration, publicKey ed25519.PublicKey) bool {
+	client := &http.Client{
+		Timeout: 10 * time.Minute,
+	}
+
+	for {
+		tag, err := selfUpdate(ctx, client, publicKey, false)
+		switch {
+		case errors.Is(err, ErrUpToDate):
+			slog.Debug("already up to date", "version", tag)
+		case err != nil:
+			slog.Error("auto-update failed", "err", err)
+		default:
+			slog.Info("updated, restarting", "from", version, "to", tag)
+
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type nodeStatus struct {
+	Node                   string  `json:"node"`
+	UpdateFrequency        string  `json:"update_frequency"`
+	UpdateFrequencySeconds float64 `json:"update_frequency_seconds"`
+}
+
+type statusResponse struct {
+	Nodes []nodeStatus `json:"nodes"`
+}
+
+// healthCheck is the result of a single check of a node.
+type healthCheck struct {
+	Node  string `json:"node"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check func(u *updater.Updater, ctx context.Context) error
+}
+
+// healthHandler runs the checks on all the nodes. It responds with 503 if any
+// of them fail, and lists the results in the body.
+func healthHandler(updaters []*updater.Updater, checks []namedCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		resp := healthResponse{
+			Status: "ok",
+			Checks: []healthCheck{},
+		}
+
+		for _, u := range updaters {
+			for _, check := range checks {
+				result := healthCheck{
+					Node: u.Name(),
+					Name: check.name,
+				}
+
+				err := check.check(u, ctx)
+				if err != nil {
+					resp.Status = "fail"
+					result.Error = err.Error()
+				}
+
+				resp.Checks = append(resp.Checks, result)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if resp.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			slog.Warn("writing health failed", "err", err)
+		}
+	}
+}
+
+type serverConfig struct {
+	// basicAuth is user:password, or empty to disable auth.
+	basicAuth string
+	tlsCert   string
+	tlsKey    string
+}
+
+// healthPaths are served without basic auth, because the probes of Kubernetes
+// and Docker can't send credentials. They only tell whether the node is
+// healthy.
+var healthPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// requireBasicAuth responds with 401 to requests without the user:password
+// credentials, except for the health checks.
+func requireBasicAuth(next http.Handler, credentials string) http.Handler {
+	wantUser, wantPassword, _ := strings.Cut(credentials, ":")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword)) == 1
+
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ipfspodcasting"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newMetricsMux serves the metrics and the health checks. kuboMetrics adds
+// Kubo's own metrics, or is nil to only serve the updater's.
+func newMetricsMux(updaters []*updater.Updater, kuboMetrics *kuboMetricsProxy) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// The stats of Kubo are collected in the background, by
+	// collectKuboStats, so the scrapes don't wait for Kubo.
+	if kuboMetrics == nil {
+		mux.Handle("/metrics", promhttp.Handler())
+	} else {
+		gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, kuboMetrics}
+
+		mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}),
+		))
+	}
+
+	// healthz only checks the node itself, so a work server outage doesn't
+	// restart every node. readyz also checks the work server.
+	liveChecks := []namedCheck{
+		{"kubo", (*updater.Updater).CheckKubo},
+		{"disk", (*updater.Updater).CheckDisk},
+	}
+	readyChecks := append(liveChecks, namedCheck{"work_server", (*updater.Updater).CheckWorkServer})
+
+	mux.HandleFunc("/healthz", healthHandler(updaters, liveChecks))
+	mux.HandleFunc("/readyz", healthHandler(updaters, readyChecks))
+
+	return mux
+}
+
+// findUpdater returns the updater of the node, or the first one if node is
+// empty. Nil if there is no such node.
+func findUpdater(updaters []*updater.Updater, node string) *updater.Updater {
+	for _, u := range updaters {
+		if node == "" || u.Name() == node {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// registerAdminHandlers adds the admin endpoints to mux. They are only served
+// on the admin listener, never on the metrics listener, because they can
+// trigger work.
+func registerAdminHandlers(mux *http.ServeMux, updaters []*updater.Updater) {
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status := statusResponse{
+			Nodes: make([]nodeStatus, 0, len(updaters)),
+		}
+
+		for _, u := range updaters {
+			current := u.UpdateFrequency()
+
+			status.Nodes = append(status.Nodes, nodeStatus{
+				Node:                   u.Name(),
+				UpdateFrequency:        current.String(),
+				UpdateFrequencySeconds: current.Seconds(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(status)
+		if err != nil {
+			slog.Warn("writing status failed", "err", err)
+		}
+	})
+
+	// The podcast:alternateEnclosure of a pinned episode, by the cid of the
+	// directory wrapping the file. With several nodes, node selects which
+	// one to ask, and defaults to the first.
+	mux.HandleFunc("/alternate-enclosure", func(w http.ResponseWriter, r *http.Request) {
+		hash := r.URL.Query().Get("cid")
+		if hash == "" {
+			http.Error(w, "cid is required", http.StatusBadRequest)
+
+			return
+		}
+
+		u := findUpdater(updaters, r.URL.Query().Get("node"))
+		if u == nil {
+			http.Error(w, "unknown node", http.StatusNotFound)
+
+			return
+		}
+
+		enclosure, err := u.AlternateEnclosure(r.Context(), hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+
+		_, err = w.Write([]byte(enclosure.Snippet()))
+		if err != nil {
+			slog.Warn("writing alternate enclosure failed", "err", err)
+		}
+	})
+
+	registerDashboard(mux, updaters)
+}
+
+// newDebugMux serves the pprof handlers.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// checkLoopback returns an error if address doesn't listen only on a loopback
+// interface.
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("parsing address failed: %w", err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s is not a loopback address", address)
+	}
+
+	return nil
+}
+
+// serve runs an HTTP server on address until ctx is done, then shuts it down
+// gracefully.
+func serve(ctx context.Context, name string, address string, handler http.Handler, config serverConfig) error {
+	if config.basicAuth != "" {
+		handler = requireBasicAuth(handler, config.basicAuth)
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// Health checks query Kubo, which can be slow while it's busy.
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			slog.Warn("server shutdown failed", "server", name, "err", err)
+		}
+	}()
+
+	slog.Info(
+		"starting server",
+		"server", name,
+		"address", address,
+		"tls", config.tlsCert != "",
+		"basic_auth", config.basicAuth != "",
+	)
+
+	var err error
+	if config.tlsCert != "" {
+		err = server.ListenAndServeTLS(config.tlsCert, config.tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
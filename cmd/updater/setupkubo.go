@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+const (
+	// connMgrLowWater and connMgrHighWater are the connection manager
+	// limits recommended for a node which serves episodes. Kubo's defaults
+	// of 32 and 96 drop the peers which are fetching from the node.
+	connMgrLowWater  = 100
+	connMgrHighWater = 400
+)
+
+// kuboSetting is a change of Kubo's config recommended by setup-kubo.
+type kuboSetting struct {
+	key string
+	// current is the JSON value in the config, nil if it isn't set.
+	current json.RawMessage
+	value   any
+	reason  string
+}
+
+// runSetupKubo shows the changes of Kubo's config recommended for hosting
+// episodes, and applies them with --apply. It returns the exit code.
+func runSetupKubo(args []string) int {
+	flags := flag.NewFlagSet("setup-kubo", flag.ExitOnError)
+
+	apiAddress := flags.String(
+		"api-address",
+		"/ip4/127.0.0.1/tcp/5001",
+		"address of the IPFS API",
+	)
+	timeout := flags.Duration(
+		"timeout",
+		time.Minute,
+		"Timeout of the requests to Kubo",
+	)
+	storageMax := byteSize(0)
+	flags.Var(
+		&storageMax,
+		"storage-max",
+		"Datastore.StorageMax to set, e.g. 500GB. Defaults to 90% of the space the repo can grow to, if StorageMax is less",
+	)
+	acceleratedDHT := flags.Bool(
+		"accelerated-dht",
+		true,
+		"Enable Routing.AcceleratedDHTClient, which reprovides a lot of content much faster, but uses more memory and connections",
+	)
+	apply := flags.Bool(
+		"apply",
+		false,
+		"Apply the changes. Without it, they're only shown",
+	)
+	flags.Parse(args)
+
+	err := setupKubo(*apiAddress, *timeout, int64(storageMax), *acceleratedDHT, *apply)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "setup-kubo failed:", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func setupKubo(address string, timeout time.Duration, storageMax int64, acceleratedDHT bool, apply bool) error {
+	client, err := newKuboClient(address)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	version, err := client.DetectVersion(ctx)
+	if errors.Is(err, kubo.ErrUnsupportedVersion) {
+		fmt.Printf("Kubo %s is older than %s, and the updater refuses to run with it. Upgrade Kubo first.\n\n", version, kubo.MinVersion)
+	} else if err != nil {
+		return fmt.Errorf("detecting version failed: %w", err)
+	}
+
+	if !client.PinNames() {
+		fmt.Printf(
+			"Kubo %s doesn't keep the names of the pins, like show/episode. Upgrade to 0.26 or later to see them with ipfs pin ls --names.\n\n",
+			version,
+		)
+	}
+
+	sys, err := client.DiagSys(ctx)
+	if err != nil {
+		return fmt.Errorf("getting node info failed: %w", err)
+	}
+
+	settings, err := recommendedSettings(ctx, client, sys, storageMax, acceleratedDHT)
+	if err != nil {
+		return err
+	}
+
+	if len(settings) == 0 {
+		fmt.Println("Kubo's config is already set up for hosting episodes.")
+
+		return nil
+	}
+
+	for _, setting := range settings {
+		current := "(not set)"
+		if setting.current != nil {
+			current = string(setting.current)
+		}
+
+		value, err := json.Marshal(setting.value)
+		if err != nil {
+			return fmt.Errorf("encoding %s failed: %w", setting.key, err)
+		}
+
+		fmt.Printf("%s\n  - %s\n  + %s\n  %s\n\n", setting.key, current, value, setting.reason)
+	}
+
+	if !apply {
+		fmt.Println("Run again with --apply to apply the changes.")
+
+		return nil
+	}
+
+	for _, setting := range settings {
+		err := client.ConfigSet(ctx, setting.key, setting.value)
+		if err != nil {
+			return fmt.Errorf("setting %s failed: %w", setting.key, err)
+		}
+	}
+
+	fmt.Printf("Applied %d changes. Restart Kubo for them to take effect.\n", len(settings))
+
+	return nil
+}
+
+// recommendedSettings are the changes of Kubo's config for hosting
+// episodes. Limits which were raised above the recommendation are left
+// alone.
+func recommendedSettings(
+	ctx context.Context,
+	client *kubo.Client,
+	sys *kubo.DiagSysResponse,
+	storageMax int64,
+	acceleratedDHT bool,
+) ([]kuboSetting, error) {
+	var settings []kuboSetting
+
+	current := func(key string) (json.RawMessage, error) {
+		value, err := client.ConfigGet(ctx, key)
+		if errors.Is(err, kubo.ErrConfigNotSet) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting %s failed: %w", key, err)
+		}
+
+		return value, nil
+	}
+
+	repo, err := client.RepoStat(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting repo stats failed: %w", err)
+	}
+
+	storageMaxGB := storageMax / 1e9
+	if storageMax == 0 {
+		storageMaxGB = (int64(repo.RepoSize) + sys.DiskInfo.FreeSpace) * 9 / 10 / 1e9
+		if storageMaxGB < int64(repo.StorageMax)/1e9 {
+			storageMaxGB = 0
+		}
+	}
+
+	if storageMaxGB != 0 && storageMaxGB != int64(repo.StorageMax)/1e9 {
+		value, err := current("Datastore.StorageMax")
+		if err != nil {
+			return nil, err
+		}
+
+		settings = append(settings, kuboSetting{
+			key:     "Datastore.StorageMax",
+			current: value,
+			value:   fmt.Sprintf("%dGB", storageMaxGB),
+			reason:  "The updater declines jobs when the repo is full, and Kubo's default of 10GB only fits a few episodes.",
+		})
+	}
+
+	limits := []struct {
+		key   string
+		value int
+	}{
+		{"Swarm.ConnMgr.LowWater", connMgrLowWater},
+		{"Swarm.ConnMgr.HighWater", connMgrHighWater},
+	}
+
+	for _, limit := range limits {
+		value, err := current(limit.key)
+		if err != nil {
+			return nil, err
+		}
+
+		var n int
+		if value != nil {
+			_ = json.Unmarshal(value, &n)
+		}
+
+		if n >= limit.value {
+			continue
+		}
+
+		settings = append(settings, kuboSetting{
+			key:     limit.key,
+			current: value,
+			value:   limit.value,
+			reason:  "Keeps the connections to the peers fetching episodes, instead of trimming them.",
+		})
+	}
+
+	if acceleratedDHT {
+		// Kubo before 0.21 has it under Experimental.
+		key := client.AcceleratedDHTClientKey()
+
+		value, err := current(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if string(value) != "true" {
+			settings = append(settings, kuboSetting{
+				key:     key,
+				current: value,
+				value:   true,
+				reason:  "Announces all the pinned episodes in one sweep, so the reprovides keep up with a large repo.",
+			})
+		}
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Set when building, with
+// -ldflags "-X main.version=v1.0.0 -X main.commit=abc123 -X main.date=2024-01-01T00:00:00Z".
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+type buildInfo struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+}
+
+// getBuildInfo is the version info set with ldflags. The commit and date
+// fall back to the VCS info embedded by go build.
+func getBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+	}
+
+	goInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, setting := range goInfo.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.Date == "":
+			info.Date = setting.Value
+		}
+	}
+
+	return info
+}
+
+// userAgent identifies the build to the work server.
+func (b buildInfo) userAgent() string {
+	commit := b.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+
+	if commit == "" {
+		commit = "unknown"
+	}
+
+	return fmt.Sprintf("ipfspodcasting-updater/%s (%s; %s)", b.Version, commit, b.GoVersion)
+}
+
+// runVersion prints the build info.
+func runVersion() int {
+	info := getBuildInfo()
+
+	fmt.Println("version:", info.Version)
+	fmt.Println("commit:", info.Commit)
+	fmt.Println("date:", info.Date)
+	fmt.Println("go:", info.GoVersion)
+
+	return 0
+}
+
+// semver is a parsed release version, like v1.2.3 or 1.2.3-rc.1.
+type semver struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+// parseSemver parses a version, with or without the v prefix of the release
+// tags. Build metadata, after a +, is ignored.
+func parseSemver(s string) (semver, error) {
+	s, _, _ = strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	s, prerelease, _ := strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, fmt.Errorf("%q is not a major.minor.patch version", s)
+	}
+
+	numbers := make([]int, len(parts))
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, fmt.Errorf("%q is not a major.minor.patch version", s)
+		}
+
+		numbers[i] = n
+	}
+
+	return semver{
+		major:      numbers[0],
+		minor:      numbers[1],
+		patch:      numbers[2],
+		prerelease: prerelease,
+	}, nil
+}
+
+// newerThan is true when v is a later version than other. A pre-release is
+// older than its release, and pre-releases are compared as strings.
+func (v semver) newerThan(other semver) bool {
+	switch {
+	case v.major != other.major:
+		return v.major > other.major
+	case v.minor != other.minor:
+		return v.minor > other.minor
+	case v.patch != other.patch:
+		return v.patch > other.patch
+	case v.prerelease == "" || other.prerelease == "":
+		return v.prerelease == "" && other.prerelease != ""
+	default:
+		return v.prerelease > other.prerelease
+	}
+}
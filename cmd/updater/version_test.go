@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestSemverNewerThan(t *testing.T) {
+	tests := []struct {
+		latest  string
+		current string
+		newer   bool
+	}{
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "1.2.3", false},
+		{"1.2.3", "v1.2.3", false},
+		{"v1.2.4", "v1.2.3", true},
+		{"v1.3.0", "v1.2.9", true},
+		{"v2.0.0", "v1.9.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.2", "v1.2.3", false},
+		{"v1.2.3", "v1.2.3-rc.1", true},
+		{"v1.2.3-rc.1", "v1.2.3", false},
+		{"v1.2.3-rc.2", "v1.2.3-rc.1", true},
+		{"v1.2.3+build.5", "v1.2.3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.latest+" "+tt.current, func(t *testing.T) {
+			latest, err := parseSemver(tt.latest)
+			if err != nil {
+				t.Fatalf("parsing %s failed: %v", tt.latest, err)
+			}
+
+			current, err := parseSemver(tt.current)
+			if err != nil {
+				t.Fatalf("parsing %s failed: %v", tt.current, err)
+			}
+
+			newer := latest.newerThan(current)
+			if newer != tt.newer {
+				t.Errorf("%s newer than %s: got %t, want %t", tt.latest, tt.current, newer, tt.newer)
+			}
+		})
+	}
+}
+
+func TestParseSemverInvalid(t *testing.T) {
+	for _, version := range []string{"dev", "", "v1.2", "v1.2.3.4", "v1.x.3", "v-1.2.3"} {
+		_, err := parseSemver(version)
+		if err == nil {
+			t.Errorf("parsing %q succeeded, want an error", version)
+		}
+	}
+}
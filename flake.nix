@@ -40,6 +40,10 @@
 
       perSystem = { config, pkgs, system, ... }: let
         inherit (gitignore.lib) gitignoreSource;
+
+        # The release tag, without the v. It's bumped with each release, so
+        # the packaged updater knows which releases are newer.
+        version = "0.1.0";
       in {
         # Attrs for easyOverlay
         overlayAttrs = {
@@ -49,17 +53,21 @@
 
         packages = {
           ipfspodcastingUpdater = pkgs.buildGoModule {
-            name = "ipfspodcasting-updater";
+            pname = "ipfspodcasting-updater";
+            inherit version;
 
             src = gitignoreSource ./.;
             subPackages = [ "cmd/updater" ];
 
-            vendorHash = "sha256-chkbPYWWCPnLQ7kWdCtiwf8opRBsN/fp41Qw28vi6zU=";
+            vendorHash = "sha256-1Aq+4YfvnKjP1Jip7U+IZz2ZBwuMAi+gBhflzxJjQ1g=";
 
             ldflags = [
               "-s"
               "-w"
               "-extldflags -static"
+              "-X main.version=v${version}"
+              "-X main.commit=${self.rev or self.dirtyRev or "unknown"}"
+              "-X main.date=${self.lastModifiedDate}"
             ];
           };
         };
@@ -90,16 +98,31 @@
             };
 
             email = mkOption {
-              type = types.str;
+              type = types.nullOr types.str;
+              default = null;
               description = "Email address for managing the node via https://ipfspodcasting.net/manage";
             };
 
+            emailFile = mkOption {
+              type = types.nullOr types.path;
+              default = null;
+              example = "/run/secrets/ipfspodcasting-email";
+              description = "File containing the email address, instead of setting email, so it doesn't end up in the Nix store";
+            };
+
             apiAddress = mkOption {
               type = types.str;
               default = "/ip4/127.0.0.1/tcp/5001";
               description = "API address for Kubo";
             };
 
+            clusterApiAddress = mkOption {
+              type = types.nullOr types.str;
+              default = null;
+              example = "http://127.0.0.1:9094";
+              description = "URL of an ipfs-cluster REST API. When set, pins go through the cluster instead of only Kubo";
+            };
+
             metricsAddress = mkOption {
               type = types.str;
               default = "0.0.0.0";
@@ -136,6 +159,13 @@
               description = "System group to be used for Kubo and the IPFS Podcasting Updater";
             };
 
+            maxStorage = mkOption {
+              type = types.nullOr types.str;
+              default = null;
+              example = "500GB";
+              description = "Storage quota for hosted episodes, enforced by the updater. Jobs which would go over the quota are declined";
+            };
+
             kuboSettings = mkOption {
               type = (pkgs.formats.json {}).type;
               default = {};
@@ -144,6 +174,13 @@
           };
 
           config = mkIf cfg.enable {
+            assertions = [
+              {
+                assertion = (cfg.email == null) != (cfg.emailFile == null);
+                message = "services.ipfspodcasting: exactly one of email and emailFile must be set";
+              }
+            ];
+
             networking.firewall = mkIf cfg.openFirewall {
               allowedTCPPorts = [
                 4001
@@ -176,9 +213,17 @@
               serviceConfig = let
                 args = [
                   "--api-address='${cfg.apiAddress}'"
-                  "--email='${cfg.email}'"
                   "--http-timeout='${cfg.httpTimeout}'"
                   "--metrics-address='${cfg.metricsAddress}:${toString cfg.metricsPort}'"
+                  "--state-dir=/var/lib/ipfspodcasting"
+                ] ++ optionals (cfg.email != null) [
+                  "--email='${cfg.email}'"
+                ] ++ optionals (cfg.emailFile != null) [
+                  "--email-file='${cfg.emailFile}'"
+                ] ++ optionals (cfg.clusterApiAddress != null) [
+                  "--cluster-api-address='${cfg.clusterApiAddress}'"
+                ] ++ optionals (cfg.maxStorage != null) [
+                  "--max-storage='${cfg.maxStorage}'"
                 ];
               in {
                 ExecStart = "${pkgs.ipfspodcastingUpdater}/bin/updater ${concatStringsSep " " args}";
@@ -186,6 +231,8 @@
                 User = cfg.user;
                 Group = cfg.group;
 
+                StateDirectory = "ipfspodcasting";
+
                 Restart = "on-failure";
               };
             };
@@ -0,0 +1,357 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Episode is an episode hosted by the node.
+type Episode struct {
+	// Hash of the pinned root. For downloads this is the directory wrapping
+	// the file.
+	Hash string `json:"hash"`
+	// File is the hash of the episode file.
+	File string `json:"file,omitempty"`
+	// Size of the episode file in bytes.
+	Size int `json:"size"`
+	// SHA256 is the hex encoded SHA-256 of the downloaded file. Empty if
+	// the node didn't download it, like when it was pinned.
+	SHA256 string `json:"sha256,omitempty"`
+
+	Show     string `json:"show,omitempty"`
+	Episode  string `json:"episode,omitempty"`
+	Download string `json:"download,omitempty"`
+
+	Added time.Time `json:"added"`
+	// Assigned is when the server last sent a job for the episode. Nil if
+	// it's only been assigned when it was added.
+	Assigned *time.Time `json:"assigned,omitempty"`
+	// Expires is when the episode is unpinned. Nil if it's hosted until it's
+	// deleted.
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// MirrorKey is the key of the episode file in the S3 mirror. Empty if
+	// it wasn't mirrored.
+	MirrorKey string `json:"mirror_key,omitempty"`
+
+	// Replicated is when the episode was uploaded to the pinning service.
+	// Nil if it wasn't replicated.
+	Replicated *time.Time `json:"replicated,omitempty"`
+
+	// Provided is when the episode was last announced to the DHT. Nil if it
+	// wasn't, or it failed.
+	Provided *time.Time `json:"provided,omitempty"`
+
+	// Archive is the Filecoin archival of the episode. Nil if it wasn't
+	// archived.
+	Archive *Archive `json:"archive,omitempty"`
+}
+
+// LastAssigned is when the server last sent a job for the episode.
+func (e Episode) LastAssigned() time.Time {
+	if e.Assigned != nil {
+		return *e.Assigned
+	}
+
+	return e.Added
+}
+
+// Archive tracks the Filecoin deals of an episode.
+type Archive struct {
+	// ContentID is the ID of the episode in the onboarding API.
+	ContentID int64 `json:"content_id"`
+	// State of the deals, like "queued", "proposed", "active", or
+	// "failed".
+	State string `json:"state"`
+	// Deals are the IDs of the deals which are on chain.
+	Deals []int64 `json:"deals,omitempty"`
+	// Updated is when the state was last checked.
+	Updated time.Time `json:"updated"`
+}
+
+// Catalog tracks the episodes hosted by the node. It's saved as a JSON file
+// after every change.
+type Catalog struct {
+	mu       sync.Mutex
+	path     string
+	episodes map[string]Episode
+}
+
+// Open loads the catalog saved at path, or creates an empty one if the file
+// doesn't exist. An empty path creates a catalog which is only kept in
+// memory.
+func Open(path string) (*Catalog, error) {
+	c := &Catalog{
+		path:     path,
+		episodes: map[string]Episode{},
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening catalog failed: %w", err)
+	}
+	defer f.Close()
+
+	var episodes []Episode
+
+	err = json.NewDecoder(f).Decode(&episodes)
+	if err != nil {
+		return nil, fmt.Errorf("decoding catalog failed: %w", err)
+	}
+
+	for _, episode := range episodes {
+		c.episodes[episode.Hash] = episode
+	}
+
+	return c, nil
+}
+
+// save writes the catalog to a temporary file, which is synced before it's
+// renamed over the previous catalog, so a crash, or a power cut, doesn't
+// leave a partial, or empty, file behind.
+//
+// c.mu must be held.
+func (c *Catalog) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(c.path), 0o755)
+	if err != nil {
+		return fmt.Errorf("creating catalog directory failed: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(c.path), ".catalog-*.json")
+	if err != nil {
+		return fmt.Errorf("creating temp file failed: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+
+	err = encoder.Encode(c.episodesLocked())
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("encoding catalog failed: %w", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("syncing catalog failed: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("closing temp file failed: %w", err)
+	}
+
+	err = os.Rename(f.Name(), c.path)
+	if err != nil {
+		return fmt.Errorf("replacing catalog failed: %w", err)
+	}
+
+	return syncDir(filepath.Dir(c.path))
+}
+
+// syncDir syncs the directory, so a file renamed into it is still there
+// after a power cut.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening directory failed: %w", err)
+	}
+	defer dir.Close()
+
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing directory failed: %w", err)
+	}
+
+	return nil
+}
+
+// Add adds, or replaces, the episode with the same Hash.
+func (c *Catalog) Add(episode Episode) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if episode.Added.IsZero() {
+		episode.Added = time.Now()
+	}
+
+	c.episodes[episode.Hash] = episode
+
+	return c.save()
+}
+
+// SetArchive sets the archival of the episode with hash. Unknown hashes are
+// not an error, the episode could have been removed in the meantime.
+func (c *Catalog) SetArchive(hash string, archive Archive) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	episode, ok := c.episodes[hash]
+	if !ok {
+		return nil
+	}
+
+	episode.Archive = &archive
+	c.episodes[hash] = episode
+
+	return c.save()
+}
+
+// SetProvided marks the episode with hash as announced to the DHT at t.
+// Unknown hashes are not an error.
+func (c *Catalog) SetProvided(hash string, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	episode, ok := c.episodes[hash]
+	if !ok {
+		return nil
+	}
+
+	episode.Provided = &t
+	c.episodes[hash] = episode
+
+	return c.save()
+}
+
+// SetReplicated marks the episode with hash as replicated at t. Unknown
+// hashes are not an error.
+func (c *Catalog) SetReplicated(hash string, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	episode, ok := c.episodes[hash]
+	if !ok {
+		return nil
+	}
+
+	episode.Replicated = &t
+	c.episodes[hash] = episode
+
+	return c.save()
+}
+
+// Remove removes the episode with hash. Unknown hashes are not an error.
+func (c *Catalog) Remove(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.episodes[hash]
+	if !ok {
+		return nil
+	}
+
+	delete(c.episodes, hash)
+
+	return c.save()
+}
+
+// Get returns the episode with hash.
+func (c *Catalog) Get(hash string) (Episode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	episode, ok := c.episodes[hash]
+
+	return episode, ok
+}
+
+// GetDownload returns the newest episode which was downloaded from
+// download.
+func (c *Catalog) GetDownload(download string) (Episode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		newest Episode
+		found  bool
+	)
+
+	for _, episode := range c.episodes {
+		if episode.Download != download {
+			continue
+		}
+
+		if !found || episode.Added.After(newest.Added) {
+			newest = episode
+			found = true
+		}
+	}
+
+	return newest, found
+}
+
+// Expired returns the episodes which expire before now, oldest first.
+func (c *Catalog) Expired(now time.Time) []Episode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expired []Episode
+
+	for _, episode := range c.episodesLocked() {
+		if episode.Expires != nil && episode.Expires.Before(now) {
+			expired = append(expired, episode)
+		}
+	}
+
+	return expired
+}
+
+// Episodes returns all the episodes, oldest first.
+func (c *Catalog) Episodes() []Episode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.episodesLocked()
+}
+
+func (c *Catalog) episodesLocked() []Episode {
+	episodes := make([]Episode, 0, len(c.episodes))
+
+	for _, episode := range c.episodes {
+		episodes = append(episodes, episode)
+	}
+
+	slices.SortFunc(episodes, func(a, b Episode) int {
+		if n := a.Added.Compare(b.Added); n != 0 {
+			return n
+		}
+
+		return strings.Compare(a.Hash, b.Hash)
+	})
+
+	return episodes
+}
+
+// Size is the total size of all the episodes in bytes.
+func (c *Catalog) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, episode := range c.episodes {
+		total += episode.Size
+	}
+
+	return total
+}
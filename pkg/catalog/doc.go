@@ -0,0 +1,8 @@
+// Package catalog tracks the episodes hosted by an IPFS Podcasting node,
+// with their hashes, sizes, and where they came from.
+//
+// The Catalog is saved as a JSON file after every change, so it survives
+// restarts, and the updater can tell which pins are its episodes. Besides the
+// episode itself, it keeps when the episode was last assigned, announced to
+// the DHT, replicated, and archived to Filecoin.
+package catalog
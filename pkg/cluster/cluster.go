@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client pins and unpins through the ipfs-cluster REST API.
+type Client struct {
+	httpClient *http.Client
+	apiURL     *url.URL
+	username   string
+	password   string
+}
+
+// New creates a Client for the REST API at apiAddress, e.g.
+// http://127.0.0.1:9094. basicAuth is optional, in the form user:password.
+func New(httpClient *http.Client, apiAddress string, basicAuth string) (*Client, error) {
+	apiURL, err := url.Parse(apiAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cluster api address failed: %w", err)
+	}
+
+	c := &Client{
+		httpClient: httpClient,
+		apiURL:     apiURL,
+	}
+
+	if basicAuth != "" {
+		username, password, ok := strings.Cut(basicAuth, ":")
+		if !ok {
+			return nil, fmt.Errorf("cluster basic auth must be in the form user:password")
+		}
+
+		c.username = username
+		c.password = password
+	}
+
+	return c, nil
+}
+
+type clusterError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (c *Client) request(ctx context.Context, method string, hash string, query url.Values) (*http.Response, error) {
+	reqURL := c.apiURL.JoinPath("pins", "ipfs", hash)
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		reqURL.String(),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return resp, nil
+}
+
+func decodeError(resp *http.Response) error {
+	var clusterErr clusterError
+
+	err := json.NewDecoder(resp.Body).Decode(&clusterErr)
+	if err != nil || clusterErr.Message == "" {
+		return fmt.Errorf("response not OK: %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("response not OK: %d: %s", resp.StatusCode, clusterErr.Message)
+}
+
+// Pin asks the cluster to pin hash on its peers, with an optional name. The
+// cluster pins asynchronously, so this returns before the content is pinned.
+func (c *Client) Pin(ctx context.Context, hash string, name string) error {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	resp, err := c.request(ctx, http.MethodPost, hash, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return decodeError(resp)
+	}
+
+	return nil
+}
+
+// Unpin removes the pin of hash from the cluster. Hashes which are not
+// pinned are not an error.
+func (c *Client) Unpin(ctx context.Context, hash string) error {
+	resp, err := c.request(ctx, http.MethodDelete, hash, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Like with Kubo, we sometimes get delete requests for files the
+	// cluster doesn't have pinned. That's OK.
+	if resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusAccepted &&
+		resp.StatusCode != http.StatusNotFound {
+		return decodeError(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,6 @@
+// Package cluster is a client for pinning through the ipfs-cluster REST API.
+//
+// Client implements updater.Pinner, so the episodes added by an updater are
+// pinned on the peers of the cluster, with its replication factor, instead
+// of only on the Kubo node which added them.
+package cluster
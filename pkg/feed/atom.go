@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"strings"
+)
+
+type atomFeed struct {
+	ItunesImage    itunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesAuthor   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	ItunesExplicit string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+
+	Title    string         `xml:"title"`
+	Subtitle string         `xml:"subtitle"`
+	Links    []atomLink     `xml:"link"`
+	Logo     string         `xml:"logo"`
+	Icon     string         `xml:"icon"`
+	Author   atomPerson     `xml:"author"`
+	Category []atomCategory `xml:"category"`
+	Entries  []atomEntry    `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomEntry struct {
+	ItunesImage    itunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesDuration string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ItunesSeason   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ItunesEpisode  string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ItunesExplicit string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// link is the href of the first link with the rel. Links without a rel are
+// alternate links.
+func link(links []atomLink, rel string) *atomLink {
+	for _, l := range links {
+		if l.Rel == rel || (l.Rel == "" && rel == "alternate") {
+			return &l
+		}
+	}
+
+	return nil
+}
+
+func (a atomFeed) feed() *Feed {
+	f := &Feed{
+		Title:       strings.TrimSpace(a.Title),
+		Description: strings.TrimSpace(a.Subtitle),
+		Image:       a.ItunesImage.Href,
+		Author:      strings.TrimSpace(a.ItunesAuthor),
+		Explicit:    parseExplicit(a.ItunesExplicit),
+		Items:       make([]Item, 0, len(a.Entries)),
+	}
+
+	if alternate := link(a.Links, "alternate"); alternate != nil {
+		f.Link = alternate.Href
+	}
+	if f.Image == "" {
+		f.Image = strings.TrimSpace(a.Logo)
+	}
+	if f.Image == "" {
+		f.Image = strings.TrimSpace(a.Icon)
+	}
+	if f.Author == "" {
+		f.Author = strings.TrimSpace(a.Author.Name)
+	}
+
+	for _, category := range a.Category {
+		f.Categories = append(f.Categories, category.Term)
+	}
+
+	for _, entry := range a.Entries {
+		f.Items = append(f.Items, entry.item())
+	}
+
+	return f
+}
+
+func (e atomEntry) item() Item {
+	item := Item{
+		Title:       strings.TrimSpace(e.Title),
+		GUID:        strings.TrimSpace(e.ID),
+		Description: strings.TrimSpace(e.Summary),
+		Published:   parseDate(e.Published),
+		Image:       e.ItunesImage.Href,
+		Duration:    parseDuration(e.ItunesDuration),
+		Season:      atoi(e.ItunesSeason),
+		Episode:     atoi(e.ItunesEpisode),
+		Explicit:    parseExplicit(e.ItunesExplicit),
+	}
+
+	if item.Description == "" {
+		item.Description = strings.TrimSpace(e.Content)
+	}
+	if item.Published.IsZero() {
+		item.Published = parseDate(e.Updated)
+	}
+
+	if alternate := link(e.Links, "alternate"); alternate != nil {
+		item.Link = alternate.Href
+	}
+
+	if enclosure := link(e.Links, "enclosure"); enclosure != nil {
+		item.Enclosure = &Enclosure{
+			URL:    strings.TrimSpace(enclosure.Href),
+			Type:   enclosure.Type,
+			Length: atoi(enclosure.Length),
+		}
+	}
+
+	return item
+}
@@ -0,0 +1,9 @@
+// Package feed parses podcast feeds, in RSS or Atom, with the iTunes and
+// Podcasting 2.0 namespaces.
+//
+//	f, err := feed.Fetch(ctx, http.DefaultClient, "https://example.com/podcast.rss")
+//
+//	for _, item := range f.Items {
+//		fmt.Println(item.Title, item.Enclosure.URL)
+//	}
+package feed
@@ -0,0 +1,61 @@
+package feed_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/angaz/ipfspodcasting/pkg/feed"
+)
+
+func ExampleParse() {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Example Show</title>
+    <itunes:author>Example Author</itunes:author>
+    <item>
+      <title>Episode 1</title>
+      <guid isPermaLink="false">example-show-1</guid>
+      <enclosure url="https://example.com/episode-1.mp3" type="audio/mpeg" length="1048576" />
+      <itunes:duration>01:02:03</itunes:duration>
+    </item>
+  </channel>
+</rss>`
+
+	f, err := feed.Parse(strings.NewReader(rss))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(f.Title, "by", f.Author)
+
+	for _, item := range f.Items {
+		fmt.Println(item.Title, item.GUID, item.Duration)
+		fmt.Println(item.Enclosure.URL, item.Enclosure.Length)
+	}
+
+	// Output:
+	// Example Show by Example Author
+	// Episode 1 example-show-1 1h2m3s
+	// https://example.com/episode-1.mp3 1048576
+}
+
+func ExampleAlternateEnclosure_Snippet() {
+	enclosure := feed.AlternateEnclosure{
+		Type:   "audio/mpeg",
+		Length: 1048576,
+		Sources: []feed.Source{
+			{URI: "ipfs://bafkreihcofdgahmfypswbh6hrmjixr5co3x4znyp7mtlty3opsm4okqzdq"},
+			{URI: "https://example.com/episode-1.mp3"},
+		},
+	}
+
+	fmt.Print(enclosure.Snippet())
+
+	// Output:
+	// <podcast:alternateEnclosure type="audio/mpeg" length="1048576">
+	//   <podcast:source uri="ipfs://bafkreihcofdgahmfypswbh6hrmjixr5co3x4znyp7mtlty3opsm4okqzdq" />
+	//   <podcast:source uri="https://example.com/episode-1.mp3" />
+	// </podcast:alternateEnclosure>
+}
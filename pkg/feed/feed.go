@@ -0,0 +1,256 @@
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat is returned for documents which are neither RSS nor Atom.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Feed is a podcast.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string
+	Image       string
+	Author      string
+	Explicit    bool
+	Categories  []string
+	Items       []Item
+}
+
+// Item is an episode of a podcast.
+type Item struct {
+	Title       string
+	GUID        string
+	Link        string
+	Description string
+	Published   time.Time
+	Image       string
+	Duration    time.Duration
+	Season      int
+	Episode     int
+	Explicit    bool
+
+	// Enclosure is the media file of the episode. Nil if the item doesn't
+	// have one.
+	Enclosure *Enclosure
+	// AlternateEnclosures are other versions of the media file, or the
+	// same file at other places, like IPFS, from the podcast namespace.
+	AlternateEnclosures []AlternateEnclosure
+}
+
+// Enclosure is a media file.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int
+}
+
+// AlternateEnclosure is a podcast:alternateEnclosure.
+type AlternateEnclosure struct {
+	Type    string
+	Length  int
+	Bitrate float64
+	Title   string
+	Default bool
+	Sources []Source
+	// Integrity is the SRI hash, or PGP signature, of the file.
+	Integrity *Integrity
+}
+
+// Source is a podcast:source, a URI the file can be fetched from, like an
+// https:// or ipfs:// URI.
+type Source struct {
+	URI         string
+	ContentType string
+}
+
+// Integrity is a podcast:integrity.
+type Integrity struct {
+	// Type is "sri" or "pgp-signature".
+	Type  string
+	Value string
+}
+
+// Fetch downloads and parses the feed at url.
+func Fetch(ctx context.Context, client *http.Client, url string) (*Feed, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed not OK: %d", resp.StatusCode)
+	}
+
+	return Parse(resp.Body)
+}
+
+// Parse parses an RSS or Atom feed.
+func Parse(r io.Reader) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	// Feeds in the wild are often not valid XML, like with undeclared
+	// entities, or HTML in descriptions.
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charsetReader
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrUnknownFormat
+			}
+
+			return nil, fmt.Errorf("decoding feed failed: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "rss":
+			var doc rssDocument
+
+			err := decoder.DecodeElement(&doc, &start)
+			if err != nil {
+				return nil, fmt.Errorf("decoding rss failed: %w", err)
+			}
+
+			return doc.Channel.feed(), nil
+		case "feed":
+			var doc atomFeed
+
+			err := decoder.DecodeElement(&doc, &start)
+			if err != nil {
+				return nil, fmt.Errorf("decoding atom failed: %w", err)
+			}
+
+			return doc.feed(), nil
+		default:
+			return nil, ErrUnknownFormat
+		}
+	}
+}
+
+// charsetReader accepts ISO-8859-1, which some older feeds use, in addition
+// to UTF-8, which encoding/xml handles itself.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "us-ascii":
+		return &latin1Reader{r: input}, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.buf) == 0 {
+		in := make([]byte, max(len(p)/2, 1))
+
+		n, err := l.r.Read(in)
+
+		for _, b := range in[:n] {
+			l.buf = append(l.buf, string(rune(b))...)
+		}
+
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+
+	return n, nil
+}
+
+// parseExplicit parses an itunes:explicit, which is "true", "yes", or
+// "explicit" when the content is explicit.
+func parseExplicit(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "yes", "explicit":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseDuration parses an itunes:duration, which is a number of seconds, or
+// in the form MM:SS or HH:MM:SS.
+func parseDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	var total float64
+
+	for _, part := range strings.Split(s, ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+
+		total = total*60 + value
+	}
+
+	return time.Duration(total * float64(time.Second))
+}
+
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, _2 Jan 2006 15:04:05 -0700",
+	"Mon, _2 Jan 2006 15:04:05 MST",
+	"Mon, _2 Jan 2006 15:04 -0700",
+	"_2 Jan 2006 15:04:05 -0700",
+	"_2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses the dates of RSS and Atom, and the common mistakes in
+// them. It's the zero time if none of the formats match.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+
+	for _, format := range dateFormats {
+		t, err := time.Parse(format, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+
+	return i
+}
@@ -0,0 +1,240 @@
+package feed
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want *Feed
+	}{
+		{
+			name: "rss",
+			file: "rss.xml",
+			want: &Feed{
+				Title:       "The Decentralized Hour",
+				Description: "Conversations about <b>peer-to-peer</b> systems, every other week.",
+				Link:        "https://decentralizedhour.example.com/",
+				Image:       "https://decentralizedhour.example.com/cover.jpg",
+				Author:      "Jo Example & Friends",
+				Explicit:    false,
+				Categories:  []string{"Technology", "Tech News", "Education"},
+				Items: []Item{
+					{
+						Title:       "Episode 42: Pinning at Scale",
+						GUID:        "dh-episode-42",
+						Link:        "https://decentralizedhour.example.com/42",
+						Description: "<p>How the nodes of a community keep a <em>whole</em> catalog online.</p>",
+						Published:   time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC),
+						Image:       "https://decentralizedhour.example.com/42.jpg",
+						Duration:    time.Hour + 14*time.Minute + 22*time.Second,
+						Season:      3,
+						Episode:     42,
+						Explicit:    true,
+						Enclosure: &Enclosure{
+							URL:    "https://media.example.com/dh/episode-42.mp3",
+							Type:   "audio/mpeg",
+							Length: 53687091,
+						},
+						AlternateEnclosures: []AlternateEnclosure{
+							{
+								Type:    "audio/opus",
+								Length:  21474836,
+								Bitrate: 48000.5,
+								Title:   "Opus",
+								Default: true,
+								Sources: []Source{
+									{URI: "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"},
+									{URI: "https://media.example.com/dh/episode-42.opus", ContentType: "audio/opus"},
+								},
+								Integrity: &Integrity{
+									Type:  "sri",
+									Value: "sha384-ExVqijgYHm15PqQqdXfW95x+Rs6C+d6E/ICxyQOeFevnxNLR/wtJNrNYTjIysUBo",
+								},
+							},
+							{
+								Type:   "audio/aac",
+								Length: 32212254,
+								Sources: []Source{
+									{URI: "https://media.example.com/dh/episode-42.aac"},
+								},
+							},
+						},
+					},
+					{
+						// Without a title or a guid, the itunes:title, and
+						// the URL of the enclosure are used.
+						Title:       "Episode 41: Content Addressing",
+						GUID:        "https://media.example.com/dh/episode-41.mp3",
+						Description: "CIDs, multihashes, and why URLs break.",
+						Published:   time.Date(2024, 2, 20, 14, 30, 0, 0, time.UTC),
+						Duration:    time.Hour + 2*time.Minute + 5*time.Second,
+						Season:      3,
+						Episode:     41,
+						Enclosure: &Enclosure{
+							URL:    "https://media.example.com/dh/episode-41.mp3",
+							Type:   "audio/mpeg",
+							Length: 48234496,
+						},
+					},
+					{
+						Title:       "Trailer",
+						GUID:        "https://decentralizedhour.example.com/trailer",
+						Description: "Coming soon.",
+						Published:   time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC),
+						Duration:    2*time.Minute + 5*time.Second,
+					},
+				},
+			},
+		},
+		{
+			name: "atom",
+			file: "atom.xml",
+			want: &Feed{
+				Title:       "Atomic Audio",
+				Description: "A podcast published as an Atom feed.",
+				Link:        "https://atomic.example.org/",
+				Image:       "https://atomic.example.org/logo.png",
+				Author:      "Sam Atom",
+				Explicit:    false,
+				Categories:  []string{"Science", "Physics"},
+				Items: []Item{
+					{
+						Title:       "Splitting the Atom",
+						GUID:        "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a",
+						Link:        "https://atomic.example.org/episodes/splitting",
+						Description: "What happens inside a reactor.",
+						Published:   time.Date(2024, 4, 2, 10, 0, 0, 0, time.UTC),
+						Image:       "https://atomic.example.org/episodes/splitting.jpg",
+						Duration:    45*time.Minute + 30*time.Second,
+						Season:      1,
+						Episode:     7,
+						Explicit:    true,
+						Enclosure: &Enclosure{
+							URL:    "https://cdn.example.org/atomic/splitting.m4a",
+							Type:   "audio/mp4",
+							Length: 73400320,
+						},
+					},
+					{
+						// Without a summary or a published date, the
+						// content, and the updated date are used.
+						Title:       "Half-lives",
+						GUID:        "urn:uuid:7a3c4a1e-9f2b-4e0e-bb2d-1c5e0f6a9d20",
+						Link:        "https://atomic.example.org/episodes/half-lives",
+						Description: "Why some things take forever to decay.",
+						Published:   time.Date(2024, 3, 19, 8, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		{
+			name: "latin1",
+			file: "latin1.xml",
+			want: &Feed{
+				Title:       "Café Crème",
+				Description: "Une émission en français.",
+				Link:        "http://cafe.example.fr/",
+				Image:       "http://cafe.example.fr/pochette.jpg",
+				Author:      "Renée",
+				Items: []Item{
+					{
+						Title:     "Épisode 1 : Les débuts",
+						GUID:      "cafe-1",
+						Published: time.Date(2023, 10, 7, 16, 0, 0, 0, time.UTC),
+						Duration:  30 * time.Minute,
+						Enclosure: &Enclosure{
+							URL:    "http://cafe.example.fr/episode-1.mp3",
+							Type:   "audio/mpeg",
+							Length: 1234567,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := Parse(f)
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+
+			if len(got.Items) != len(tt.want.Items) {
+				t.Fatalf("got %d items, want %d", len(got.Items), len(tt.want.Items))
+			}
+
+			// The dates are compared as instants, since their location
+			// depends on the local time zone.
+			for i := range got.Items {
+				if !got.Items[i].Published.Equal(tt.want.Items[i].Published) {
+					t.Errorf("item %d published %s, want %s", i, got.Items[i].Published, tt.want.Items[i].Published)
+				}
+
+				got.Items[i].Published = time.Time{}
+				tt.want.Items[i].Published = time.Time{}
+
+				if !reflect.DeepEqual(got.Items[i], tt.want.Items[i]) {
+					t.Errorf("item %d:\ngot  %+v\nwant %+v", i, got.Items[i], tt.want.Items[i])
+				}
+			}
+
+			got.Items = nil
+			tt.want.Items = nil
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("feed:\ngot  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	for _, doc := range []string{
+		"<!DOCTYPE html><html><body>Not Found</body></html>",
+		`<?xml version="1.0"?><opml version="2.0"></opml>`,
+		"",
+	} {
+		_, err := Parse(strings.NewReader(doc))
+		if !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("parsing %q: got %v, want %v", doc, err, ErrUnknownFormat)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"", 0},
+		{"90", 90 * time.Second},
+		{"90.5", 90*time.Second + 500*time.Millisecond},
+		{"02:05", 2*time.Minute + 5*time.Second},
+		{"1:02:05", time.Hour + 2*time.Minute + 5*time.Second},
+		{" 01:00:00 ", time.Hour},
+		{"1h", 0},
+	}
+
+	for _, tt := range tests {
+		got := parseDuration(tt.s)
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package feed
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The namespaced fields are before the fields without a namespace, because
+// encoding/xml gives an element to the first field which matches, and fields
+// without a namespace match elements in any namespace.
+
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	ItunesImage      itunesImage      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesAuthor     string           `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	ItunesExplicit   string           `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+	ItunesCategories []itunesCategory `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd category"`
+	AtomLinks        []atomLink       `xml:"http://www.w3.org/2005/Atom link"`
+
+	Title       string    `xml:"title"`
+	Description string    `xml:"description"`
+	Link        string    `xml:"link"`
+	Image       rssImage  `xml:"image"`
+	Items       []rssItem `xml:"item"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesCategory struct {
+	Text          string           `xml:"text,attr"`
+	Subcategories []itunesCategory `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd category"`
+}
+
+type rssImage struct {
+	URL string `xml:"url"`
+}
+
+type rssItem struct {
+	ItunesImage         itunesImage             `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	ItunesDuration      string                  `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ItunesSeason        string                  `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ItunesEpisode       string                  `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ItunesExplicit      string                  `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+	ItunesTitle         string                  `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	PodcastSeason       string                  `xml:"https://podcastindex.org/namespace/1.0 season"`
+	PodcastEpisode      string                  `xml:"https://podcastindex.org/namespace/1.0 episode"`
+	AlternateEnclosures []rssAlternateEnclosure `xml:"https://podcastindex.org/namespace/1.0 alternateEnclosure"`
+
+	Title       string        `xml:"title"`
+	GUID        string        `xml:"guid"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type rssAlternateEnclosure struct {
+	Type      string        `xml:"type,attr"`
+	Length    string        `xml:"length,attr"`
+	Bitrate   string        `xml:"bitrate,attr"`
+	Title     string        `xml:"title,attr"`
+	Default   string        `xml:"default,attr"`
+	Sources   []rssSource   `xml:"https://podcastindex.org/namespace/1.0 source"`
+	Integrity *rssIntegrity `xml:"https://podcastindex.org/namespace/1.0 integrity"`
+}
+
+type rssSource struct {
+	URI         string `xml:"uri,attr"`
+	ContentType string `xml:"contentType,attr"`
+}
+
+type rssIntegrity struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (c rssChannel) feed() *Feed {
+	f := &Feed{
+		Title:       strings.TrimSpace(c.Title),
+		Description: strings.TrimSpace(c.Description),
+		Link:        strings.TrimSpace(c.Link),
+		Image:       c.ItunesImage.Href,
+		Author:      strings.TrimSpace(c.ItunesAuthor),
+		Explicit:    parseExplicit(c.ItunesExplicit),
+		Items:       make([]Item, 0, len(c.Items)),
+	}
+
+	if f.Image == "" {
+		f.Image = strings.TrimSpace(c.Image.URL)
+	}
+
+	for _, category := range c.ItunesCategories {
+		f.Categories = append(f.Categories, category.Text)
+
+		for _, sub := range category.Subcategories {
+			f.Categories = append(f.Categories, sub.Text)
+		}
+	}
+
+	for _, item := range c.Items {
+		f.Items = append(f.Items, item.item())
+	}
+
+	return f
+}
+
+func (i rssItem) item() Item {
+	item := Item{
+		Title:       strings.TrimSpace(i.Title),
+		GUID:        strings.TrimSpace(i.GUID),
+		Link:        strings.TrimSpace(i.Link),
+		Description: strings.TrimSpace(i.Description),
+		Published:   parseDate(i.PubDate),
+		Image:       i.ItunesImage.Href,
+		Duration:    parseDuration(i.ItunesDuration),
+		Season:      atoi(i.ItunesSeason),
+		Episode:     atoi(i.ItunesEpisode),
+		Explicit:    parseExplicit(i.ItunesExplicit),
+	}
+
+	if item.Title == "" {
+		item.Title = strings.TrimSpace(i.ItunesTitle)
+	}
+	if item.Season == 0 {
+		item.Season = atoi(i.PodcastSeason)
+	}
+	if item.Episode == 0 {
+		item.Episode = atoi(i.PodcastEpisode)
+	}
+
+	if i.Enclosure != nil && i.Enclosure.URL != "" {
+		item.Enclosure = &Enclosure{
+			URL:    strings.TrimSpace(i.Enclosure.URL),
+			Type:   i.Enclosure.Type,
+			Length: atoi(i.Enclosure.Length),
+		}
+	}
+
+	if item.GUID == "" && item.Enclosure != nil {
+		item.GUID = item.Enclosure.URL
+	}
+
+	for _, alt := range i.AlternateEnclosures {
+		bitrate, _ := strconv.ParseFloat(alt.Bitrate, 64)
+
+		enclosure := AlternateEnclosure{
+			Type:    alt.Type,
+			Length:  atoi(alt.Length),
+			Bitrate: bitrate,
+			Title:   alt.Title,
+			Default: alt.Default == "true",
+		}
+
+		for _, source := range alt.Sources {
+			enclosure.Sources = append(enclosure.Sources, Source{
+				URI:         strings.TrimSpace(source.URI),
+				ContentType: source.ContentType,
+			})
+		}
+
+		if alt.Integrity != nil {
+			enclosure.Integrity = &Integrity{
+				Type:  alt.Integrity.Type,
+				Value: alt.Integrity.Value,
+			}
+		}
+
+		item.AlternateEnclosures = append(item.AlternateEnclosures, enclosure)
+	}
+
+	return item
+}
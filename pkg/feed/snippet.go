@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Snippet is the alternate enclosure as a podcast:alternateEnclosure
+// element, ready to paste into the item of a feed which declares the
+// podcast namespace.
+func (a AlternateEnclosure) Snippet() string {
+	sb := new(strings.Builder)
+
+	sb.WriteString("<podcast:alternateEnclosure")
+	writeAttr(sb, "type", a.Type)
+
+	if a.Length != 0 {
+		writeAttr(sb, "length", strconv.Itoa(a.Length))
+	}
+	if a.Bitrate != 0 {
+		writeAttr(sb, "bitrate", strconv.FormatFloat(a.Bitrate, 'f', -1, 64))
+	}
+	if a.Title != "" {
+		writeAttr(sb, "title", a.Title)
+	}
+	if a.Default {
+		writeAttr(sb, "default", "true")
+	}
+
+	sb.WriteString(">\n")
+
+	for _, source := range a.Sources {
+		sb.WriteString("  <podcast:source")
+		writeAttr(sb, "uri", source.URI)
+
+		if source.ContentType != "" {
+			writeAttr(sb, "contentType", source.ContentType)
+		}
+
+		sb.WriteString(" />\n")
+	}
+
+	if a.Integrity != nil {
+		sb.WriteString("  <podcast:integrity")
+		writeAttr(sb, "type", a.Integrity.Type)
+		writeAttr(sb, "value", a.Integrity.Value)
+		sb.WriteString(" />\n")
+	}
+
+	sb.WriteString("</podcast:alternateEnclosure>\n")
+
+	return sb.String()
+}
+
+func writeAttr(sb *strings.Builder, name string, value string) {
+	sb.WriteString(" " + name + `="`)
+	_ = xml.EscapeText(sb, []byte(value))
+	sb.WriteString(`"`)
+}
@@ -0,0 +1,6 @@
+// Package filecoin is a client for a Filecoin onboarding API, compatible with
+// Estuary, which fetches content from IPFS and makes storage deals for it.
+//
+// Add hands the API the root CID of an episode, and the peers which have it.
+// Status then reports the deals as they progress, until they are on chain.
+package filecoin
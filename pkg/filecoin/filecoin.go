@@ -0,0 +1,229 @@
+package filecoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Deal states, from the least to the most progress.
+const (
+	// StateFailed is when every deal of the content failed. It can be
+	// added again.
+	StateFailed = "failed"
+	// StateQueued is when the content was accepted, but no deals were made
+	// yet. The API aggregates small content into larger deals, so this can
+	// take a while.
+	StateQueued = "queued"
+	// StateProposed is when a deal was proposed to a storage provider, but
+	// isn't on chain yet.
+	StateProposed = "proposed"
+	// StateActive is when a deal is on chain.
+	StateActive = "active"
+)
+
+// Client adds content to the onboarding API, and checks its deals.
+type Client struct {
+	httpClient *http.Client
+	apiURL     *url.URL
+	token      string
+}
+
+// New creates a Client for the API at apiAddress, e.g.
+// https://api.estuary.tech. token is sent as a Bearer token.
+func New(httpClient *http.Client, apiAddress string, token string) (*Client, error) {
+	apiURL, err := url.Parse(apiAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archive api address failed: %w", err)
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		apiURL:     apiURL,
+		token:      token,
+	}, nil
+}
+
+type apiError struct {
+	Error struct {
+		Details string `json:"details"`
+	} `json:"error"`
+}
+
+func (c *Client) do(ctx context.Context, method string, reqPath string, body any, out any) error {
+	var reqBody io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request failed: %w", err)
+		}
+
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		c.apiURL.JoinPath(reqPath).String(),
+		reqBody,
+	)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp apiError
+
+		err := json.NewDecoder(resp.Body).Decode(&errResp)
+		if err != nil || errResp.Error.Details == "" {
+			return fmt.Errorf("response not OK: %d", resp.StatusCode)
+		}
+
+		return fmt.Errorf("response not OK: %d: %s", resp.StatusCode, errResp.Error.Details)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return nil
+}
+
+// Peer is an IPFS node which has the content, so the API can fetch it
+// directly instead of searching the DHT.
+type Peer struct {
+	ID    string   `json:"ID"`
+	Addrs []string `json:"Addrs"`
+}
+
+type addRequest struct {
+	Name  string `json:"name"`
+	Root  string `json:"root"`
+	Peers []Peer `json:"peers,omitempty"`
+}
+
+type addResponse struct {
+	ContentID int64 `json:"estuaryId"`
+}
+
+// Add asks the API to fetch root from IPFS, and make deals for it. It
+// returns the ID of the content, which is used to check its deals.
+func (c *Client) Add(ctx context.Context, root string, name string, peers []Peer) (int64, error) {
+	var added addResponse
+
+	err := c.do(
+		ctx,
+		http.MethodPost,
+		"content/add-ipfs",
+		addRequest{
+			Name:  name,
+			Root:  root,
+			Peers: peers,
+		},
+		&added,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return added.ContentID, nil
+}
+
+// Deal is a storage deal of some content.
+type Deal struct {
+	DealID    int64     `json:"dealId"`
+	Miner     string    `json:"miner"`
+	Failed    bool      `json:"failed"`
+	OnChainAt time.Time `json:"onChainAt"`
+}
+
+// Status is the state of the deals of some content.
+type Status struct {
+	Deals []Deal
+}
+
+type statusResponse struct {
+	Deals []struct {
+		Deal Deal `json:"deal"`
+	} `json:"deals"`
+}
+
+// Status returns the deals of the content with id.
+func (c *Client) Status(ctx context.Context, id int64) (*Status, error) {
+	var resp statusResponse
+
+	err := c.do(
+		ctx,
+		http.MethodGet,
+		"content/status/"+strconv.FormatInt(id, 10),
+		nil,
+		&resp,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(Status)
+	for _, deal := range resp.Deals {
+		status.Deals = append(status.Deals, deal.Deal)
+	}
+
+	return status, nil
+}
+
+// State summarizes the deals as the state with the most progress.
+func (s Status) State() string {
+	if len(s.Deals) == 0 {
+		return StateQueued
+	}
+
+	state := StateFailed
+
+	for _, deal := range s.Deals {
+		if deal.Failed {
+			continue
+		}
+
+		if !deal.OnChainAt.IsZero() {
+			return StateActive
+		}
+
+		state = StateProposed
+	}
+
+	return state
+}
+
+// ActiveDeals returns the IDs of the deals which are on chain.
+func (s Status) ActiveDeals() []int64 {
+	var ids []int64
+
+	for _, deal := range s.Deals {
+		if !deal.Failed && !deal.OnChainAt.IsZero() && deal.DealID != 0 {
+			ids = append(ids, deal.DealID)
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,179 @@
+package kubo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/kubo/client/rpc"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// unixHost is the host of the URLs of requests to a Unix socket. It's never
+// resolved, since the transport dials the socket.
+const unixHost = "unix"
+
+// addressExamples are the formats accepted by ParseAddress, for its errors.
+const addressExamples = "use a multiaddr like /ip4/127.0.0.1/tcp/5001, " +
+	"a host and port like 127.0.0.1:5001 or localhost:5001, " +
+	"a URL like http://localhost:5001, " +
+	"or a Unix socket like /unix/run/ipfs/api.sock"
+
+// ParseAddress parses the address of a Kubo API, which is a multiaddr, like
+// /ip4/127.0.0.1/tcp/5001, a host and port, like localhost:5001, or an http
+// or https URL, like https://ipfs.example.com. The host and port, and the
+// URLs, are converted to a multiaddr.
+func ParseAddress(s string) (multiaddr.Multiaddr, error) {
+	addr, err := parseAddress(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w; %s", s, err, addressExamples)
+	}
+
+	return addr, nil
+}
+
+func parseAddress(s string) (multiaddr.Multiaddr, error) {
+	if s == "" {
+		return nil, errors.New("empty address")
+	}
+
+	if strings.HasPrefix(s, "/") {
+		return multiaddr.NewMultiaddr(s)
+	}
+
+	scheme, hostPort, ok := strings.Cut(s, "://")
+	if !ok {
+		host, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return hostPortAddress(host, port, "")
+	}
+
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %s", scheme)
+	}
+
+	u, err := url.Parse(scheme + "://" + hostPort)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client adds /api/v0 to the address.
+	path := strings.TrimSuffix(u.Path, "/")
+	if path != "" && path != "/api/v0" {
+		return nil, fmt.Errorf("unsupported path %s", u.Path)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+
+	suffix := ""
+	if scheme == "https" {
+		suffix = "/https"
+	}
+
+	return hostPortAddress(u.Hostname(), port, suffix)
+}
+
+// hostPortAddress converts a host, which is an IP address or a DNS name, and
+// a TCP port to a multiaddr, with suffix appended.
+func hostPortAddress(host string, port string, suffix string) (multiaddr.Multiaddr, error) {
+	if host == "" {
+		return nil, errors.New("missing host")
+	}
+
+	protocol := "dns"
+
+	ip, err := netip.ParseAddr(host)
+	if err == nil {
+		protocol = "ip4"
+		if ip.Is6() {
+			protocol = "ip6"
+		}
+	}
+
+	return multiaddr.NewMultiaddr(fmt.Sprintf("/%s/%s/tcp/%s%s", protocol, host, port, suffix))
+}
+
+// UnixSocket returns the path of the socket of a /unix address, like
+// /unix/run/ipfs/api.sock, and if addr is one.
+func UnixSocket(addr multiaddr.Multiaddr) (string, bool) {
+	path, err := addr.ValueForProtocol(multiaddr.P_UNIX)
+	if err != nil {
+		return "", false
+	}
+
+	return path, true
+}
+
+// NewTransport creates the HTTP transport for the Kubo API at addr. Kubo is
+// usually on the same host or network, so the proxy from the environment
+// isn't used, and for a /unix address, it dials the socket.
+func NewTransport(addr multiaddr.Multiaddr) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+
+	path, ok := UnixSocket(addr)
+	if ok {
+		transport.DialContext = func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	}
+
+	return transport
+}
+
+// BaseURL returns the URL of the Kubo API at addr, like
+// http://127.0.0.1:5001, for the endpoints outside of the RPC, like
+// /debug/metrics/prometheus. A /unix address has a placeholder host, so its
+// requests have to be sent through a transport from NewTransport.
+func BaseURL(addr multiaddr.Multiaddr) (string, error) {
+	_, ok := UnixSocket(addr)
+	if ok {
+		return "http://" + unixHost, nil
+	}
+
+	_, host, err := manet.DialArgs(addr)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "http://"
+
+	for _, protocol := range addr.Protocols() {
+		if protocol.Code == multiaddr.P_HTTPS || protocol.Code == multiaddr.P_TLS {
+			scheme = "https://"
+
+			break
+		}
+	}
+
+	return scheme + host, nil
+}
+
+// NewAPI creates an RPC client for the Kubo API at addr, like
+// /ip4/127.0.0.1/tcp/5001, or /unix/run/ipfs/api.sock. For a /unix address,
+// httpClient has to send the requests through a transport from NewTransport.
+func NewAPI(addr multiaddr.Multiaddr, httpClient *http.Client) (*rpc.HttpApi, error) {
+	baseURL, err := BaseURL(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewURLApiWithClient(baseURL, httpClient)
+}
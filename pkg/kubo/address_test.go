@@ -0,0 +1,69 @@
+package kubo
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		// want is the multiaddr, or empty if the address is invalid.
+		want string
+	}{
+		{name: "multiaddr", address: "/ip4/127.0.0.1/tcp/5001", want: "/ip4/127.0.0.1/tcp/5001"},
+		{name: "spaces", address: " /ip4/127.0.0.1/tcp/5001\n", want: "/ip4/127.0.0.1/tcp/5001"},
+		{name: "ip and port", address: "127.0.0.1:5001", want: "/ip4/127.0.0.1/tcp/5001"},
+		{name: "ipv6 and port", address: "[::1]:5001", want: "/ip6/::1/tcp/5001"},
+		{name: "host and port", address: "ipfs.lan:5001", want: "/dns/ipfs.lan/tcp/5001"},
+		{name: "http", address: "http://localhost:5001", want: "/dns/localhost/tcp/5001"},
+		{name: "http without port", address: "http://ipfs.lan", want: "/dns/ipfs.lan/tcp/80"},
+		{name: "https", address: "https://ipfs.example.com", want: "/dns/ipfs.example.com/tcp/443/https"},
+		{name: "api path", address: "http://localhost:5001/api/v0/", want: "/dns/localhost/tcp/5001"},
+		{name: "https api path", address: "https://ipfs.example.com:8443/api/v0", want: "/dns/ipfs.example.com/tcp/8443/https"},
+		{name: "unix", address: "/unix/run/ipfs/api.sock", want: "/unix/run/ipfs/api.sock"},
+		{name: "other path", address: "http://localhost:5001/ipfs", want: ""},
+		{name: "other scheme", address: "ftp://localhost:5001", want: ""},
+		{name: "missing port", address: "localhost", want: ""},
+		{name: "missing host", address: ":5001", want: ""},
+		{name: "invalid multiaddr", address: "/ip4/localhost/tcp/5001", want: ""},
+		{name: "empty", address: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := ParseAddress(tt.address)
+
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("got %s, want an error", addr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsing failed: %v", err)
+			}
+
+			if addr.String() != tt.want {
+				t.Errorf("got %s, want %s", addr, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	addr, err := ParseAddress("/unix/run/ipfs/api.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, ok := UnixSocket(addr)
+	if !ok || path != "/run/ipfs/api.sock" {
+		t.Errorf("got %q, %t, want /run/ipfs/api.sock", path, ok)
+	}
+
+	baseURL, err := BaseURL(addr)
+	if err != nil || baseURL != "http://unix" {
+		t.Errorf("got base url %q, %v, want http://unix", baseURL, err)
+	}
+}
@@ -0,0 +1,106 @@
+package kubo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultReprovideInterval is how often Kubo reprovides the pinned content
+// when Reprovider.Interval isn't set.
+const DefaultReprovideInterval = 22 * time.Hour
+
+// ErrConfigNotSet is when a config key isn't in Kubo's config file, so Kubo
+// uses its default.
+var ErrConfigNotSet = errors.New("config key not set")
+
+type configResponse struct {
+	Key   string          `json:"Key"`
+	Value json.RawMessage `json:"Value"`
+}
+
+// ConfigGet returns the JSON value of key in Kubo's config, like
+// Datastore.StorageMax. It returns ErrConfigNotSet if the key isn't in the
+// config file.
+func (c *Client) ConfigGet(ctx context.Context, key string) (json.RawMessage, error) {
+	resp, err := c.api.Request("config", key).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		// Kubo fails to get keys which aren't in the config file,
+		// rather than returning their defaults.
+		if strings.Contains(resp.Error.Message, "key has no attribute") {
+			return nil, ErrConfigNotSet
+		}
+
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	config := new(configResponse)
+
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	if string(config.Value) == "null" {
+		return nil, ErrConfigNotSet
+	}
+
+	return config.Value, nil
+}
+
+// ConfigSet sets key in Kubo's config to value, which is encoded as JSON.
+// Most of the config only takes effect when Kubo restarts.
+func (c *Client) ConfigSet(ctx context.Context, key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding value failed: %w", err)
+	}
+
+	err = c.api.Request("config", key, string(encoded)).
+		Option("json", true).
+		Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// ReprovideInterval returns how often Kubo reprovides the pinned content,
+// from Reprovider.Interval, or DefaultReprovideInterval when it isn't set.
+// Zero means reproviding is disabled.
+func (c *Client) ReprovideInterval(ctx context.Context) (time.Duration, error) {
+	value, err := c.ConfigGet(ctx, "Reprovider.Interval")
+	if errors.Is(err, ErrConfigNotSet) {
+		return DefaultReprovideInterval, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var interval string
+
+	err = json.Unmarshal(value, &interval)
+	if err != nil {
+		return 0, fmt.Errorf("decoding interval failed: %w", err)
+	}
+
+	if interval == "" {
+		return DefaultReprovideInterval, nil
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, fmt.Errorf("parsing interval failed: %w", err)
+	}
+
+	return duration, nil
+}
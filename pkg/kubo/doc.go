@@ -0,0 +1,7 @@
+// Package kubo is a client for the Kubo RPC endpoints used by the IPFS
+// Podcasting updater, like adding episodes wrapped in a directory, pinning,
+// and the node stats reported to the server.
+//
+// It wraps the official RPC client, which is available with Client.API for
+// anything not covered here.
+package kubo
@@ -0,0 +1,53 @@
+package kubo_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/kubotest"
+)
+
+// An episode is added wrapped in a directory, so it keeps its filename, and
+// the directory is pinned with the name of the episode.
+func ExampleClient_AddWrapped() {
+	node := kubotest.NewServer()
+	defer node.Close()
+
+	// A real node would use rpc.NewLocalApi, and kubo.New.
+	k, err := node.Client()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	added, err := k.AddWrapped(ctx, strings.NewReader("ID3 episode"), "episode.mp3", kubo.AddOptions{
+		CIDVersion: 1,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("dir:", added.Dir.Hash)
+	fmt.Println("file:", added.File.Name, added.File.Hash, added.File.Size)
+
+	err = k.PinAdd(ctx, added.Dir.Hash, "show/episode")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pinned, err := k.IsPinned(ctx, added.Dir.Hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("pinned:", pinned)
+
+	// Output:
+	// dir: bafybeieiwhk4gkzqwxfjxlhkizyduupnfkfa3t2gqyk465degqiwugw6mu
+	// file: episode.mp3 bafkreihcofdgahmfypswbh6hrmjixr5co3x4znyp7mtlty3opsm4okqzdq 11
+	// pinned: true
+}
@@ -0,0 +1,999 @@
+package kubo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/client/rpc"
+)
+
+// Client calls the Kubo RPC endpoints used by the updater.
+type Client struct {
+	api *rpc.HttpApi
+	// version is the Kubo version from DetectVersion, nil before it's
+	// detected.
+	version atomic.Pointer[Version]
+}
+
+// New creates a Client using api to talk to Kubo.
+func New(api *rpc.HttpApi) *Client {
+	return &Client{
+		api: api,
+	}
+}
+
+// API returns the underlying RPC client, for calls not covered by Client.
+func (c *Client) API() *rpc.HttpApi {
+	return c.api
+}
+
+// Peers returns the number of connected peers.
+func (c *Client) Peers(ctx context.Context) (int, error) {
+	connectionInfo, err := c.api.Swarm().Peers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("requesting peers failed: %w", err)
+	}
+
+	return len(connectionInfo), nil
+}
+
+// PeerIDs returns the IDs of the connected peers.
+func (c *Client) PeerIDs(ctx context.Context) ([]string, error) {
+	connectionInfo, err := c.api.Swarm().Peers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("requesting peers failed: %w", err)
+	}
+
+	ids := make([]string, 0, len(connectionInfo))
+	for _, info := range connectionInfo {
+		ids = append(ids, info.ID().String())
+	}
+
+	return ids, nil
+}
+
+type RepoStatResponse struct {
+	RepoSize   int    `json:"RepoSize"`
+	StorageMax int    `json:"StorageMax"`
+	NumObjects int    `json:"NumObjects"`
+	RepoPath   string `json:"RepoPath"`
+	Version    string `json:"Version"`
+}
+
+func (c *Client) RepoStat(ctx context.Context) (*RepoStatResponse, error) {
+	resp, err := c.api.Request("repo/stat").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	stats := new(RepoStatResponse)
+
+	err = decoder.Decode(stats)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return stats, nil
+}
+
+// BitswapStatResponse is the subset of the bitswap stats which are used.
+type BitswapStatResponse struct {
+	Wantlist []struct {
+		CID string `json:"/"`
+	} `json:"Wantlist"`
+	BlocksReceived  uint64 `json:"BlocksReceived"`
+	DataReceived    uint64 `json:"DataReceived"`
+	DupBlksReceived uint64 `json:"DupBlksReceived"`
+	DupDataReceived uint64 `json:"DupDataReceived"`
+	BlocksSent      uint64 `json:"BlocksSent"`
+	DataSent        uint64 `json:"DataSent"`
+}
+
+// BitswapStat returns the blocks and bytes exchanged with other peers over
+// bitswap since Kubo started.
+func (c *Client) BitswapStat(ctx context.Context) (*BitswapStatResponse, error) {
+	resp, err := c.api.Request("bitswap/stat").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	stats := new(BitswapStatResponse)
+
+	err = decoder.Decode(stats)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return stats, nil
+}
+
+// BitswapLedgerResponse is the bitswap ledger of the node with a peer.
+type BitswapLedgerResponse struct {
+	Peer string `json:"Peer"`
+	// Value is the ratio of bytes sent to bytes received, plus one.
+	Value     float64 `json:"Value"`
+	Sent      uint64  `json:"Sent"`
+	Recv      uint64  `json:"Recv"`
+	Exchanged uint64  `json:"Exchanged"`
+}
+
+// BitswapLedger returns the blocks and bytes exchanged with peer over
+// bitswap. Kubo keeps the ledger while it knows the peer, so it starts over
+// after the peer was disconnected for a while.
+func (c *Client) BitswapLedger(ctx context.Context, peer string) (*BitswapLedgerResponse, error) {
+	resp, err := c.api.Request("bitswap/ledger", peer).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	ledger := new(BitswapLedgerResponse)
+
+	err = decoder.Decode(ledger)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return ledger, nil
+}
+
+// BandwidthStatResponse is the total bytes, and the current rate in bytes per
+// second, of the node's network traffic.
+type BandwidthStatResponse struct {
+	TotalIn  int64   `json:"TotalIn"`
+	TotalOut int64   `json:"TotalOut"`
+	RateIn   float64 `json:"RateIn"`
+	RateOut  float64 `json:"RateOut"`
+}
+
+// BandwidthStat returns the network traffic of the node since Kubo started.
+func (c *Client) BandwidthStat(ctx context.Context) (*BandwidthStatResponse, error) {
+	resp, err := c.api.Request("stats/bw").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	stats := new(BandwidthStatResponse)
+
+	err = decoder.Decode(stats)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return stats, nil
+}
+
+// ProvideStatResponse is the statistics of the reprovider, which announces
+// the node's content to the DHT. The durations are in nanoseconds.
+type ProvideStatResponse struct {
+	TotalProvides          uint64        `json:"TotalProvides"`
+	LastReprovideBatchSize uint64        `json:"LastReprovideBatchSize"`
+	AvgProvideDuration     time.Duration `json:"AvgProvideDuration"`
+	LastReprovideDuration  time.Duration `json:"LastReprovideDuration"`
+}
+
+// ProvideStat returns the statistics of the reprovider. Kubo fails it while
+// the node is offline.
+func (c *Client) ProvideStat(ctx context.Context) (*ProvideStatResponse, error) {
+	resp, err := c.api.Request("stats/provide").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	stats := new(ProvideStatResponse)
+
+	err = decoder.Decode(stats)
+	if err != nil {
+		return nil, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	return stats, nil
+}
+
+type repoGCResponse struct {
+	Key struct {
+		CID string `json:"/"`
+	} `json:"Key"`
+	Error string `json:"Error"`
+}
+
+// RepoGC runs the garbage collector, and returns the number of removed
+// blocks.
+func (c *Client) RepoGC(ctx context.Context) (int, error) {
+	resp, err := c.api.Request("repo/gc").Send(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return 0, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	removed := 0
+
+	for {
+		var gcResp repoGCResponse
+
+		err := decoder.Decode(&gcResp)
+		if errors.Is(err, io.EOF) {
+			return removed, nil
+		}
+		if err != nil {
+			return removed, fmt.Errorf("json decode failed: %w", err)
+		}
+
+		if gcResp.Error != "" {
+			return removed, fmt.Errorf("gc failed: %s", gcResp.Error)
+		}
+
+		removed += 1
+	}
+}
+
+// PinRm removes a recursive pin of hash, a CID or an /ipfs/ path. Hashes
+// which are not pinned are not an error.
+func (c *Client) PinRm(ctx context.Context, hash string) error {
+	if !strings.HasPrefix(hash, "/") {
+		hash = "/ipfs/" + hash
+	}
+
+	hashPath, err := path.NewPath(hash)
+	if err != nil {
+		return fmt.Errorf("hash to path: %w", err)
+	}
+
+	err = c.api.Pin().Rm(ctx, hashPath)
+	if err != nil {
+		// This error is OK for us. Sometimes we get delete requests for
+		// files we don't have pinned. That's OK.
+		if strings.Contains(err.Error(), "not pinned or pinned indirectly") {
+			return nil
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// PinAdd recursively pins hash, fetching it from the network if needed.
+// name is optional. Pinning something which is already pinned replaces the
+// name of the pin.
+func (c *Client) PinAdd(ctx context.Context, hash string, name string) error {
+	req := c.api.Request("pin/add", hash).
+		Option("recursive", true)
+
+	if name != "" && c.PinNames() {
+		req = req.Option("name", name)
+	}
+
+	err := req.Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+type pinLsResponse struct {
+	Cid  string `json:"Cid"`
+	Name string `json:"Name"`
+	Type string `json:"Type"`
+}
+
+// PinLs returns the recursive pins, mapped to their names. Pins without a
+// name, and every pin on a Kubo which doesn't keep the names, map to an empty
+// string.
+func (c *Client) PinLs(ctx context.Context) (map[string]string, error) {
+	req := c.api.Request("pin/ls").
+		Option("type", "recursive").
+		Option("stream", true)
+
+	if c.PinNames() {
+		req = req.Option("names", true)
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	pins := map[string]string{}
+
+	for {
+		var pin pinLsResponse
+
+		err := decoder.Decode(&pin)
+		if errors.Is(err, io.EOF) {
+			return pins, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("json decode failed: %w", err)
+		}
+
+		pins[pin.Cid] = pin.Name
+	}
+}
+
+// IsPinned checks if hash is pinned recursively, without listing all the
+// pins.
+func (c *Client) IsPinned(ctx context.Context, hash string) (bool, error) {
+	resp, err := c.api.Request("pin/ls", hash).
+		Option("type", "recursive").
+		Send(ctx)
+	if err != nil {
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		if strings.Contains(resp.Error.Message, "not pinned") {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	resp.Output.Close()
+
+	return true, nil
+}
+
+// BadNode is a block of a pin which is missing or corrupt.
+type BadNode struct {
+	Cid string `json:"Cid"`
+	Err string `json:"Err"`
+}
+
+// PinVerifyResponse is the verification result of a pin.
+type PinVerifyResponse struct {
+	Cid      string    `json:"Cid"`
+	Err      string    `json:"Err"`
+	Ok       bool      `json:"Ok"`
+	BadNodes []BadNode `json:"BadNodes"`
+}
+
+// PinVerify traverses the blocks of all the recursive pins, and returns the
+// pins which have missing or corrupt blocks.
+func (c *Client) PinVerify(ctx context.Context) ([]PinVerifyResponse, error) {
+	resp, err := c.api.Request("pin/verify").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	var bad []PinVerifyResponse
+
+	for {
+		var verify PinVerifyResponse
+
+		err := decoder.Decode(&verify)
+		if errors.Is(err, io.EOF) {
+			return bad, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("json decode failed: %w", err)
+		}
+
+		if !verify.Ok {
+			bad = append(bad, verify)
+		}
+	}
+}
+
+// queryError is the type of the routing query events with an error.
+const queryError = 3
+
+type routingEvent struct {
+	Type  int    `json:"Type"`
+	Extra string `json:"Extra"`
+}
+
+// Provide announces to the DHT that the node has the blocks of hashes, so
+// other peers can find them right away, instead of after the next reprovide.
+// Only the blocks themselves are provided, not the blocks they link to.
+func (c *Client) Provide(ctx context.Context, hashes ...string) error {
+	resp, err := c.api.Request("routing/provide", hashes...).Send(ctx)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+
+	for {
+		var event routingEvent
+
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("json decode failed: %w", err)
+		}
+
+		if event.Type == queryError {
+			return fmt.Errorf("provide failed: %s", event.Extra)
+		}
+	}
+}
+
+// PeeringAdd adds peers to Kubo's peering, which keeps connections to them,
+// and reconnects when they drop. Each of addrs is a multiaddr with the peer
+// ID, like /ip4/203.0.113.1/tcp/4001/p2p/12D3KooW... The peers are only kept
+// until Kubo restarts, unlike the ones in Peering.Peers of the config.
+func (c *Client) PeeringAdd(ctx context.Context, addrs ...string) error {
+	err := c.api.Request("swarm/peering/add", addrs...).Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// PeeringRm removes peers, by their IDs, from Kubo's peering.
+func (c *Client) PeeringRm(ctx context.Context, ids ...string) error {
+	err := c.api.Request("swarm/peering/rm", ids...).Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+type blockRmResponse struct {
+	Hash  string `json:"Hash"`
+	Error string `json:"Error"`
+}
+
+// BlockRm removes a block from the local blockstore. Blocks which are not in
+// the blockstore are not an error. Kubo refuses to remove blocks which are
+// part of a pin, so they have to be unpinned first.
+func (c *Client) BlockRm(ctx context.Context, hash string) error {
+	var removed blockRmResponse
+
+	// Kubo reports blocks it couldn't remove in the output, and not as a
+	// failed request.
+	err := c.api.Request("block/rm", hash).
+		Option("force", true).
+		Exec(ctx, &removed)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	if removed.Error != "" {
+		return fmt.Errorf("removing block failed: %s", removed.Error)
+	}
+
+	return nil
+}
+
+type resolveResponse struct {
+	Path string `json:"Path"`
+}
+
+// Resolve resolves an /ipns/ path, or a DNSLink name, to an /ipfs/ path.
+func (c *Client) Resolve(ctx context.Context, ipnsPath string) (string, error) {
+	var resolved resolveResponse
+
+	err := c.api.Request("resolve", ipnsPath).
+		Option("recursive", true).
+		Exec(ctx, &resolved)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+
+	return resolved.Path, nil
+}
+
+// NamePublishResponse is the IPNS name, and the path it was published as.
+type NamePublishResponse struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// NamePublish publishes ipfsPath under the IPNS name of the key. The record
+// is valid for lifetime.
+func (c *Client) NamePublish(ctx context.Context, ipfsPath string, key string, lifetime time.Duration) (*NamePublishResponse, error) {
+	published := new(NamePublishResponse)
+
+	err := c.api.Request("name/publish", ipfsPath).
+		Option("key", key).
+		Option("lifetime", lifetime.String()).
+		Option("allow-offline", true).
+		Exec(ctx, published)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return published, nil
+}
+
+// Key is a keypair in Kubo's keystore, which can publish IPNS names.
+type Key struct {
+	Name string `json:"Name"`
+	ID   string `json:"Id"`
+}
+
+type keyListResponse struct {
+	Keys []Key `json:"Keys"`
+}
+
+// KeyList lists the keys in the keystore, including the node's "self" key.
+func (c *Client) KeyList(ctx context.Context) ([]Key, error) {
+	var list keyListResponse
+
+	err := c.api.Request("key/list").
+		Option("l", true).
+		Exec(ctx, &list)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return list.Keys, nil
+}
+
+// KeyGen creates a key of keyType, rsa or ed25519.
+func (c *Client) KeyGen(ctx context.Context, name string, keyType string) (*Key, error) {
+	key := new(Key)
+
+	err := c.api.Request("key/gen", name).
+		Option("type", keyType).
+		Exec(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// KeyImport imports a private key in format, libp2p-protobuf-cleartext or
+// pem-pkcs8-cleartext, as name.
+func (c *Client) KeyImport(ctx context.Context, name string, r io.Reader, format string) (*Key, error) {
+	key := new(Key)
+
+	err := c.api.Request("key/import", name).
+		Option("format", format).
+		FileBody(r).
+		Exec(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return key, nil
+}
+
+type LsLink struct {
+	Name   string `json:"Name"`
+	Hash   string `json:"Hash"`
+	Size   int    `json:"Size"`
+	Type   int    `json:"Type"`
+	Target string `json:"Target"`
+}
+
+type LsObject struct {
+	Hash  string   `json:"Hash"`
+	Links []LsLink `json:"links"`
+}
+
+type LsResponse struct {
+	Objects []LsObject `json:"Objects"`
+}
+
+func (c *Client) Ls(ctx context.Context, hash string) (*LsResponse, error) {
+	resp, err := c.api.Request("ls", hash).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	ls := new(LsResponse)
+
+	err = decoder.Decode(ls)
+	if err != nil {
+		return nil, fmt.Errorf("json decode failed: %w", err)
+	}
+
+	return ls, nil
+}
+
+// FileSize returns the sum of the sizes of the links of hash.
+func (c *Client) FileSize(ctx context.Context, hash string) (int, error) {
+	lsResp, err := c.Ls(ctx, hash)
+	if err != nil {
+		return 0, fmt.Errorf("ls failed: %w", err)
+	}
+
+	total := 0
+	for _, object := range lsResp.Objects {
+		for _, link := range object.Links {
+			total += link.Size
+		}
+	}
+
+	return total, nil
+}
+
+type FilesStatResponse struct {
+	Hash           string `json:"Hash"`
+	Size           int    `json:"Size"`
+	CumulativeSize int    `json:"CumulativeSize"`
+	Blocks         int    `json:"Blocks"`
+	Type           string `json:"Type"`
+}
+
+// FilesStat returns the size of hash, without fetching more than the root
+// block.
+func (c *Client) FilesStat(ctx context.Context, hash string) (*FilesStatResponse, error) {
+	resp, err := c.api.Request("files/stat", "/ipfs/"+hash).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	stat := new(FilesStatResponse)
+
+	err = decoder.Decode(stat)
+	if err != nil {
+		return nil, fmt.Errorf("json decode failed: %w", err)
+	}
+
+	return stat, nil
+}
+
+// FilesMkdir creates the MFS directory at mfsPath, and its parents.
+func (c *Client) FilesMkdir(ctx context.Context, mfsPath string) error {
+	err := c.api.Request("files/mkdir", mfsPath).
+		Option("parents", true).
+		Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// FilesCp copies src, like /ipfs/<cid>, to the MFS path dst.
+func (c *Client) FilesCp(ctx context.Context, src string, dst string) error {
+	err := c.api.Request("files/cp", src, dst).
+		Exec(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// FilesRm removes the MFS path, and everything under it. Paths which don't
+// exist are not an error.
+func (c *Client) FilesRm(ctx context.Context, mfsPath string) error {
+	err := c.api.Request("files/rm", mfsPath).
+		Option("force", true).
+		Exec(ctx, nil)
+	if err != nil && !strings.Contains(err.Error(), "does not exist") {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	return nil
+}
+
+// DagExport streams the DAG of hash as a CAR file. The caller has to close
+// it.
+func (c *Client) DagExport(ctx context.Context, hash string) (io.ReadCloser, error) {
+	resp, err := c.api.Request("dag/export", hash).Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+
+	return resp.Output, nil
+}
+
+type DagImportRoot struct {
+	Cid         string
+	PinErrorMsg string
+}
+
+type dagImportResponse struct {
+	Root *struct {
+		Cid struct {
+			Link string `json:"/"`
+		} `json:"Cid"`
+		PinErrorMsg string `json:"PinErrorMsg"`
+	} `json:"Root"`
+}
+
+// DagImport imports the CAR from r, and pins its roots. A root which failed
+// to pin has PinErrorMsg set.
+func (c *Client) DagImport(ctx context.Context, r io.Reader) ([]DagImportRoot, error) {
+	resp, err := c.api.Request("dag/import").
+		Option("pin-roots", true).
+		FileBody(r).
+		Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	var roots []DagImportRoot
+
+	decoder := json.NewDecoder(resp.Output)
+	for {
+		var line dagImportResponse
+
+		err := decoder.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding json failed: %w", err)
+		}
+
+		if line.Root != nil {
+			roots = append(roots, DagImportRoot{
+				Cid:         line.Root.Cid.Link,
+				PinErrorMsg: line.Root.PinErrorMsg,
+			})
+		}
+	}
+
+	return roots, nil
+}
+
+type AddResponse struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+	Size int    `json:"Size,string"`
+}
+
+// AddWrappedResponse is the result of AddWrapped.
+type AddWrappedResponse struct {
+	// File is the added file.
+	File AddResponse
+	// Dir is the directory wrapping the file.
+	Dir AddResponse
+}
+
+// AddOptions changes how content is added. The zero value uses Kubo's
+// defaults.
+type AddOptions struct {
+	// CIDVersion of the added content, 0 or 1. CIDv1 also implies raw
+	// leaves.
+	CIDVersion int
+	// RawLeaves uses raw blocks for the leaf nodes.
+	RawLeaves bool
+	// Hash function, like sha2-256 or blake3. Empty uses Kubo's default.
+	Hash string
+	// Chunker splits the file into blocks, like size-262144, buzhash, or
+	// rabin-min-avg-max. Nodes have to chunk the same way for their blocks
+	// to be deduplicated. Empty uses Kubo's default.
+	Chunker string
+}
+
+// ValidateChunker returns an error if chunker is not one of size-<bytes>,
+// buzhash, rabin, or rabin-<min>-<avg>-<max>.
+func ValidateChunker(chunker string) error {
+	name, args, _ := strings.Cut(chunker, "-")
+
+	var sizes []string
+	if args != "" {
+		sizes = strings.Split(args, "-")
+	}
+
+	switch {
+	case chunker == "buzhash" || chunker == "rabin":
+		return nil
+	case name == "size" && len(sizes) == 1:
+	case name == "rabin" && len(sizes) == 3:
+	default:
+		return fmt.Errorf("unknown chunker %q, expected size-<bytes>, buzhash, rabin, or rabin-<min>-<avg>-<max>", chunker)
+	}
+
+	for _, size := range sizes {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid chunker size %q in %q", size, chunker)
+		}
+	}
+
+	return nil
+}
+
+// AddWrapped adds the contents of r as filename, wrapped in a directory, and
+// pins the directory.
+//
+// The returned hashes are in the format of the added CIDs, so with CIDv1,
+// both the file and directory hashes are base32 CIDv1 strings.
+func (c *Client) AddWrapped(ctx context.Context, r io.Reader, filename string, opts AddOptions) (*AddWrappedResponse, error) {
+	body, writer := io.Pipe()
+	reqMultipart := multipart.NewWriter(writer)
+
+	req := c.api.Request("add")
+	req = req.Option("wrap-with-directory", true)
+
+	if opts.CIDVersion != 0 {
+		req = req.Option("cid-version", opts.CIDVersion)
+	}
+	if opts.RawLeaves {
+		req = req.Option("raw-leaves", true)
+	}
+	if opts.Hash != "" {
+		req = req.Option("hash", opts.Hash)
+	}
+	if opts.Chunker != "" {
+		req = req.Option("chunker", opts.Chunker)
+	}
+	req.Header("Content-Type", reqMultipart.FormDataContentType())
+	req.Body(body)
+
+	writeErr := make(chan error, 1)
+
+	go func() {
+		err := writeMultipart(reqMultipart, r, filename)
+
+		// Closing the pipe with the error makes sure the request doesn't
+		// wait for a body which will never finish.
+		writer.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		err = fmt.Errorf("request failed: %w", err)
+
+		// Kubo can stop reading the body before it's done, which leaves
+		// the writer blocked, so the pipe is closed before waiting for it.
+		body.CloseWithError(err)
+		<-writeErr
+
+		return nil, err
+	}
+	if resp.Error != nil {
+		err = fmt.Errorf("response failed: %s", resp.Error.Message)
+
+		body.CloseWithError(err)
+		<-writeErr
+
+		return nil, err
+	}
+	defer resp.Output.Close()
+
+	err = <-writeErr
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAddWrapped(resp.Output, filename)
+}
+
+// addEvent is a line of the add response. Besides the added files and
+// directories, which have a Hash, the stream can have progress lines, which
+// only have the Name and Bytes, and errors, which have a Message.
+type addEvent struct {
+	AddResponse
+
+	Bytes   int64  `json:"Bytes"`
+	Message string `json:"Message"`
+	Type    string `json:"Type"`
+}
+
+// decodeAddWrapped reads the whole NDJSON add response, and picks the file
+// named filename, and the wrapping directory, which has an empty name.
+func decodeAddWrapped(r io.Reader, filename string) (*AddWrappedResponse, error) {
+	decoder := json.NewDecoder(r)
+
+	var file, dir *AddResponse
+
+	for {
+		var event addEvent
+
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("json decode failed: %w", err)
+		}
+
+		if event.Type == "error" {
+			return nil, fmt.Errorf("response failed: %s", event.Message)
+		}
+
+		if event.Hash == "" {
+			continue
+		}
+
+		switch event.Name {
+		case filename:
+			file = &event.AddResponse
+		case "":
+			dir = &event.AddResponse
+		}
+	}
+
+	if file == nil {
+		return nil, fmt.Errorf("add response has no file named %q", filename)
+	}
+	if dir == nil {
+		return nil, fmt.Errorf("add response has no wrapping directory")
+	}
+
+	return &AddWrappedResponse{
+		File: *file,
+		Dir:  *dir,
+	}, nil
+}
+
+func writeMultipart(mpw *multipart.Writer, r io.Reader, filename string) error {
+	w, err := mpw.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("creating form file failed: %w", err)
+	}
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return fmt.Errorf("copy download failed: %w", err)
+	}
+
+	err = mpw.Close()
+	if err != nil {
+		return fmt.Errorf("closing mutlipart writer failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package kubo
+
+import "testing"
+
+func TestValidateChunker(t *testing.T) {
+	tests := []struct {
+		chunker string
+		valid   bool
+	}{
+		{chunker: "size-262144", valid: true},
+		{chunker: "size-1048576", valid: true},
+		{chunker: "buzhash", valid: true},
+		{chunker: "rabin", valid: true},
+		{chunker: "rabin-262144-524288-1048576", valid: true},
+		{chunker: ""},
+		{chunker: "size"},
+		{chunker: "size-"},
+		{chunker: "size-0"},
+		{chunker: "size--1"},
+		{chunker: "size-256k"},
+		{chunker: "size-1-2"},
+		{chunker: "buzhash-1"},
+		{chunker: "rabin-1-2"},
+		{chunker: "rabin-1-2-x"},
+		{chunker: "fastcdc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chunker, func(t *testing.T) {
+			err := ValidateChunker(tt.chunker)
+
+			if tt.valid && err != nil {
+				t.Errorf("got %v, want valid", err)
+			}
+
+			if !tt.valid && err == nil {
+				t.Error("got valid, want an error")
+			}
+		})
+	}
+}
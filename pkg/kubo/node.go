@@ -0,0 +1,167 @@
+package kubo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+//	{
+//	  "diskinfo": {
+//	    "free_space": 45147315712,
+//	    "fstype": "3393526350",
+//	    "total_space": 44452741120
+//	  },
+//	  "environment": {
+//	    "GOPATH": "",
+//	    "IPFS_PATH": ""
+//	  },
+//	  "ipfs_commit": "",
+//	  "ipfs_version": "0.23.0",
+//	  "memory": {
+//	    "swap": 0,
+//	    "virt": 2983384000
+//	  },
+//	  "net": {
+//	    "interface_addresses": [
+//	      "/ip4/127.0.0.1",
+//	      "/ip4/192.168.0.160",
+//	      "/ip4/192.168.122.1",
+//	      "/ip4/100.89.52.31",
+//	      "/ip4/172.18.0.1",
+//	      "/ip4/172.17.0.1",
+//	      "/ip6/::1",
+//	      "/ip6/fe80::f2eb:eebb:44f5:837a",
+//	      "/ip6/fd7a:115c:a1e0:ab12:4843:cd96:6259:341f",
+//	      "/ip6/fe80::49b2:7ef3:ee2:ca18"
+//	    ],
+//	    "online": true
+//	  },
+//	  "runtime": {
+//	    "arch": "amd64",
+//	    "compiler": "gc",
+//	    "gomaxprocs": 16,
+//	    "numcpu": 16,
+//	    "numgoroutines": 283,
+//	    "os": "linux",
+//	    "version": "go1.21.3"
+//	  }
+//	}
+type DiagSysResponse struct {
+	DiskInfo struct {
+		FreeSpace  int64  `json:"free_space"`
+		FSType     string `json:"fstype"`
+		TotalSpace int64  `json:"total_space"`
+	} `json:"diskinfo"`
+	Environment struct {
+		GoPath   string `json:"GOPATH"`
+		IPFSPath string `json:"IPFS_PATH"`
+	} `json:"environment"`
+	IPFSCommit  string `json:"ipfs_commit"`
+	IPFSVersion string `json:"ipfs_version"`
+	Memory      struct {
+		Swap int64 `json:"swap"`
+		Virt int64 `json:"virt"`
+	} `json:"memory"`
+	Net struct {
+		InterfaceAddresses []string `json:"interface_addresses"`
+		Online             bool     `json:"online"`
+	} `json:"net"`
+	Runtime struct {
+		Arch          string `json:"arch"`
+		Compiler      string `json:"compiler"`
+		GoMacProcs    int    `json:"gomaxprocs"`
+		NumCPUs       int    `json:"numcpu"`
+		NumGoroutines int    `json:"numgoroutines"`
+		OS            string `json:"os"`
+		Version       string `json:"version"`
+	}
+}
+
+//	{
+//	  "ID": "12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	  "PublicKey": "CAESIJiZuBDyMqYaXmHzPgbKoOKHhKhPAgFkU/xt0563KZ81",
+//	  "Addresses": [
+//	    "/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/127.0.0.1/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/127.0.0.1/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/144.202.116.156/tcp/4001/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",    "/ip4/144.202.116.156/udp/4001/quic-v1/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/144.202.116.156/udp/4001/quic/p2p/12D3KooWMeJti8EyULiL6Ae1SaHN8uhhgjZWpkuT2Rak6vSHfhcj/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/192.168.0.160/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/192.168.0.160/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/192.168.0.160/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/64.20.50.242/tcp/4001/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/64.20.50.242/udp/4001/quic-v1/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip4/64.20.50.242/udp/4001/quic-v1/webtransport/certhash/uEiDaxiUKVD_6DcKDiWcumyWrtIkIXT2rNlo0k8EgpyT0Og/certhash/uEiArSVE3Q14fQzk2NU8CtG_xATGO1XrzTRWBglw5IbNKxg/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/tcp/4001/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/udp/4001/quic-v1/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/2604:a00:50:b9:aaa1:59ff:fec7:2082/udp/4001/quic-v1/webtransport/certhash/uEiDaxiUKVD_6DcKDiWcumyWrtIkIXT2rNlo0k8EgpyT0Og/certhash/uEiArSVE3Q14fQzk2NU8CtG_xATGO1XrzTRWBglw5IbNKxg/p2p/12D3KooWFCxURh5KFQrP4YwxG9aPbMQjrBrm7HBMdFCW9feWoRyh/p2p-circuit/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/::1/tcp/4001/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/::1/udp/4001/quic-v1/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg",
+//	    "/ip6/::1/udp/4001/quic-v1/webtransport/certhash/uEiCL4zOsXA211I8dPzeQTR7Ws8CyRhyNUI0trGwOR5a-JA/certhash/uEiAPDBPZGNogGfelJLdGoNDIe3iVUZCpX-llOfV6JI7ehw/p2p/12D3KooWL6466mzdYUHCBRabjfAZTL5BbzVGCsgfRnH8NhbejiSg"
+//	  ],
+//	  "AgentVersion": "kubo/0.23.0/",
+//	  "Protocols": [
+//	    "/ipfs/bitswap",
+//	    "/ipfs/bitswap/1.0.0",
+//	    "/ipfs/bitswap/1.1.0",
+//	    "/ipfs/bitswap/1.2.0",
+//	    "/ipfs/id/1.0.0",
+//	    "/ipfs/id/push/1.0.0",
+//	    "/ipfs/lan/kad/1.0.0",
+//	    "/ipfs/ping/1.0.0",
+//	    "/libp2p/circuit/relay/0.2.0/stop",
+//	    "/x/"
+//	  ]
+//	}
+type IDResponse struct {
+	ID           string   `json:"ID"`
+	PublicKey    string   `json:"PublicKey"`
+	Addresses    []string `json:"Addresses"`
+	AgentVersion string   `json:"AgentVersion"`
+	Protocols    []string `json:"Protocols"`
+}
+
+// ID returns the identity of the node.
+func (c *Client) ID(ctx context.Context) (*IDResponse, error) {
+	resp, err := c.api.Request("id").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response error: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	idResp := new(IDResponse)
+
+	err = decoder.Decode(idResp)
+	if err != nil {
+		return nil, fmt.Errorf("decoding id response failed: %w", err)
+	}
+
+	return idResp, nil
+}
+
+// DiagSys returns system diagnostic information of the node.
+func (c *Client) DiagSys(ctx context.Context) (*DiagSysResponse, error) {
+	resp, err := c.api.Request("diag/sys").Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response error: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	diagSysResp := new(DiagSysResponse)
+
+	err = decoder.Decode(diagSysResp)
+	if err != nil {
+		return nil, fmt.Errorf("decoding diag/sys response failed: %w", err)
+	}
+
+	return diagSysResp, nil
+}
@@ -0,0 +1,157 @@
+package kubo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedVersion is when Kubo is older than MinVersion.
+var ErrUnsupportedVersion = errors.New("unsupported kubo version")
+
+var (
+	// MinVersion is the oldest Kubo which has all the RPC endpoints used
+	// by the updater.
+	MinVersion = Version{Major: 0, Minor: 18}
+
+	// pinNamesVersion is the first Kubo which keeps the names of the pins.
+	// Older versions ignore the name option.
+	pinNamesVersion = Version{Major: 0, Minor: 26}
+	// routingConfigVersion is the first Kubo which has
+	// Routing.AcceleratedDHTClient, instead of the experimental flag.
+	routingConfigVersion = Version{Major: 0, Minor: 21}
+)
+
+// Version is a Kubo version, like 0.31.0, or 0.32.0-rc1.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Pre is the pre-release, like rc1, or empty for a release.
+	Pre string
+}
+
+// ParseVersion parses a Kubo version, like 0.31.0, or 0.32.0-rc1. The
+// patch version is optional.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+
+	s, v.Pre, _ = strings.Cut(strings.TrimPrefix(s, "v"), "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version: %s", s)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version: %s", s)
+		}
+
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// String formats the version like Kubo.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+
+	return s
+}
+
+// AtLeast is if v is minimum or later. The pre-releases of a version count
+// as the version, since the RPC API doesn't change in between.
+func (v Version) AtLeast(minimum Version) bool {
+	if v.Major != minimum.Major {
+		return v.Major > minimum.Major
+	}
+	if v.Minor != minimum.Minor {
+		return v.Minor > minimum.Minor
+	}
+
+	return v.Patch >= minimum.Patch
+}
+
+// VersionResponse is the version of Kubo, and of what it was built with.
+type VersionResponse struct {
+	Version string `json:"Version"`
+	Commit  string `json:"Commit"`
+	Repo    string `json:"Repo"`
+	System  string `json:"System"`
+	Golang  string `json:"Golang"`
+}
+
+// DetectVersion gets the version of Kubo, and adapts the Client to the
+// differences of its RPC API. It returns an error wrapping
+// ErrUnsupportedVersion if Kubo is older than MinVersion. Until it's called,
+// the Client assumes the latest Kubo.
+func (c *Client) DetectVersion(ctx context.Context) (Version, error) {
+	resp, err := c.api.Request("version").Send(ctx)
+	if err != nil {
+		return Version{}, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.Error != nil {
+		return Version{}, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	defer resp.Output.Close()
+
+	decoder := json.NewDecoder(resp.Output)
+	version := new(VersionResponse)
+
+	err = decoder.Decode(version)
+	if err != nil {
+		return Version{}, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	v, err := ParseVersion(version.Version)
+	if err != nil {
+		return Version{}, err
+	}
+
+	c.version.Store(&v)
+
+	if !v.AtLeast(MinVersion) {
+		return v, fmt.Errorf(
+			"%w: kubo %s is older than %s, upgrade kubo to run the updater",
+			ErrUnsupportedVersion,
+			v,
+			MinVersion,
+		)
+	}
+
+	return v, nil
+}
+
+// supports is if the detected Kubo is version or later, or true if the
+// version wasn't detected.
+func (c *Client) supports(version Version) bool {
+	v := c.version.Load()
+
+	return v == nil || v.AtLeast(version)
+}
+
+// PinNames is if Kubo keeps the names of the pins.
+func (c *Client) PinNames() bool {
+	return c.supports(pinNamesVersion)
+}
+
+// AcceleratedDHTClientKey is the config key which enables the accelerated
+// DHT client, which moved out of Experimental in Kubo 0.21.
+func (c *Client) AcceleratedDHTClientKey() string {
+	if c.supports(routingConfigVersion) {
+		return "Routing.AcceleratedDHTClient"
+	}
+
+	return "Experimental.AcceleratedDHTClient"
+}
@@ -0,0 +1,25 @@
+// Package kubotest provides a fake Kubo node, for tests of code using the
+// Kubo RPC API, like the jobs of the work loop, without running Kubo.
+//
+// The Server keeps the added content, and the pins, in memory, and
+// implements the subset of the RPC endpoints used by the updater: add, pin,
+// ls, files, block/rm, repo/stat, repo/gc, id, diag/sys, swarm/peers,
+// bitswap/ledger, routing/provide, stats/provide, swarm/peering, config,
+// and version.
+// The CIDs have the right format, but they aren't the CIDs Kubo would
+// compute for the same content.
+//
+//	node := kubotest.NewServer()
+//	defer node.Close()
+//
+//	node.SetDisk(10<<30, 100<<30)
+//	dir, file := node.Provide("episode.mp3", data)
+//
+//	k, err := node.Client()
+//
+//	// Fail the pins, like when Kubo can't find any providers.
+//	node.SetError("pin/add", "context deadline exceeded")
+//
+//	// Damage a block, which pin/verify reports.
+//	node.Corrupt(file)
+package kubotest
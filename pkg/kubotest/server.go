@@ -0,0 +1,1047 @@
+package kubotest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/client/rpc"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// PeerID is the ID of the fake node, and of its peers.
+	PeerID = "12D3KooWDpJ7As7BWAwRMfu1VU2WCqNjvq387JEYKDBj4kx6nXTN"
+	// Version is the Kubo version the fake node reports, unless it's
+	// changed with SetVersion.
+	Version = "0.29.0"
+)
+
+// object is a file, with its data, or a directory, with its links.
+type object struct {
+	data  []byte
+	links []link
+}
+
+type link struct {
+	name string
+	hash string
+	size int
+}
+
+// size is the size of the file, or the sum of the sizes of the links of the
+// directory.
+func (o object) size() int {
+	size := len(o.data)
+	for _, l := range o.links {
+		size += l.size
+	}
+
+	return size
+}
+
+// Server is a fake Kubo node, running on a local httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]object
+	// pins are the recursive pins, mapped to their names.
+	pins   map[string]string
+	mfs    map[string]string
+	errors map[string]string
+	// corrupt are the blocks which don't match their CID anymore, like
+	// after a disk error.
+	corrupt map[string]bool
+	// network are the blocks removed with block/rm, which other nodes
+	// still have, so pinning fetches them again.
+	network map[string]object
+	// peering are the peers added to the peering, mapped to their
+	// addresses.
+	peering map[string][]string
+
+	freeSpace  int64
+	totalSpace int64
+	storageMax int
+	peers      int
+	online     bool
+	version    string
+	// ledgerSent is the bytes sent to each peer in the bitswap ledgers.
+	ledgerSent uint64
+	// provideStat is reported by stats/provide.
+	provideStat kubo.ProvideStatResponse
+	// config are the keys set in the config, like Reprovider.Interval,
+	// mapped to their values. Any other key isn't set.
+	config map[string]any
+}
+
+// NewServer starts an online Server, without any content, or peers, and
+// 100 GiB of free disk space. The caller should call Close when finished,
+// to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		objects:    map[string]object{},
+		pins:       map[string]string{},
+		mfs:        map[string]string{},
+		errors:     map[string]string{},
+		corrupt:    map[string]bool{},
+		network:    map[string]object{},
+		peering:    map[string][]string{},
+		config:     map[string]any{},
+		freeSpace:  100 << 30,
+		totalSpace: 200 << 30,
+		storageMax: 100 << 30,
+		online:     true,
+		version:    Version,
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Client creates a kubo.Client for the Server.
+func (s *Server) Client() (*kubo.Client, error) {
+	api, err := rpc.NewURLApiWithClient(s.URL, s.Server.Client())
+	if err != nil {
+		return nil, fmt.Errorf("creating rpc client failed: %w", err)
+	}
+
+	return kubo.New(api), nil
+}
+
+// SetDisk sets the free and total space of the disk reported by diag/sys.
+func (s *Server) SetDisk(freeSpace int64, totalSpace int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.freeSpace = freeSpace
+	s.totalSpace = totalSpace
+}
+
+// SetStorageMax sets the StorageMax reported by repo/stat.
+func (s *Server) SetStorageMax(storageMax int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.storageMax = storageMax
+}
+
+// SetPeers sets the number of connected peers.
+func (s *Server) SetPeers(peers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.peers = peers
+}
+
+// SetLedgerSent sets the bytes sent to the peers, in their bitswap ledgers.
+func (s *Server) SetLedgerSent(sent uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ledgerSent = sent
+}
+
+// SetProvideStat sets the statistics of the reprovider reported by
+// stats/provide.
+func (s *Server) SetProvideStat(stats kubo.ProvideStatResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.provideStat = stats
+}
+
+// SetConfig sets key in the config, like "Reprovider.Interval" to "12h".
+// A nil value removes it from the config.
+func (s *Server) SetConfig(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if value == nil {
+		delete(s.config, key)
+	} else {
+		s.config[key] = value
+	}
+}
+
+// Config returns the value of key in the config, and if it's set.
+func (s *Server) Config(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.config[key]
+
+	return value, ok
+}
+
+// SetVersion sets the Kubo version reported by version and diag/sys, like
+// an older Kubo, to test how clients adapt to it.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version = version
+}
+
+// SetOnline sets if the node reports being online.
+func (s *Server) SetOnline(online bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.online = online
+}
+
+// SetError makes every request for command, like "add" or "pin/add", fail
+// with message. An empty message clears the error.
+func (s *Server) SetError(command string, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if message == "" {
+		delete(s.errors, command)
+	} else {
+		s.errors[command] = message
+	}
+}
+
+// Provide stores data, wrapped in a directory, like it was added to another
+// node on the network, so it can be pinned, without pinning it. It returns
+// the CIDs of the directory and the file.
+func (s *Server) Provide(filename string, data []byte) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addWrapped(filename, data, 0)
+}
+
+// Corrupt makes the stored block hash corrupt, like after a disk error.
+// pin/verify reports it until it's removed with block/rm, and pinning
+// fetches it again.
+func (s *Server) Corrupt(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.corrupt[hash] = true
+}
+
+// Pins returns the recursive pins, mapped to their names.
+func (s *Server) Pins() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins := make(map[string]string, len(s.pins))
+	for hash, name := range s.pins {
+		pins[hash] = name
+	}
+
+	return pins
+}
+
+// Content returns the data of the file hash, and false if there's no such
+// file.
+func (s *Server) Content(hash string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[hash]
+	if !ok || o.links != nil {
+		return nil, false
+	}
+
+	return o.data, true
+}
+
+// MFS returns the MFS paths, mapped to the CIDs copied to them.
+func (s *Server) MFS() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfs := make(map[string]string, len(s.mfs))
+	for path, hash := range s.mfs {
+		mfs[path] = hash
+	}
+
+	return mfs
+}
+
+// Peering returns the IDs of the peers added to the peering, mapped to their
+// addresses.
+func (s *Server) Peering() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peering := make(map[string][]string, len(s.peering))
+	for id, addrs := range s.peering {
+		peering[id] = slices.Clone(addrs)
+	}
+
+	return peering
+}
+
+// addWrapped stores the file, and the directory wrapping it. The file is a
+// raw block, and the directory is dag-pb, with CIDv1, and CIDv0 otherwise.
+func (s *Server) addWrapped(filename string, data []byte, cidVersion int) (string, string) {
+	var fileBuilder, dirBuilder cid.Builder = cid.V0Builder{}, cid.V0Builder{}
+	if cidVersion == 1 {
+		fileBuilder = cid.V1Builder{Codec: cid.Raw, MhType: multihash.SHA2_256}
+		dirBuilder = cid.V1Builder{Codec: cid.DagProtobuf, MhType: multihash.SHA2_256}
+	}
+
+	fileCid, _ := fileBuilder.Sum(data)
+	file := fileCid.String()
+
+	dirCid, _ := dirBuilder.Sum([]byte(filename + "\x00" + file))
+	dir := dirCid.String()
+
+	s.objects[file] = object{
+		data: data,
+	}
+	s.objects[dir] = object{
+		links: []link{{
+			name: filename,
+			hash: file,
+			size: len(data),
+		}},
+	}
+
+	return dir, file
+}
+
+// handle serves /api/v0/<command>.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	command, ok := strings.CutPrefix(r.URL.Path, "/api/v0/")
+	if !ok || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "404 page not found")
+
+		return
+	}
+
+	handlers := map[string]func(http.ResponseWriter, *http.Request) error{
+		"version":           s.handleVersion,
+		"id":                s.handleID,
+		"diag/sys":          s.handleDiagSys,
+		"swarm/peers":       s.handleSwarmPeers,
+		"bitswap/ledger":    s.handleBitswapLedger,
+		"routing/provide":   s.handleRoutingProvide,
+		"stats/provide":     s.handleStatsProvide,
+		"config":            s.handleConfig,
+		"swarm/peering/add": s.handleSwarmPeeringAdd,
+		"swarm/peering/rm":  s.handleSwarmPeeringRm,
+		"repo/stat":         s.handleRepoStat,
+		"repo/gc":           s.handleRepoGC,
+		"add":               s.handleAdd,
+		"pin/add":           s.handlePinAdd,
+		"pin/rm":            s.handlePinRm,
+		"pin/ls":            s.handlePinLs,
+		"pin/verify":        s.handlePinVerify,
+		"block/rm":          s.handleBlockRm,
+		"ls":                s.handleLs,
+		"files/stat":        s.handleFilesStat,
+		"files/mkdir":       s.handleFilesMkdir,
+		"files/cp":          s.handleFilesCp,
+		"files/rm":          s.handleFilesRm,
+	}
+
+	handler, ok := handlers[command]
+	if !ok {
+		writeError(w, http.StatusNotFound, "command not found")
+
+		return
+	}
+
+	s.mu.Lock()
+	message, fail := s.errors[command]
+	s.mu.Unlock()
+
+	if fail {
+		writeError(w, http.StatusInternalServerError, message)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err := handler(w, r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeError writes a Kubo error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"Message": message,
+		"Code":    0,
+		"Type":    "error",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// arg is the first argument of the command.
+func arg(r *http.Request) (string, error) {
+	arg := r.URL.Query().Get("arg")
+	if arg == "" {
+		return "", errors.New("argument is required")
+	}
+
+	return arg, nil
+}
+
+// hashArg is the first argument, without a /ipfs/ prefix.
+func hashArg(r *http.Request) (string, error) {
+	hash, err := arg(r)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(hash, "/ipfs/"), nil
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeJSON(w, map[string]string{
+		"Version": s.version,
+		"Commit":  "",
+		"Repo":    "15",
+		"System":  "amd64/linux",
+		"Golang":  "go1.23",
+	})
+}
+
+func (s *Server) handleID(w http.ResponseWriter, _ *http.Request) error {
+	return writeJSON(w, kubo.IDResponse{
+		ID:           PeerID,
+		AgentVersion: "kubo/" + Version + "/",
+	})
+}
+
+func (s *Server) handleDiagSys(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sys kubo.DiagSysResponse
+
+	sys.DiskInfo.FreeSpace = s.freeSpace
+	sys.DiskInfo.TotalSpace = s.totalSpace
+	sys.DiskInfo.FSType = "ext4"
+	sys.IPFSVersion = s.version
+	sys.Net.Online = s.online
+
+	return writeJSON(w, sys)
+}
+
+func (s *Server) handleSwarmPeers(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type peer struct {
+		Addr string
+		Peer string
+	}
+
+	peers := make([]peer, s.peers)
+	for i := range peers {
+		peers[i] = peer{
+			Addr: "/ip4/127.0.0.1/tcp/" + strconv.Itoa(4001+i),
+			Peer: PeerID,
+		}
+	}
+
+	return writeJSON(w, map[string]any{
+		"Peers": peers,
+	})
+}
+
+func (s *Server) handleBitswapLedger(w http.ResponseWriter, r *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeJSON(w, kubo.BitswapLedgerResponse{
+		Peer:      r.URL.Query().Get("arg"),
+		Value:     1,
+		Sent:      s.ledgerSent,
+		Exchanged: 1,
+	})
+}
+
+// handleRoutingProvide fails like Kubo without peers, or when a block isn't
+// in the repo, and otherwise responds with a single event, of a peer the
+// record was sent to.
+func (s *Server) handleRoutingProvide(w http.ResponseWriter, r *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.peers == 0 {
+		return errors.New("cannot provide, no connected peers")
+	}
+
+	for _, hash := range r.URL.Query()["arg"] {
+		_, ok := s.objects[hash]
+		if !ok {
+			return fmt.Errorf("block %s not found locally, cannot provide", hash)
+		}
+	}
+
+	return writeJSON(w, map[string]any{
+		"ID":   PeerID,
+		"Type": 1,
+	})
+}
+
+// handleSwarmPeeringAdd adds the peers of the addresses, which end with
+// /p2p/ and the peer ID.
+func (s *Server) handleSwarmPeeringAdd(w http.ResponseWriter, r *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.online {
+		return errors.New("this command must be run in online mode. Try running 'ipfs daemon' first")
+	}
+
+	added := map[string][]string{}
+
+	for _, addr := range r.URL.Query()["arg"] {
+		transport, id, ok := strings.Cut(addr, "/p2p/")
+		if !ok || id == "" {
+			return fmt.Errorf("invalid p2p multiaddr: %s", addr)
+		}
+
+		if transport != "" {
+			added[id] = append(added[id], transport)
+		} else if added[id] == nil {
+			added[id] = []string{}
+		}
+	}
+
+	for id, addrs := range added {
+		s.peering[id] = addrs
+
+		err := writeJSON(w, map[string]any{
+			"ID":     id,
+			"Status": "success",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handleSwarmPeeringRm(w http.ResponseWriter, r *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range r.URL.Query()["arg"] {
+		delete(s.peering, id)
+
+		err := writeJSON(w, map[string]any{
+			"ID":     id,
+			"Status": "success",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handleStatsProvide(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.online {
+		return errors.New("this command must be run in online mode. Try running 'ipfs daemon' first")
+	}
+
+	return writeJSON(w, s.provideStat)
+}
+
+// handleConfig gets, or with a second argument sets, a config key. The keys
+// are flat, so setting a key doesn't change its parents, or children, and
+// getting a key which isn't set fails like Kubo.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) error {
+	args := r.URL.Query()["arg"]
+	if len(args) == 0 {
+		return errors.New("argument \"key\" is required")
+	}
+
+	key := args[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(args) > 1 {
+		var value any = args[1]
+
+		if r.URL.Query().Get("json") == "true" {
+			err := json.Unmarshal([]byte(args[1]), &value)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal json. %w", err)
+			}
+		}
+
+		s.config[key] = value
+	}
+
+	value, ok := s.config[key]
+	if !ok {
+		attribute := key[strings.LastIndex(key, ".")+1:]
+
+		return fmt.Errorf("failed to get config value: key has no attribute %s", attribute)
+	}
+
+	return writeJSON(w, map[string]any{
+		"Key":   key,
+		"Value": value,
+	})
+}
+
+func (s *Server) handleRepoStat(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repoSize := 0
+	for _, o := range s.objects {
+		repoSize += len(o.data)
+	}
+
+	return writeJSON(w, kubo.RepoStatResponse{
+		RepoSize:   repoSize,
+		StorageMax: s.storageMax,
+		NumObjects: len(s.objects),
+		RepoPath:   "/kubotest",
+		Version:    "fs-repo@15",
+	})
+}
+
+// handleRepoGC removes the objects which aren't pinned, or in MFS.
+func (s *Server) handleRepoGC(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := map[string]bool{}
+
+	var mark func(hash string)
+	mark = func(hash string) {
+		keep[hash] = true
+		for _, l := range s.objects[hash].links {
+			mark(l.hash)
+		}
+	}
+
+	for hash := range s.pins {
+		mark(hash)
+	}
+	for _, hash := range s.mfs {
+		mark(hash)
+	}
+
+	for hash := range s.objects {
+		if keep[hash] {
+			continue
+		}
+
+		delete(s.objects, hash)
+
+		err := writeJSON(w, map[string]any{
+			"Key": map[string]string{"/": hash},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleAdd adds the files of the multipart body, wrapped in a directory.
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	if query.Get("wrap-with-directory") != "true" {
+		return errors.New("kubotest only supports wrap-with-directory")
+	}
+
+	cidVersion, _ := strconv.Atoi(query.Get("cid-version"))
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("reading multipart body failed: %w", err)
+	}
+
+	part, err := reader.NextPart()
+	if err != nil {
+		return fmt.Errorf("reading file failed: %w", err)
+	}
+
+	filename := part.FileName()
+
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return fmt.Errorf("reading file failed: %w", err)
+	}
+
+	s.mu.Lock()
+	dir, file := s.addWrapped(filename, data, cidVersion)
+	if query.Get("pin") != "false" {
+		s.pins[dir] = ""
+	}
+	s.mu.Unlock()
+
+	for _, added := range []kubo.AddResponse{
+		{Name: filename, Hash: file, Size: len(data)},
+		{Name: "", Hash: dir, Size: len(data)},
+	} {
+		err := writeJSON(w, map[string]string{
+			"Name": added.Name,
+			"Hash": added.Hash,
+			"Size": strconv.Itoa(added.Size),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handlePinAdd(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hashArg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.fetch(hash)
+	if err != nil {
+		return err
+	}
+
+	s.pins[hash] = r.URL.Query().Get("name")
+
+	return writeJSON(w, map[string]any{
+		"Pins": []string{hash},
+	})
+}
+
+func (s *Server) handlePinRm(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hashArg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pins[hash]; !ok {
+		return errors.New("not pinned or pinned indirectly")
+	}
+
+	delete(s.pins, hash)
+
+	return writeJSON(w, map[string]any{
+		"Pins": []string{hash},
+	})
+}
+
+// fetch fetches the blocks of hash which were removed from the network,
+// like pinning does. Blocks which no node has fail like Kubo, when it can't
+// find any providers.
+func (s *Server) fetch(hash string) error {
+	o, ok := s.objects[hash]
+	if !ok {
+		o, ok = s.network[hash]
+		if !ok {
+			return fmt.Errorf("%s: context deadline exceeded", hash)
+		}
+
+		s.objects[hash] = o
+	}
+
+	for _, l := range o.links {
+		err := s.fetch(l.hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pinnedBy returns the recursive pin which hash is part of, and false if
+// it's not pinned.
+func (s *Server) pinnedBy(hash string) (string, bool) {
+	var reachable func(root string) bool
+	reachable = func(root string) bool {
+		if root == hash {
+			return true
+		}
+
+		for _, l := range s.objects[root].links {
+			if reachable(l.hash) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for pin := range s.pins {
+		if reachable(pin) {
+			return pin, true
+		}
+	}
+
+	return "", false
+}
+
+// handlePinVerify traverses the blocks of the recursive pins, and streams
+// the pins with missing or corrupt blocks.
+func (s *Server) handlePinVerify(w http.ResponseWriter, _ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for pin := range s.pins {
+		var badNodes []kubo.BadNode
+
+		var verify func(hash string)
+		verify = func(hash string) {
+			o, ok := s.objects[hash]
+
+			switch {
+			case !ok:
+				badNodes = append(badNodes, kubo.BadNode{Cid: hash, Err: "merkledag: not found"})
+			case s.corrupt[hash]:
+				badNodes = append(badNodes, kubo.BadNode{Cid: hash, Err: "block in storage has different hash than requested"})
+			}
+
+			for _, l := range o.links {
+				verify(l.hash)
+			}
+		}
+
+		verify(pin)
+
+		if len(badNodes) == 0 {
+			continue
+		}
+
+		err := writeJSON(w, kubo.PinVerifyResponse{
+			Cid:      pin,
+			Ok:       false,
+			BadNodes: badNodes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleBlockRm removes a block, unless it's pinned, which is reported in
+// the output, like Kubo does, instead of failing the request.
+func (s *Server) handleBlockRm(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hashArg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := map[string]string{"Hash": hash}
+
+	o, ok := s.objects[hash]
+	pin, pinned := s.pinnedBy(hash)
+
+	switch {
+	case !ok && r.URL.Query().Get("force") != "true":
+		removed["Error"] = "blockservice: key not found"
+	case pinned && pin == hash:
+		removed["Error"] = "pinned: recursive"
+	case pinned:
+		removed["Error"] = fmt.Sprintf("pinned via %s", pin)
+	case ok:
+		delete(s.objects, hash)
+		delete(s.corrupt, hash)
+
+		s.network[hash] = o
+	}
+
+	return writeJSON(w, removed)
+}
+
+// handlePinLs lists the recursive pins, as a stream, or checks if the
+// argument is pinned.
+func (s *Server) handlePinLs(w http.ResponseWriter, r *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := strings.TrimPrefix(r.URL.Query().Get("arg"), "/ipfs/")
+	if hash != "" {
+		name, ok := s.pins[hash]
+		if !ok {
+			return fmt.Errorf("path '%s' is not pinned", hash)
+		}
+
+		return writeJSON(w, map[string]any{
+			"Keys": map[string]any{
+				hash: map[string]string{
+					"Type": "recursive",
+					"Name": name,
+				},
+			},
+		})
+	}
+
+	for hash, name := range s.pins {
+		err := writeJSON(w, map[string]string{
+			"Cid":  hash,
+			"Name": name,
+			"Type": "recursive",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handleLs(w http.ResponseWriter, r *http.Request) error {
+	hash, err := hashArg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[hash]
+	if !ok {
+		return fmt.Errorf("%s: context deadline exceeded", hash)
+	}
+
+	links := make([]kubo.LsLink, 0, len(o.links))
+	for _, l := range o.links {
+		links = append(links, kubo.LsLink{
+			Name: l.name,
+			Hash: l.hash,
+			Size: l.size,
+			Type: 2,
+		})
+	}
+
+	return writeJSON(w, kubo.LsResponse{
+		Objects: []kubo.LsObject{{
+			Hash:  hash,
+			Links: links,
+		}},
+	})
+}
+
+func (s *Server) handleFilesStat(w http.ResponseWriter, r *http.Request) error {
+	path, err := arg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, ok := strings.CutPrefix(path, "/ipfs/")
+	if !ok {
+		hash, ok = s.mfs[path]
+		if !ok {
+			return fmt.Errorf("file does not exist")
+		}
+	}
+
+	o, ok := s.objects[hash]
+	if !ok {
+		return fmt.Errorf("%s: context deadline exceeded", hash)
+	}
+
+	stat := kubo.FilesStatResponse{
+		Hash:           hash,
+		Size:           len(o.data),
+		CumulativeSize: o.size(),
+		Blocks:         len(o.links),
+		Type:           "file",
+	}
+	if o.links != nil {
+		stat.Type = "directory"
+	}
+
+	return writeJSON(w, stat)
+}
+
+func (s *Server) handleFilesMkdir(_ http.ResponseWriter, r *http.Request) error {
+	_, err := arg(r)
+
+	return err
+}
+
+func (s *Server) handleFilesCp(_ http.ResponseWriter, r *http.Request) error {
+	args := r.URL.Query()["arg"]
+	if len(args) != 2 {
+		return errors.New("source and destination are required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := strings.TrimPrefix(args[0], "/ipfs/")
+	if _, ok := s.objects[hash]; !ok {
+		return fmt.Errorf("%s: context deadline exceeded", hash)
+	}
+
+	if _, ok := s.mfs[args[1]]; ok {
+		return errors.New("directory already has entry by that name")
+	}
+
+	s.mfs[args[1]] = hash
+
+	return nil
+}
+
+func (s *Server) handleFilesRm(_ http.ResponseWriter, r *http.Request) error {
+	path, err := arg(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for mfsPath := range s.mfs {
+		if mfsPath == path || strings.HasPrefix(mfsPath, strings.TrimSuffix(path, "/")+"/") {
+			delete(s.mfs, mfsPath)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+// Package metrics contains the Prometheus metrics of the updater. They are
+// registered with the default registry.
+//
+// The metrics of a node, and of its Kubo stats, have a node label with the
+// name of the updater, so several work loops in one process can be told
+// apart. BuildInfo is the only metric of the process itself.
+package metrics
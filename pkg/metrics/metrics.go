@@ -10,6 +10,19 @@ import (
 var (
 	namespace = "ipfspodcasting_updater"
 
+	BuildInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Always 1. The labels describe the build of the updater",
+		},
+		[]string{
+			"version",
+			"commit",
+			"build_date",
+			"go_version",
+		},
+	)
 	JobsHistogram = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: namespace,
@@ -17,40 +30,642 @@ var (
 			Help:      "Time spent on a job",
 		},
 		[]string{
+			"node",
 			"job_type",
 			"status",
 		},
 	)
-	IPFSPeers = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "peers",
-		Help:      "Number of connected IPFS peers",
-	})
-	IPFSRepoDiskUsage = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "repo_disk_used_bytes",
-		Help:      "IPFS repo disk usage",
-	})
-	IPFSRepoStorageMax = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "repo_storage_max_bytes",
-		Help:      "IPFS repo max storage limit",
-	})
-	IPFSRepoObjects = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: namespace,
-		Name:      "repo_objects",
-		Help:      "Number of IPFS repo objects",
-	})
+	JobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_total",
+			Help:      "Number of jobs",
+		},
+		[]string{
+			"node",
+			"job_type",
+			"status",
+		},
+	)
+	BytesDownloaded = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_downloaded_total",
+			Help:      "Bytes of episodes hosted by download jobs",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BytesPinned = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_pinned_total",
+			Help:      "Bytes of episodes hosted by pin jobs",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PinsExpired = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pins_expired_total",
+			Help:      "Number of episodes unpinned because their expiry passed",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PinsEvicted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pins_evicted_total",
+			Help:      "Number of episodes unpinned to bring the storage used below the low-water mark",
+		},
+		[]string{
+			"node",
+		},
+	)
+	Deletes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deletes_total",
+			Help:      "Number of episodes deleted by delete jobs",
+		},
+		[]string{
+			"node",
+		},
+	)
+	AccountJobs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "account_jobs_total",
+			Help:      "Jobs by the account which requested them, on nodes which work for several accounts",
+		},
+		[]string{
+			"node",
+			"account",
+			"job_type",
+			"status",
+		},
+	)
+	ShowJobs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "show_jobs_total",
+			Help:      "Jobs per show. Shows over the label limit are counted as other",
+		},
+		[]string{
+			"node",
+			"show",
+			"job_type",
+			"status",
+		},
+	)
+	DownloadThroughput = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "download_throughput_bytes_per_second",
+			Help:      "Speed of episode downloads from the origin into Kubo",
+			Buckets:   prometheus.ExponentialBuckets(16*1024, 2, 14),
+		},
+		[]string{
+			"node",
+		},
+	)
+	DownloadSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "download_size_bytes",
+			Help:      "Size of downloaded episodes",
+			Buckets:   prometheus.ExponentialBuckets(1_000_000, 2, 12),
+		},
+		[]string{
+			"node",
+		},
+	)
+	DownloadProgress = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "download_progress_bytes",
+			Help:      "Bytes of the in-flight download added to Kubo so far. 0 when no download is running",
+		},
+		[]string{
+			"node",
+		},
+	)
+	DownloadExpected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "download_expected_bytes",
+			Help:      "Content-Length of the in-flight download. 0 when it's unknown, or no download is running",
+		},
+		[]string{
+			"node",
+		},
+	)
+	Downloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downloads_total",
+			Help:      "Episode downloads, by the host at the end of the redirects, and the HTTP status, or error",
+		},
+		[]string{
+			"node",
+			"host",
+			"status",
+		},
+	)
+	DownloadRedirects = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "download_redirects",
+			Help:      "Number of redirects followed by episode downloads",
+			Buckets:   prometheus.LinearBuckets(0, 1, 11),
+		},
+		[]string{
+			"node",
+		},
+	)
+	FeedEpisodes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "feed_episodes_total",
+			Help:      "Episodes of self-hosted feeds, by status",
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	EpisodesPinned = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "episodes_pinned",
+			Help:      "Episodes in the catalog of the node",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PinnedBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pinned_bytes",
+			Help:      "Total size of the episodes in the catalog of the node",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ShowsHosted = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "shows_hosted",
+			Help:      "Distinct shows of the episodes in the catalog of the node",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PendingResponses = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_responses",
+			Help:      "Results of jobs which couldn't be sent to the server yet",
+		},
+		[]string{
+			"node",
+		},
+	)
+	MirrorUploads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mirror_uploads_total",
+			Help:      "Episodes uploaded to the S3 mirror, by status",
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	Replications = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "replications_total",
+			Help:      "Episodes uploaded to web3.storage, by status",
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	ArchiveSubmissions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "archive_submissions_total",
+			Help:      "Episodes submitted for Filecoin archival, by status",
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	ArchivedEpisodes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "archived_episodes",
+			Help:      "Episodes in the catalog by the state of their Filecoin deals",
+		},
+		[]string{
+			"node",
+			"state",
+		},
+	)
+	KuboUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "kubo_up",
+			Help:      "1 if Kubo was reachable at the last work cycle, or check of the watchdog, 0 if not",
+		},
+		[]string{
+			"node",
+		},
+	)
+	WorkServerCircuitOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "work_server_circuit_open",
+			Help:      "1 while work requests are paused, after the work server failed repeatedly, 0 if not",
+		},
+		[]string{
+			"node",
+		},
+	)
+	IPFSPeers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "peers",
+			Help:      "Number of connected IPFS peers",
+		},
+		[]string{
+			"node",
+		},
+	)
+	IPFSRepoDiskUsage = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "repo_disk_used_bytes",
+			Help:      "IPFS repo disk usage",
+		},
+		[]string{
+			"node",
+		},
+	)
+	IPFSRepoStorageMax = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "repo_storage_max_bytes",
+			Help:      "IPFS repo max storage limit",
+		},
+		[]string{
+			"node",
+		},
+	)
+	IPFSRepoObjects = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "repo_objects",
+			Help:      "Number of IPFS repo objects",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BitswapBlocks = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bitswap_blocks",
+			Help:      "Blocks exchanged over bitswap since Kubo started",
+		},
+		[]string{
+			"node",
+			"direction",
+		},
+	)
+	BitswapBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bitswap_bytes",
+			Help:      "Bytes exchanged over bitswap since Kubo started",
+		},
+		[]string{
+			"node",
+			"direction",
+		},
+	)
+	Provides = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "provides_total",
+			Help:      "Announcements of pinned episodes to the DHT, by result",
+		},
+		[]string{
+			"node",
+			"result",
+		},
+	)
+	ProvideDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "provide_duration_seconds",
+			Help:      "Time to announce a pinned episode to the DHT",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		},
+		[]string{
+			"node",
+		},
+	)
+	RetrievalChecks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retrieval_checks_total",
+			Help:      "Checks if pinned episodes are retrievable through the public gateway, by result",
+		},
+		[]string{
+			"node",
+			"result",
+		},
+	)
+	Retrievable = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "retrievable",
+			Help:      "1 if the last pinned episode which was checked was retrievable through the public gateway, 0 if not",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BytesServed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_served_total",
+			Help:      "Bytes sent to peers over bitswap, from the samples of the bitswap ledgers",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PeersServed = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "peers_served",
+			Help:      "Peers which were sent bytes over bitswap between the last two samples of the bitswap ledgers",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ReprovideBatchSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "reprovide_batch_size",
+			Help:      "CIDs announced to the DHT by Kubo's last reprovide",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ReprovideDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_reprovide_duration_seconds",
+			Help:      "How long Kubo's last reprovide took",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ProvideAvgDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "provide_avg_duration_seconds",
+			Help:      "Average time Kubo takes to announce a CID to the DHT",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ReprovideInterval = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "reprovide_interval_seconds",
+			Help:      "How often Kubo reprovides its content, from Reprovider.Interval",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ReproviderBehind = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "reprovider_behind",
+			Help:      "1 if Kubo's last reprovide took longer than the reprovide interval, so the announcements of some content expire",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PeeringPeers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "peering_peers",
+			Help:      "Backbone peers listed by the work server which are in Kubo's peering",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BitswapDuplicateBlocks = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bitswap_duplicate_blocks_received",
+			Help:      "Blocks received over bitswap which were already in the repo",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BitswapWantlist = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bitswap_wantlist_blocks",
+			Help:      "Number of blocks in the bitswap wantlist",
+		},
+		[]string{
+			"node",
+		},
+	)
+	BandwidthBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bandwidth_bytes",
+			Help:      "Bytes of network traffic since Kubo started",
+		},
+		[]string{
+			"node",
+			"direction",
+		},
+	)
+	BandwidthRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bandwidth_rate_bytes_per_second",
+			Help:      "Current rate of network traffic",
+		},
+		[]string{
+			"node",
+			"direction",
+		},
+	)
+	DiskFreeBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "disk_free_bytes",
+			Help:      "Free space on the disk of the IPFS repo",
+		},
+		[]string{
+			"node",
+		},
+	)
+	DiskLow = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "disk_low",
+			Help:      "1 if the free disk space is below the minimum, and downloads and pins are declined",
+		},
+		[]string{
+			"node",
+		},
+	)
+	GCHistogram = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "gc_seconds",
+			Help:      "Time spent on repo garbage collection",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	GCReclaimedBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "gc_reclaimed_bytes_total",
+			Help:      "Bytes reclaimed by repo garbage collection",
+		},
+		[]string{
+			"node",
+		},
+	)
+	VerifyHistogram = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "verify_seconds",
+			Help:      "Time spent verifying the blocks of the pinned episodes",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	VerifyBadPins = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verify_bad_pins_total",
+			Help:      "Pinned episodes found with missing or corrupt blocks",
+		},
+		[]string{
+			"node",
+		},
+	)
+	VerifyRepairs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verify_repairs_total",
+			Help:      "Repins of episodes with missing or corrupt blocks",
+		},
+		[]string{
+			"node",
+			"status",
+		},
+	)
+	LastAttempt = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_attempt_timestamp_seconds",
+			Help:      "Unix time of the last work cycle",
+		},
+		[]string{
+			"node",
+		},
+	)
+	LastSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last work cycle which completed without an error",
+		},
+		[]string{
+			"node",
+		},
+	)
+	ServerRateLimitWait = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "server_rate_limit_wait_seconds_total",
+			Help:      "Time spent waiting for the rate limit of the requests to the work server",
+		},
+		[]string{
+			"node",
+		},
+	)
+	PushConnected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "work_server_events_connected",
+			Help:      "Whether the event stream of the work server is connected",
+		},
+		[]string{
+			"node",
+		},
+	)
+	UpdateFrequency = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "update_frequency_seconds",
+			Help:      "Current effective time between work requests",
+		},
+		[]string{
+			"node",
+		},
+	)
 )
 
-func ObserveJob(jobType string, isErr bool, duration time.Duration) {
-	status := "success"
-	if isErr {
-		status = "error"
-	}
-
-	JobsHistogram.With(prometheus.Labels{
+// ObserveJob records a job's duration on the node, and counts it. status is
+// "success", or the failure category, like "error" or "too_small".
+func ObserveJob(node string, jobType string, status string, duration time.Duration) {
+	labels := prometheus.Labels{
+		"node":     node,
 		"job_type": jobType,
 		"status":   status,
-	}).Observe(duration.Seconds())
+	}
+
+	JobsHistogram.With(labels).Observe(duration.Seconds())
+	JobsTotal.With(labels).Inc()
 }
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Text formats the event as a short message for chat platforms.
+func Text(event Event) string {
+	sb := new(strings.Builder)
+
+	fmt.Fprintf(sb, "[%s] %s", event.Node, event.Message)
+
+	keys := make([]string, 0, len(event.Fields))
+	for key := range event.Fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(sb, "\n%s: %v", key, event.Fields[key])
+	}
+
+	return sb.String()
+}
+
+// chatSender sends the event's Text as JSON to a chat platform.
+type chatSender struct {
+	httpClient *http.Client
+	method     string
+	url        string
+	header     http.Header
+	body       func(text string) any
+}
+
+func (c *chatSender) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(c.body(Text(event)))
+	if err != nil {
+		return fmt.Errorf("encoding message failed: %w", err)
+	}
+
+	return postWithRetries(ctx, 3, func() error {
+		req, err := http.NewRequestWithContext(ctx, c.method, c.url, bytes.NewReader(body))
+		if err != nil {
+			return permanent(fmt.Errorf("creating request failed: %w", err))
+		}
+
+		req.Header = c.header.Clone()
+		req.Header.Set("Content-Type", "application/json")
+
+		return doRequest(c.httpClient, req)
+	})
+}
+
+// NewDiscord creates a Sender which posts to a Discord webhook.
+func NewDiscord(httpClient *http.Client, webhookURL string) Sender {
+	return &chatSender{
+		httpClient: httpClient,
+		method:     http.MethodPost,
+		url:        webhookURL,
+		header:     http.Header{},
+		body: func(text string) any {
+			return map[string]string{"content": text}
+		},
+	}
+}
+
+// NewSlack creates a Sender which posts to a Slack incoming webhook.
+func NewSlack(httpClient *http.Client, webhookURL string) Sender {
+	return &chatSender{
+		httpClient: httpClient,
+		method:     http.MethodPost,
+		url:        webhookURL,
+		header:     http.Header{},
+		body: func(text string) any {
+			return map[string]string{"text": text}
+		},
+	}
+}
+
+// NewTelegram creates a Sender which sends messages to a Telegram chat
+// through a bot.
+func NewTelegram(httpClient *http.Client, botToken string, chatID string) Sender {
+	return &chatSender{
+		httpClient: httpClient,
+		method:     http.MethodPost,
+		url:        "https://api.telegram.org/bot" + botToken + "/sendMessage",
+		header:     http.Header{},
+		body: func(text string) any {
+			return map[string]string{
+				"chat_id": chatID,
+				"text":    text,
+			}
+		},
+	}
+}
+
+// matrixSender sends messages to a Matrix room. Each message needs its own
+// transaction ID in the URL, so it can't be a plain chatSender.
+type matrixSender struct {
+	httpClient  *http.Client
+	homeserver  string
+	roomID      string
+	accessToken string
+}
+
+// NewMatrix creates a Sender which sends messages to a Matrix room, as the
+// user of the access token, who has to be in the room.
+func NewMatrix(httpClient *http.Client, homeserver string, roomID string, accessToken string) Sender {
+	return &matrixSender{
+		httpClient:  httpClient,
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		roomID:      roomID,
+		accessToken: accessToken,
+	}
+}
+
+func (m *matrixSender) Send(ctx context.Context, event Event) error {
+	txnID := make([]byte, 8)
+
+	_, err := rand.Read(txnID)
+	if err != nil {
+		return fmt.Errorf("generating transaction id failed: %w", err)
+	}
+
+	sender := &chatSender{
+		httpClient: m.httpClient,
+		method:     http.MethodPut,
+		url: m.homeserver +
+			"/_matrix/client/v3/rooms/" + url.PathEscape(m.roomID) +
+			"/send/m.room.message/" + hex.EncodeToString(txnID),
+		header: http.Header{
+			"Authorization": []string{"Bearer " + m.accessToken},
+		},
+		body: func(text string) any {
+			return map[string]string{
+				"msgtype": "m.text",
+				"body":    text,
+			}
+		},
+	}
+
+	return sender.Send(ctx, event)
+}
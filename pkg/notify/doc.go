@@ -0,0 +1,8 @@
+// Package notify sends events about an IPFS Podcasting node, like failed
+// jobs or low disk space, to the operator, through webhooks, chats, and
+// email.
+//
+// A Notifier sends each Event to all its senders. Only wraps a sender, so
+// it's only sent some types of events, like sending the failures to a chat,
+// and everything to a webhook.
+package notify
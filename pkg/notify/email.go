@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailConfig is the SMTP server and addresses of an Email sender.
+type EmailConfig struct {
+	Host string
+	// Port of the SMTP server. Port 465 uses implicit TLS, other ports use
+	// STARTTLS when the server supports it. Defaults to 587.
+	Port int
+	// Username and Password authenticate with PLAIN auth, when set.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Email sends events as emails through an SMTP server.
+type Email struct {
+	config EmailConfig
+}
+
+// NewEmail creates an Email sender.
+func NewEmail(config EmailConfig) *Email {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+
+	return &Email{
+		config: config,
+	}
+}
+
+// message is the email of the event, with the headers.
+func (e *Email) message(event Event) []byte {
+	buf := new(bytes.Buffer)
+
+	subject := fmt.Sprintf("[%s] %s", event.Node, event.Message)
+
+	fmt.Fprintf(buf, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+
+	body := Text(event) + "\n\nEvent: " + string(event.Type) + "\n"
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
+
+// Send emails the event to the configured addresses.
+func (e *Email) Send(ctx context.Context, event Event) error {
+	address := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if e.config.Port == 465 {
+		dialer := &tls.Dialer{
+			Config: &tls.Config{ServerName: e.config.Host},
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		dialer := new(net.Dialer)
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+
+	if err != nil {
+		return fmt.Errorf("connecting to smtp server failed: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		return fmt.Errorf("smtp handshake failed: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: e.config.Host})
+		if err != nil {
+			return fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+
+	if e.config.Username != "" {
+		auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+
+		err = client.Auth(auth)
+		if err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	err = client.Mail(e.config.From)
+	if err != nil {
+		return fmt.Errorf("smtp mail failed: %w", err)
+	}
+
+	for _, to := range e.config.To {
+		err = client.Rcpt(to)
+		if err != nil {
+			return fmt.Errorf("smtp rcpt %s failed: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data failed: %w", err)
+	}
+
+	_, err = w.Write(e.message(event))
+	if err != nil {
+		return fmt.Errorf("writing email failed: %w", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("sending email failed: %w", err)
+	}
+
+	return client.Quit()
+}
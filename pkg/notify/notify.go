@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// EventJobFailed is sent when a job fails.
+	EventJobFailed EventType = "job_failed"
+	// EventDiskLow is sent when the free space on the Kubo repo's disk
+	// drops below the minimum.
+	EventDiskLow EventType = "disk_low"
+	// EventKuboUnreachable is sent when the Kubo node stops responding.
+	EventKuboUnreachable EventType = "kubo_unreachable"
+	// EventKuboIdentityChanged is sent when the ID of the Kubo node changed
+	// between two work cycles.
+	EventKuboIdentityChanged EventType = "kubo_identity_changed"
+	// EventNoWork is sent after a number of work cycles in a row without
+	// any work.
+	EventNoWork EventType = "no_work"
+	// EventFailing is sent when every work cycle failed for a while.
+	EventFailing EventType = "failing"
+)
+
+// Event is something about a node which the operator should know about.
+type Event struct {
+	Type    EventType `json:"type"`
+	Node    string    `json:"node"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	// Fields are details of the event, like the job type of a failed job.
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// Sender sends events to somewhere the operator will see them.
+type Sender interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Notifier sends events to all of its senders.
+type Notifier struct {
+	senders []Sender
+	timeout time.Duration
+}
+
+// New creates a Notifier which sends events to the senders.
+func New(senders ...Sender) *Notifier {
+	return &Notifier{
+		senders: senders,
+		timeout: time.Minute,
+	}
+}
+
+// Send sends the event to all the senders, and returns the errors of the
+// ones which failed.
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	var errs []error
+
+	for _, sender := range n.senders {
+		err := sender.Send(ctx, event)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Notify sends the event in the background, so a slow or broken sender
+// doesn't hold up the caller. Failures are logged.
+func (n *Notifier) Notify(event Event) {
+	if len(n.senders) == 0 {
+		return
+	}
+
+	go func() {
+		err := n.Send(context.Background(), event)
+		if err != nil {
+			slog.Error("sending notification failed", "type", event.Type, "node", event.Node, "err", err)
+		}
+	}()
+}
+
+// filtered only sends the events of some types.
+type filtered struct {
+	sender Sender
+	types  map[EventType]struct{}
+}
+
+// Only wraps the sender so it's only sent events of the given types.
+func Only(sender Sender, types ...EventType) Sender {
+	f := &filtered{
+		sender: sender,
+		types:  map[EventType]struct{}{},
+	}
+
+	for _, t := range types {
+		f.types[t] = struct{}{}
+	}
+
+	return f
+}
+
+func (f *filtered) Send(ctx context.Context, event Event) error {
+	_, ok := f.types[event.Type]
+	if !ok {
+		return nil
+	}
+
+	return f.sender.Send(ctx, event)
+}
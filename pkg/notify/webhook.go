@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader contains the HMAC-SHA256 of the body of a webhook, in the
+// form sha256=<hex>, when the webhook has a secret.
+const SignatureHeader = "X-Ipfspodcasting-Signature"
+
+// Webhook POSTs events as JSON to a URL.
+type Webhook struct {
+	httpClient *http.Client
+	url        string
+	secret     []byte
+	retries    int
+}
+
+// NewWebhook creates a Webhook which POSTs to url. If secret isn't empty,
+// the body is signed with it, so the receiver can check the events came
+// from this node.
+func NewWebhook(httpClient *http.Client, url string, secret string) *Webhook {
+	return &Webhook{
+		httpClient: httpClient,
+		url:        url,
+		secret:     []byte(secret),
+		retries:    3,
+	}
+}
+
+// Sign is the value of the SignatureHeader for the body.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs the event, retrying a few times on connection and server
+// errors.
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding event failed: %w", err)
+	}
+
+	return postWithRetries(ctx, w.retries, func() error {
+		return w.post(ctx, body)
+	})
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("creating request failed: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(w.secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	return doRequest(w.httpClient, req)
+}
+
+// permanentError is an error which retrying won't fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// doRequest sends the request, and returns an error if the response isn't
+// successful. Client errors are permanent.
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return permanent(fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode))
+	}
+
+	return nil
+}
+
+// postWithRetries calls post until it succeeds, returns a permanent error,
+// or it has been retried retries times, waiting longer between each try.
+func postWithRetries(ctx context.Context, retries int, post func() error) error {
+	wait := time.Second
+
+	for {
+		err := post()
+		if err == nil {
+			return nil
+		}
+
+		var permanentErr permanentError
+		if retries == 0 || errors.As(err, &permanentErr) {
+			return err
+		}
+
+		retries -= 1
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+	}
+}
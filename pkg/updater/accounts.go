@@ -0,0 +1,67 @@
+package updater
+
+import (
+	"slices"
+	"sync"
+)
+
+// accounts are the emails of the accounts the node works for. The work
+// requests take turns between them.
+type accounts struct {
+	emails []string
+
+	mu   sync.Mutex
+	turn int
+	// announced is the account the server announced work for, which
+	// requests the next work out of turn.
+	announced string
+}
+
+func newAccounts(email string, emails []string) *accounts {
+	all := []string{email}
+
+	for _, email := range emails {
+		if !slices.Contains(all, email) {
+			all = append(all, email)
+		}
+	}
+
+	return &accounts{
+		emails: all,
+	}
+}
+
+// next returns the email of the next work request.
+func (a *accounts) next() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.announced != "" {
+		email := a.announced
+		a.announced = ""
+
+		return email
+	}
+
+	email := a.emails[a.turn%len(a.emails)]
+	a.turn += 1
+
+	return email
+}
+
+// announce makes email request the next work, if it's one of the accounts.
+func (a *accounts) announce(email string) {
+	if !slices.Contains(a.emails, email) {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.announced = email
+}
+
+// several is if the node works for more than one account.
+func (a *accounts) several() bool {
+	return len(a.emails) > 1
+}
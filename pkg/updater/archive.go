@@ -0,0 +1,156 @@
+package updater
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/filecoin"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// archiveEpisodesPerCycle is the number of episodes submitted for archival
+// per work cycle, so the API has to fetch them from the node slowly.
+const archiveEpisodesPerCycle = 10
+
+// archivePeers returns the node's addresses, so the archive API can fetch
+// the episodes from it directly.
+func (u *Updater) archivePeers(ctx context.Context) []filecoin.Peer {
+	id, err := u.kubo.ID(ctx)
+	if err != nil {
+		u.log.Warn("getting node id failed", "err", err)
+
+		return nil
+	}
+
+	addrs := make([]string, 0, len(id.Addresses))
+	for _, addr := range id.Addresses {
+		addr, _, _ = strings.Cut(addr, "/p2p/")
+		addrs = append(addrs, addr)
+	}
+
+	return []filecoin.Peer{{ID: id.ID, Addrs: addrs}}
+}
+
+// archive submits the episodes which were hosted for longer than
+// ArchiveAfter, and aren't archived yet, or their deals failed. Then it
+// updates the state of the deals which aren't active yet.
+func (u *Updater) archive(ctx context.Context) {
+	var peers []filecoin.Peer
+
+	budget := archiveEpisodesPerCycle
+	states := map[string]int{
+		filecoin.StateFailed:   0,
+		filecoin.StateQueued:   0,
+		filecoin.StateProposed: 0,
+		filecoin.StateActive:   0,
+	}
+
+	for _, episode := range u.opts.Catalog.Episodes() {
+		archive := episode.Archive
+
+		switch {
+		case archive == nil || archive.State == filecoin.StateFailed:
+			if budget == 0 || time.Since(episode.Added) < u.opts.ArchiveAfter {
+				break
+			}
+
+			budget -= 1
+
+			if peers == nil {
+				peers = u.archivePeers(ctx)
+			}
+
+			archive = u.submitArchive(ctx, episode, peers)
+		case archive.State != filecoin.StateActive:
+			archive = u.updateArchive(ctx, episode)
+		}
+
+		if archive != nil {
+			states[archive.State] += 1
+		}
+	}
+
+	for state, count := range states {
+		metrics.ArchivedEpisodes.WithLabelValues(u.opts.Name, state).Set(float64(count))
+	}
+}
+
+// submitArchive adds the episode to the archive API, and returns its new
+// archival, or the previous one if it failed.
+func (u *Updater) submitArchive(ctx context.Context, episode catalog.Episode, peers []filecoin.Peer) *catalog.Archive {
+	log := u.log.With("cid", episode.Hash, "show", episode.Show, "episode", episode.Episode)
+
+	name := pinName(&workapi.Work{Show: episode.Show, Episode: episode.Episode})
+	if name == "" {
+		name = episode.Hash
+	}
+
+	id, err := u.opts.Archiver.Add(ctx, episode.Hash, name, peers)
+	if err != nil {
+		metrics.ArchiveSubmissions.WithLabelValues(u.opts.Name, "failed").Inc()
+		log.Error("submitting archive failed", "err", err)
+
+		return episode.Archive
+	}
+
+	metrics.ArchiveSubmissions.WithLabelValues(u.opts.Name, "ok").Inc()
+	log.Info("submitted archive", "content_id", id)
+
+	archive := catalog.Archive{
+		ContentID: id,
+		State:     filecoin.StateQueued,
+		Updated:   time.Now(),
+	}
+
+	err = u.opts.Catalog.SetArchive(episode.Hash, archive)
+	if err != nil {
+		log.Error("saving archive failed", "err", err)
+	}
+
+	return &archive
+}
+
+// updateArchive checks the deals of the episode, and returns its updated
+// archival, or the previous one if it failed.
+func (u *Updater) updateArchive(ctx context.Context, episode catalog.Episode) *catalog.Archive {
+	log := u.log.With("cid", episode.Hash, "content_id", episode.Archive.ContentID)
+
+	status, err := u.opts.Archiver.Status(ctx, episode.Archive.ContentID)
+	if err != nil {
+		log.Error("checking archive failed", "err", err)
+
+		return episode.Archive
+	}
+
+	archive := catalog.Archive{
+		ContentID: episode.Archive.ContentID,
+		State:     status.State(),
+		Deals:     status.ActiveDeals(),
+		Updated:   time.Now(),
+	}
+
+	if archive.State != episode.Archive.State {
+		log.Info("archive state changed", "from", episode.Archive.State, "to", archive.State, "deals", archive.Deals)
+	}
+
+	err = u.opts.Catalog.SetArchive(episode.Hash, archive)
+	if err != nil {
+		log.Error("saving archive failed", "err", err)
+	}
+
+	return &archive
+}
+
+// maybeArchive archives the episodes every ArchiveInterval, if archival is
+// enabled.
+func (u *Updater) maybeArchive(ctx context.Context) {
+	if u.opts.Archiver == nil || time.Since(u.lastArchive) < u.opts.ArchiveInterval {
+		return
+	}
+
+	u.archive(ctx)
+	u.lastArchive = time.Now()
+}
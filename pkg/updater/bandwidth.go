@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// downloadBurst is the most bytes read from a rate limited download at once.
+const downloadBurst = 256 * 1024
+
+// BandwidthProfile limits the speed of downloads during a daily window.
+type BandwidthProfile struct {
+	Window Window
+	// DownloadRate in bytes per second. Zero is unlimited.
+	DownloadRate int
+}
+
+// downloadRate is the rate limit of downloads at t, from the first profile
+// with a window containing t. Downloads outside of all the windows are
+// unlimited.
+func (u *Updater) downloadRate(t time.Time) rate.Limit {
+	for _, profile := range u.opts.BandwidthProfiles {
+		if !profile.Window.Contains(t) {
+			continue
+		}
+
+		if profile.DownloadRate == 0 {
+			return rate.Inf
+		}
+
+		return rate.Limit(profile.DownloadRate)
+	}
+
+	return rate.Inf
+}
+
+// limitDownload limits the speed of reading r to the download rate of the
+// bandwidth profiles. The rate is updated as the day goes on, so long
+// downloads speed up or slow down when they cross into another window.
+func (u *Updater) limitDownload(ctx context.Context, r io.Reader) io.Reader {
+	if len(u.opts.BandwidthProfiles) == 0 {
+		return r
+	}
+
+	return &limitedReader{
+		ctx:     ctx,
+		r:       r,
+		u:       u,
+		limiter: rate.NewLimiter(u.downloadRate(time.Now()), downloadBurst),
+	}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	u       *Updater
+	limiter *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	now := time.Now()
+
+	limit := l.u.downloadRate(now)
+	if limit != l.limiter.Limit() {
+		l.limiter.SetLimitAt(now, limit)
+	}
+
+	if len(p) > downloadBurst {
+		p = p[:downloadBurst]
+	}
+
+	n, err := l.r.Read(p)
+	if n > 0 {
+		waitErr := l.limiter.WaitN(l.ctx, n)
+		if waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,79 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// ErrCircuitOpen is returned by DoWork instead of requesting work, while the
+// circuit of the work server is open.
+var ErrCircuitOpen = errors.New("work server circuit open")
+
+// breakerState is the circuit breaker of the work server. After
+// ServerFailures requests in a row failed, the circuit opens, and no work is
+// requested until ServerCooldown passed. Then one request is let through,
+// which closes the circuit if it succeeds, or opens it for another cooldown.
+// The local tasks, like the reconciliation, keep running meanwhile.
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	lastErr   error
+}
+
+// checkCircuit returns ErrCircuitOpen while the circuit is open.
+func (u *Updater) checkCircuit() error {
+	if time.Now().Before(u.breaker.openUntil) {
+		return fmt.Errorf(
+			"%w until %s: %w",
+			ErrCircuitOpen,
+			u.breaker.openUntil.Format(time.RFC3339),
+			u.breaker.lastErr,
+		)
+	}
+
+	return nil
+}
+
+// observeServer records the result of a request to the work server, and
+// opens or closes the circuit.
+func (u *Updater) observeServer(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if err == nil {
+		if !u.breaker.openUntil.IsZero() {
+			u.log.Info("work server recovered, requesting work again")
+		}
+
+		u.breaker = breakerState{}
+		metrics.WorkServerCircuitOpen.WithLabelValues(u.opts.Name).Set(0)
+
+		return
+	}
+
+	u.breaker.failures += 1
+	u.breaker.lastErr = err
+
+	if u.breaker.failures < u.opts.ServerFailures {
+		return
+	}
+
+	// A failure after the cooldown opens the circuit again, which is only
+	// logged at the first time.
+	if u.breaker.openUntil.IsZero() {
+		u.log.Warn(
+			"work server keeps failing, pausing work requests",
+			"failures", u.breaker.failures,
+			"cooldown", u.opts.ServerCooldown,
+			"err", err,
+		)
+	}
+
+	u.breaker.openUntil = time.Now().Add(u.opts.ServerCooldown)
+	metrics.WorkServerCircuitOpen.WithLabelValues(u.opts.Name).Set(1)
+}
@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"golang.org/x/time/rate"
+)
+
+// Bandwidth classes sent to the server as a hint of the download speed.
+const (
+	BandwidthLow    = "low"
+	BandwidthMedium = "medium"
+	BandwidthHigh   = "high"
+)
+
+// bandwidthClass is the BandwidthClass option, or else the class of the
+// download rate of the current bandwidth profile. It's empty when the
+// downloads aren't limited, and no class is set.
+func (u *Updater) bandwidthClass(t time.Time) string {
+	if u.opts.BandwidthClass != "" {
+		return u.opts.BandwidthClass
+	}
+
+	limit := u.downloadRate(t)
+
+	switch {
+	case limit == rate.Inf:
+		return ""
+	case limit < 1_000_000:
+		return BandwidthLow
+	case limit < 10_000_000:
+		return BandwidthMedium
+	default:
+		return BandwidthHigh
+	}
+}
+
+// jobTypesAll are the types of jobs the server sends.
+var jobTypesAll = []string{"download", "pin", "delete"}
+
+// acceptedJobs are the enabled job types the node can run right now. Only
+// deletes are accepted while the disk is low, or the quota is used up.
+func (u *Updater) acceptedJobs(diskErr error) []string {
+	accepted := jobTypesAll
+
+	if diskErr != nil {
+		accepted = []string{"delete"}
+	}
+
+	if u.opts.MaxStorage != 0 && u.opts.Catalog.Size() >= u.opts.MaxStorage {
+		accepted = []string{"delete"}
+	}
+
+	return slices.DeleteFunc(slices.Clone(accepted), func(jobType string) bool {
+		return !slices.Contains(u.opts.Jobs, jobType)
+	})
+}
+
+// declineJob is why a job of jobType is declined, ErrJobDisabled when it's
+// not in Options.Jobs, or diskErr, or nil if it can run.
+func (u *Updater) declineJob(jobType string, diskErr error) error {
+	if !slices.Contains(u.opts.Jobs, jobType) {
+		return fmt.Errorf("%w: %s", ErrJobDisabled, jobType)
+	}
+
+	return diskErr
+}
+
+// setCapacity adds the hints about what work the node can take to the
+// response, so the server can avoid sending jobs which would be declined.
+func (u *Updater) setCapacity(workResponse *workapi.WorkResponse, sys *kubo.DiagSysResponse, diskErr error) {
+	freeSpace := int(sys.DiskInfo.FreeSpace)
+	workResponse.FreeSpace = &freeSpace
+
+	if u.opts.MaxEpisodeSize != 0 {
+		workResponse.MaxEpisodeSize = &u.opts.MaxEpisodeSize
+	}
+
+	workResponse.BandwidthClass = u.bandwidthClass(time.Now())
+	workResponse.AcceptedJobs = u.acceptedJobs(diskErr)
+}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader loads a client certificate, and loads it again when the
+// files change, so rotated certificates are used without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *slog.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, log *slog.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      log,
+	}
+
+	err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// latestModTime is the latest modification time of the cert and key files.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	certStat, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat cert failed: %w", err)
+	}
+
+	keyStat, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat key failed: %w", err)
+	}
+
+	if keyStat.ModTime().After(certStat.ModTime()) {
+		return keyStat.ModTime(), nil
+	}
+
+	return certStat.ModTime(), nil
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	if !modTime.After(r.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading client certificate failed: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+
+	return nil
+}
+
+// GetClientCertificate is used as the tls.Config.GetClientCertificate. If
+// the files changed, but can't be loaded, like while they are being
+// replaced, the previous certificate is used.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.reload()
+	if err != nil {
+		r.log.Warn("reloading client certificate failed, using the previous one", "err", err)
+	}
+
+	return r.cert, nil
+}
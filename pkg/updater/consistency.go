@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/ipfs/go-cid"
+)
+
+// checkContent compares the downloaded episode with the last download of the
+// same URL in the catalog, and with the CID the server sent, which other
+// nodes reported. If the content changed, the new pin is removed, and the job
+// fails with ErrContentChanged, so the server doesn't get a new hash for the
+// episode without knowing why.
+func (u *Updater) checkContent(ctx context.Context, work *workapi.Work, downloaded *downloadFileResponse) error {
+	err := u.compareContent(work, downloaded)
+	if err == nil {
+		return nil
+	}
+
+	// Keep the pin if it's a known episode, like the same file under
+	// another URL.
+	_, ok := u.opts.Catalog.Get(downloaded.Dir)
+	if !ok {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+
+		unpinErr := u.pinner.Unpin(ctx, downloaded.Dir)
+		if unpinErr != nil {
+			u.log.Error("unpin of changed episode failed", "cid", downloaded.Dir, "err", unpinErr)
+		}
+	}
+
+	return err
+}
+
+func (u *Updater) compareContent(work *workapi.Work, downloaded *downloadFileResponse) error {
+	previous, ok := u.opts.Catalog.GetDownload(work.Download)
+	if ok && previous.Hash != downloaded.Dir {
+		// The same bytes get another CID when they're added with other
+		// options, so the SHA-256 is compared when both are known.
+		changed := !sameCID(previous.File, downloaded.File)
+		if previous.SHA256 != "" && downloaded.SHA256 != "" {
+			changed = previous.SHA256 != downloaded.SHA256
+		}
+
+		if changed {
+			return fmt.Errorf(
+				"%w: file is %s, but was %s when it was downloaded on %s",
+				ErrContentChanged,
+				downloaded.File,
+				previous.File,
+				previous.Added.Format("2006-01-02"),
+			)
+		}
+	}
+
+	// The reported CID can only be compared when the file was added like
+	// the other nodes add it, with Kubo's defaults, and not pinned from
+	// IPFS instead.
+	if work.CID != "" && downloaded.SHA256 != "" && u.opts.AddOptions == (kubo.AddOptions{}) {
+		// The server may send the file and directory, like the result
+		// of a download.
+		file, _, _ := strings.Cut(work.CID, "/")
+
+		if !sameCID(file, downloaded.File) {
+			return fmt.Errorf("%w: file is %s, but other nodes reported %s", ErrContentChanged, downloaded.File, file)
+		}
+	}
+
+	return nil
+}
+
+// sameCID is if a and b have the same multihash, like a CIDv0 and the CIDv1
+// of the same content. Invalid CIDs are compared as strings.
+func sameCID(a string, b string) bool {
+	aCid, aErr := cid.Decode(a)
+	bCid, bErr := cid.Decode(b)
+
+	if aErr != nil || bErr != nil {
+		return a == b
+	}
+
+	return aCid.Hash().String() == bCid.Hash().String()
+}
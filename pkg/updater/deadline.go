@@ -0,0 +1,134 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAddTimeout is returned when Kubo stopped reading a download, or didn't
+// respond after the download finished, within the add timeout.
+var ErrAddTimeout = errors.New("add timed out")
+
+// ErrJobTimeout is returned when a job didn't finish within the job timeout.
+var ErrJobTimeout = errors.New("job timed out")
+
+// cleanupTimeout is how long the cleanup of a failed job may take. It runs
+// after the job's context is done, when the job timed out.
+const cleanupTimeout = time.Minute
+
+// jobContext limits a job, with all its downloads, adds, and pins, to the job
+// timeout.
+func (u *Updater) jobContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, u.opts.JobTimeout, fmt.Errorf("%w after %s", ErrJobTimeout, u.opts.JobTimeout))
+}
+
+// jobError wraps err in ErrJobTimeout if the job failed because its context
+// expired, since the error of the cancelled request doesn't say why. Kubo
+// keeps the blocks of a cancelled add or pin, unpinned, so the repo is
+// collected after the job.
+func (u *Updater) jobError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	cause := context.Cause(ctx)
+	if !errors.Is(cause, ErrJobTimeout) {
+		return err
+	}
+
+	u.deletedSinceGC = true
+
+	if errors.Is(err, ErrJobTimeout) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", cause, err)
+}
+
+// cleanupContext is for undoing the parts of a failed job, like unpinning
+// the directory it added. It isn't cancelled with ctx, so the cleanup still
+// runs when the job timed out.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// phaseDeadline cancels a phase of a download when it doesn't make progress
+// within the timeout. The clock only runs between start and stop, while the
+// phase is the one holding up the download, so a slow origin doesn't count
+// against Kubo, or the other way around. Each start resets the clock.
+type phaseDeadline struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cause   error
+	expired atomic.Bool
+}
+
+// newPhaseDeadline creates a stopped deadline which calls cancel with cause
+// when it expires. A zero timeout never expires.
+func newPhaseDeadline(timeout time.Duration, cancel context.CancelCauseFunc, cause error) *phaseDeadline {
+	d := &phaseDeadline{
+		timeout: timeout,
+		cause:   fmt.Errorf("%w: no progress for %s", cause, timeout),
+	}
+
+	d.timer = time.AfterFunc(timeout, func() {
+		d.expired.Store(true)
+		cancel(d.cause)
+	})
+	d.timer.Stop()
+
+	return d
+}
+
+func (d *phaseDeadline) start() {
+	if d.timeout > 0 {
+		d.timer.Reset(d.timeout)
+	}
+}
+
+func (d *phaseDeadline) stop() {
+	d.timer.Stop()
+}
+
+// wrap returns the cause of the deadline if it expired, because the error of
+// the cancelled phase is only context.Canceled, or err otherwise.
+func (d *phaseDeadline) wrap(err error) error {
+	if d.expired.Load() {
+		return d.cause
+	}
+
+	return err
+}
+
+// waitingReader runs the deadline while a Read is waiting for r, like the
+// origin of a download sending the next bytes.
+type waitingReader struct {
+	r        io.Reader
+	deadline *phaseDeadline
+}
+
+func (w waitingReader) Read(p []byte) (int, error) {
+	w.deadline.start()
+	defer w.deadline.stop()
+
+	return w.r.Read(p)
+}
+
+// busyReader runs the deadline between Reads, while the reader is busy with
+// the bytes it already has, like Kubo adding the download. The deadline
+// keeps running after the last Read, until it's stopped.
+type busyReader struct {
+	r        io.Reader
+	deadline *phaseDeadline
+}
+
+func (b busyReader) Read(p []byte) (int, error) {
+	b.deadline.stop()
+	defer b.deadline.start()
+
+	return b.r.Read(p)
+}
@@ -0,0 +1,32 @@
+// Package updater runs the work loop of an IPFS Podcasting node.
+//
+// An Updater requests work from the IPFS Podcasting server, downloads, pins,
+// or deletes episodes on a Kubo node, and reports the results back:
+//
+//	api, err := rpc.NewLocalApi()
+//	if err != nil {
+//		return err
+//	}
+//
+//	u, err := updater.New(kubo.New(api), updater.Options{
+//		Email: "email@example.com",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	return u.Run(ctx)
+//
+// Options.OnEvent is called as the work loop runs, with a JobStarted,
+// DownloadProgress, JobFinished, or CycleCompleted, so an application
+// embedding the Updater can show what it's doing:
+//
+//	OnEvent: func(event updater.Event) {
+//		switch event := event.(type) {
+//		case updater.DownloadProgress:
+//			fmt.Printf("%s: %d of %d bytes\n", event.Download, event.Read, event.Total)
+//		case updater.JobFinished:
+//			fmt.Printf("%s: %s\n", event.Job.Episode, event.Job.Status)
+//		}
+//	},
+package updater
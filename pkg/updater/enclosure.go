@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/angaz/ipfspodcasting/pkg/feed"
+)
+
+// AlternateEnclosure is a podcast:alternateEnclosure of the episode pinned
+// as hash, the directory wrapping the episode file, so podcasters can add
+// their node's copy to their feed.
+func (u *Updater) AlternateEnclosure(ctx context.Context, hash string) (*feed.AlternateEnclosure, error) {
+	ls, err := u.kubo.Ls(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("ls failed: %w", err)
+	}
+
+	if len(ls.Objects) != 1 || len(ls.Objects[0].Links) != 1 {
+		return nil, fmt.Errorf("ls objects or links is not 1")
+	}
+
+	link := ls.Objects[0].Links[0]
+	filePath := hash + "/" + link.Name
+
+	mediaType := mime.TypeByExtension(path.Ext(link.Name))
+	if mediaType == "" {
+		mediaType = "audio/mpeg"
+	}
+
+	enclosure := &feed.AlternateEnclosure{
+		Type:   mediaType,
+		Length: link.Size,
+		Sources: []feed.Source{
+			{URI: "ipfs://" + filePath},
+		},
+	}
+
+	if u.opts.EnclosureGateway != "" {
+		enclosure.Sources = append(enclosure.Sources, feed.Source{
+			URI: u.opts.EnclosureGateway + "/ipfs/" + filePath,
+		})
+	}
+
+	return enclosure, nil
+}
+
+// writeAlternateEnclosure writes the snippet of the episode to
+// <hash>.xml in the EnclosureDir, if there is one.
+func (u *Updater) writeAlternateEnclosure(ctx context.Context, hash string) {
+	if u.opts.EnclosureDir == "" {
+		return
+	}
+
+	enclosure, err := u.AlternateEnclosure(ctx, hash)
+	if err != nil {
+		u.log.Error("creating alternate enclosure failed", "cid", hash, "err", err)
+
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(u.opts.EnclosureDir, hash+".xml"), []byte(enclosure.Snippet()), 0o644)
+	if err != nil {
+		u.log.Error("writing alternate enclosure failed", "cid", hash, "err", err)
+	}
+}
+
+// removeAlternateEnclosure removes the snippet of an episode which is no
+// longer hosted.
+func (u *Updater) removeAlternateEnclosure(hash string) {
+	if u.opts.EnclosureDir == "" {
+		return
+	}
+
+	err := os.Remove(filepath.Join(u.opts.EnclosureDir, hash+".xml"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		u.log.Error("removing alternate enclosure failed", "cid", hash, "err", err)
+	}
+}
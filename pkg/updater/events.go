@@ -0,0 +1,65 @@
+package updater
+
+import (
+	"time"
+)
+
+// Event is passed to Options.OnEvent as the work loop runs. It's one of
+// JobStarted, DownloadProgress, JobFinished, or CycleCompleted.
+type Event interface {
+	isEvent()
+}
+
+// JobStarted is sent when the server gave the node work, before the jobs
+// run.
+type JobStarted struct {
+	Node    string
+	Time    time.Time
+	Types   []string
+	Show    string
+	Episode string
+}
+
+// DownloadProgress is sent every few seconds while an episode is downloaded.
+type DownloadProgress struct {
+	Node     string
+	Download string
+	// Read is the number of bytes added to Kubo so far.
+	Read int64
+	// Total is the Content-Length of the download, -1 if it's unknown.
+	Total   int64
+	Elapsed time.Duration
+}
+
+// JobFinished is sent when the jobs of a work cycle finished, with the same
+// record as Updater.RecentJobs.
+type JobFinished struct {
+	Node string
+	Job  JobRecord
+}
+
+// CycleCompleted is sent at the end of each work cycle, before waiting for
+// the next one.
+type CycleCompleted struct {
+	Node     string
+	Time     time.Time
+	Duration time.Duration
+	// GotWork is if the server sent any jobs.
+	GotWork bool
+	// Err is the error which failed the cycle, nil if it succeeded.
+	Err error
+	// NextCheck is when the next cycle runs, unless it's triggered early.
+	NextCheck time.Time
+}
+
+func (JobStarted) isEvent()       {}
+func (DownloadProgress) isEvent() {}
+func (JobFinished) isEvent()      {}
+func (CycleCompleted) isEvent()   {}
+
+// emit sends event to Options.OnEvent, if it's set.
+func (u *Updater) emit(event Event) {
+	if u.opts.OnEvent != nil {
+		u.opts.OnEvent(event)
+	}
+}
@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// Eviction policies, see Options.EvictPolicy.
+const (
+	// EvictLRU evicts the episodes the server assigned least recently.
+	EvictLRU = "lru"
+	// EvictLargest evicts the largest episodes.
+	EvictLargest = "largest"
+	// EvictOldest evicts the episodes which were added first.
+	EvictOldest = "oldest"
+)
+
+// checkEviction validates the eviction options.
+func (o *Options) checkEviction() error {
+	switch o.EvictPolicy {
+	case EvictLRU, EvictLargest, EvictOldest:
+	default:
+		return fmt.Errorf("evict policy must be lru, largest, or oldest: %q", o.EvictPolicy)
+	}
+
+	if o.EvictHighWater == 0 {
+		return nil
+	}
+
+	if o.MaxStorage == 0 {
+		return fmt.Errorf("eviction needs a storage quota")
+	}
+
+	if o.EvictHighWater < 0 || o.EvictHighWater > 1 {
+		return fmt.Errorf("evict high-water mark must be between 0 and 1: %v", o.EvictHighWater)
+	}
+
+	if o.EvictLowWater <= 0 || o.EvictLowWater >= o.EvictHighWater {
+		return fmt.Errorf("evict low-water mark must be between 0 and the high-water mark: %v", o.EvictLowWater)
+	}
+
+	return nil
+}
+
+// evictEpisodes unpins episodes, in the order of the eviction policy, when
+// the catalog uses more than the high-water mark of the quota, until it uses
+// less than the low-water mark.
+func (u *Updater) evictEpisodes(ctx context.Context) {
+	if u.opts.EvictHighWater == 0 {
+		return
+	}
+
+	used := u.opts.Catalog.Size()
+	if float64(used) < u.opts.EvictHighWater*float64(u.opts.MaxStorage) {
+		return
+	}
+
+	target := int(u.opts.EvictLowWater * float64(u.opts.MaxStorage))
+
+	episodes := u.opts.Catalog.Episodes()
+	slices.SortStableFunc(episodes, evictionOrder(u.opts.EvictPolicy))
+
+	var evict []catalog.Episode
+
+	for _, episode := range episodes {
+		if used <= target {
+			break
+		}
+
+		evict = append(evict, episode)
+		used -= episode.Size
+	}
+
+	u.log.Info(
+		"storage above the high-water mark, evicting episodes",
+		"policy", u.opts.EvictPolicy,
+		"used", u.opts.Catalog.Size(),
+		"target", target,
+		"episodes", len(evict),
+	)
+
+	dropped := u.dropEpisodes(ctx, "evicted", evict)
+
+	metrics.PinsEvicted.WithLabelValues(u.opts.Name).Add(float64(dropped))
+}
+
+// evictionOrder compares episodes in the order policy evicts them.
+func evictionOrder(policy string) func(a, b catalog.Episode) int {
+	switch policy {
+	case EvictLargest:
+		return func(a, b catalog.Episode) int {
+			return cmp.Compare(b.Size, a.Size)
+		}
+	case EvictOldest:
+		return func(a, b catalog.Episode) int {
+			return a.Added.Compare(b.Added)
+		}
+	default:
+		return func(a, b catalog.Episode) int {
+			return a.LastAssigned().Compare(b.LastAssigned())
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package updater_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubotest"
+	"github.com/angaz/ipfspodcasting/pkg/updater"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/angaz/ipfspodcasting/pkg/workapitest"
+)
+
+// A work cycle against a fake work server and Kubo node, which downloads
+// an episode from an origin, and reports it.
+func Example() {
+	episode := append([]byte("ID3"), bytes.Repeat([]byte{0}, 1<<20)...)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write(episode)
+	}))
+	defer origin.Close()
+
+	node := kubotest.NewServer()
+	defer node.Close()
+
+	server := workapitest.NewServer()
+	defer server.Close()
+
+	server.Queue(workapi.Work{
+		Show:     "show",
+		Episode:  "episode",
+		Download: origin.URL + "/episode.mp3",
+		Filename: "episode.mp3",
+	})
+
+	k, err := node.Client()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	u, err := updater.New(k, updater.Options{
+		Email:     "email@example.com",
+		ServerURL: server.URL,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gotWork, complete, err := u.DoWork(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("got work:", gotWork, "complete:", complete)
+
+	for _, response := range server.Responses() {
+		fmt.Println("downloaded:", *response.Downloaded, "length:", *response.Length)
+	}
+
+	// Output:
+	// got work: true complete: true
+	// downloaded: QmfYtqg95pnDvEFkTF2CmJcpwpjDoX94BRu6NevXkFcPbF/QmcYRDHh6csEBnuD4yVYeBVkJWvvYm5bRmPFrGAmmf5Yo2 length: 1048579
+}
@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// expiry is when the episode of work expires, from the TTL of the work, or
+// Options.PinTTL. Nil if it doesn't expire.
+func (u *Updater) expiry(work *workapi.Work) *time.Time {
+	ttl := time.Duration(work.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = u.opts.PinTTL
+	}
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	expires := time.Now().Add(ttl)
+
+	return &expires
+}
+
+// expirePins unpins the episodes which expired.
+func (u *Updater) expirePins(ctx context.Context) {
+	expired := u.opts.Catalog.Expired(time.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	dropped := u.dropEpisodes(ctx, "expired", expired)
+
+	metrics.PinsExpired.WithLabelValues(u.opts.Name).Add(float64(dropped))
+}
+
+// dropEpisodes unpins the episodes, and removes them from the catalog. They
+// are reported to the server as deleted with the pending results, which are
+// sent before the next work request, so it can assign them to other nodes.
+// It returns the number of episodes which were unpinned.
+func (u *Updater) dropEpisodes(ctx context.Context, reason string, episodes []catalog.Episode) int {
+	report := workapi.WorkResponse{
+		Email:   u.opts.Email,
+		Version: workapi.ProtocolVersion,
+		Tags:    u.opts.Tags,
+	}
+
+	_, err := u.getKuboStats(ctx, &report)
+	if err != nil {
+		u.log.Error("get kubo stats failed, not unpinning episodes", "reason", reason, "err", err)
+
+		return 0
+	}
+
+	dropped := 0
+
+	for _, episode := range episodes {
+		err := kuboError(u.pinner.Unpin(ctx, episode.Hash))
+		if err != nil {
+			u.log.Error("unpin of episode failed", "reason", reason, "cid", episode.Hash, "err", err)
+
+			continue
+		}
+
+		u.log.Info(
+			"unpinned episode",
+			"reason", reason,
+			"cid", episode.Hash,
+			"show", episode.Show,
+			"episode", episode.Episode,
+			"size", episode.Size,
+		)
+
+		dropped += 1
+		u.deletedSinceGC = true
+
+		err = u.removeFromCatalog(ctx, episode.Hash)
+		if err != nil {
+			u.log.Error("removing from catalog failed", "cid", episode.Hash, "err", err)
+		}
+
+		deleted := report
+		deleted.Deleted = &episode.Hash
+
+		u.pending = append(u.pending, deleted)
+	}
+
+	if dropped > 0 {
+		u.observeCatalog()
+
+		err = u.savePending()
+		if err != nil {
+			u.log.Error("saving pending results failed", "err", err)
+		}
+	}
+
+	return dropped
+}
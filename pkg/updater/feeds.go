@@ -0,0 +1,101 @@
+package updater
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/feed"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// feedEpisodesPerCycle is the number of new feed episodes downloaded per
+// work cycle, so the first sync of a large feed doesn't hold up the work
+// from the server for hours.
+const feedEpisodesPerCycle = 5
+
+// syncFeeds downloads the episodes of the feeds which aren't in the catalog
+// yet, up to feedEpisodesPerCycle. done is false if there are more
+// episodes left.
+func (u *Updater) syncFeeds(ctx context.Context) (done bool) {
+	hosted := map[string]bool{}
+
+	for _, episode := range u.opts.Catalog.Episodes() {
+		hosted[episode.Download] = true
+	}
+
+	budget := feedEpisodesPerCycle
+
+	for _, feedURL := range u.opts.Feeds {
+		f, err := feed.Fetch(ctx, u.httpClient, feedURL)
+		if err != nil {
+			u.log.Error("fetching feed failed", "feed", feedURL, "err", err)
+
+			continue
+		}
+
+		for _, item := range f.Items {
+			if item.Enclosure == nil || hosted[item.Enclosure.URL] {
+				continue
+			}
+
+			if budget == 0 {
+				return false
+			}
+
+			budget -= 1
+
+			u.hostFeedEpisode(ctx, f.Title, item)
+		}
+	}
+
+	return true
+}
+
+// hostFeedEpisode downloads the episode of a feed, and adds it to the
+// catalog.
+func (u *Updater) hostFeedEpisode(ctx context.Context, show string, item feed.Item) {
+	download := item.Enclosure.URL
+	log := u.log.With("show", show, "episode", item.Title)
+
+	filename := "episode"
+
+	downloadURL, err := url.Parse(download)
+	if err == nil && path.Base(downloadURL.Path) != "/" && path.Base(downloadURL.Path) != "." {
+		filename = path.Base(downloadURL.Path)
+	}
+
+	work := &workapi.Work{
+		Show:     show,
+		Episode:  item.Title,
+		Download: download,
+	}
+
+	log.Info("hosting feed episode", "download", download)
+
+	downloaded, err := u.downloadOrPinFile(ctx, download, filename, pinName(work), item.Enclosure.Length)
+	if err != nil {
+		metrics.FeedEpisodes.WithLabelValues(u.opts.Name, errorReason(err)).Inc()
+		log.Error("hosting feed episode failed", "download", download, "err", err)
+
+		return
+	}
+
+	metrics.FeedEpisodes.WithLabelValues(u.opts.Name, "success").Inc()
+
+	u.addToCatalog(ctx, work, downloaded.Dir, downloaded.File, downloaded.Length, downloaded.SHA256, downloaded.MirrorKey)
+}
+
+// maybeSyncFeeds syncs the feeds if it hasn't been done within the feed
+// interval, or the previous sync had episodes left.
+func (u *Updater) maybeSyncFeeds(ctx context.Context) {
+	if len(u.opts.Feeds) == 0 || u.diskLow || time.Since(u.lastFeedSync) < u.opts.FeedInterval {
+		return
+	}
+
+	if u.syncFeeds(ctx) {
+		u.lastFeedSync = time.Now()
+	}
+}
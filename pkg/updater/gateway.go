@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+var errNotGateway = errors.New("not an ipfs gateway url")
+
+// gatewayPath finds the namespace, ipfs or ipns, and the name, a CID, IPNS
+// key, or DNSLink domain, in the URL of an IPFS gateway. Both path gateways,
+// like https://gateway/ipfs/<cid>/file.mp3, and subdomain gateways, like
+// https://<cid>.ipfs.gateway/file.mp3, are supported.
+func gatewayPath(download *url.URL) (namespace string, name string, err error) {
+	labels := strings.Split(download.Hostname(), ".")
+
+	if len(labels) > 2 && (labels[1] == "ipfs" || labels[1] == "ipns") {
+		name := labels[0]
+
+		// DNSLink names are inlined into a single label of subdomain
+		// gateways, by replacing "-" with "--", and "." with "-".
+		if labels[1] == "ipns" && strings.Contains(name, "-") {
+			name = strings.ReplaceAll(name, "--", "\x00")
+			name = strings.ReplaceAll(name, "-", ".")
+			name = strings.ReplaceAll(name, "\x00", "-")
+		}
+
+		return labels[1], name, nil
+	}
+
+	segments := strings.Split(strings.TrimPrefix(download.Path, "/"), "/")
+
+	if len(segments) >= 2 && (segments[0] == "ipfs" || segments[0] == "ipns") && segments[1] != "" {
+		return segments[0], segments[1], nil
+	}
+
+	return "", "", errNotGateway
+}
+
+// gatewayCID is the root CID of the content at an IPFS gateway URL. /ipns/
+// names are resolved by the Kubo node.
+func (u *Updater) gatewayCID(ctx context.Context, download *url.URL) (cid.Cid, error) {
+	namespace, name, err := gatewayPath(download)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if namespace == "ipns" {
+		resolved, err := u.kubo.Resolve(ctx, "/ipns/"+name)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("resolving %s failed: %w", name, err)
+		}
+
+		segments := strings.Split(strings.TrimPrefix(resolved, "/"), "/")
+		if len(segments) < 2 || segments[0] != "ipfs" {
+			return cid.Undef, fmt.Errorf("unexpected resolved path: %s", resolved)
+		}
+
+		name = segments[1]
+	}
+
+	c, err := cid.Decode(name)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("parsing cid failed: %w", err)
+	}
+
+	return c, nil
+}
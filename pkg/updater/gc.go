@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// gcNeeded is if the repo should be garbage collected. Either because
+// something was deleted, or the repo is using more than the threshold.
+func (u *Updater) gcNeeded(ctx context.Context) (bool, error) {
+	if u.opts.GCAfterDelete && u.deletedSinceGC {
+		return true, nil
+	}
+
+	if u.opts.GCThreshold == 0 {
+		return false, nil
+	}
+
+	stats, err := u.kubo.RepoStat(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repo stat failed: %w", err)
+	}
+
+	if stats.StorageMax == 0 {
+		return false, nil
+	}
+
+	return float64(stats.RepoSize) >= u.opts.GCThreshold*float64(stats.StorageMax), nil
+}
+
+// maybeGC runs the repo garbage collector if it's needed.
+func (u *Updater) maybeGC(ctx context.Context) {
+	needed, err := u.gcNeeded(ctx)
+	if err != nil {
+		u.log.Error("checking if gc is needed failed", "err", err)
+
+		return
+	}
+
+	if !needed {
+		return
+	}
+
+	err = u.gc(ctx)
+	if err != nil {
+		u.log.Error("repo gc failed", "err", err)
+	}
+}
+
+func (u *Updater) gc(ctx context.Context) error {
+	start := time.Now()
+	status := "error"
+
+	defer func() {
+		metrics.GCHistogram.WithLabelValues(u.opts.Name, status).Observe(time.Since(start).Seconds())
+	}()
+
+	before, err := u.kubo.RepoStat(ctx)
+	if err != nil {
+		return fmt.Errorf("repo stat before gc failed: %w", err)
+	}
+
+	u.log.Info("starting repo gc", "repo_size", before.RepoSize)
+
+	removed, err := u.kubo.RepoGC(ctx)
+	if err != nil {
+		return fmt.Errorf("gc failed after removing %d blocks: %w", removed, err)
+	}
+
+	u.deletedSinceGC = false
+
+	after, err := u.kubo.RepoStat(ctx)
+	if err != nil {
+		return fmt.Errorf("repo stat after gc failed: %w", err)
+	}
+
+	reclaimed := max(before.RepoSize-after.RepoSize, 0)
+
+	status = "success"
+	metrics.GCReclaimedBytes.WithLabelValues(u.opts.Name).Add(float64(reclaimed))
+
+	u.log.Info(
+		"repo gc finished",
+		"removed_blocks", removed,
+		"reclaimed_bytes", reclaimed,
+		"duration", time.Since(start),
+	)
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckKubo returns an error if the Kubo node isn't reachable.
+func (u *Updater) CheckKubo(ctx context.Context) error {
+	_, err := u.kubo.ID(ctx)
+	if err != nil {
+		return fmt.Errorf("kubo unreachable: %w", err)
+	}
+
+	return nil
+}
+
+// CheckWorkServer returns an error if the work server isn't reachable.
+func (u *Updater) CheckWorkServer(ctx context.Context) error {
+	err := u.workClient.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("work server unreachable: %w", err)
+	}
+
+	return nil
+}
+
+// CheckDisk returns an error wrapping ErrDiskLow if the free space on the
+// Kubo repo's disk is below the minimum.
+func (u *Updater) CheckDisk(ctx context.Context) error {
+	sys, err := u.kubo.DiagSys(ctx)
+	if err != nil {
+		return fmt.Errorf("getting diag/sys failed: %w", err)
+	}
+
+	return u.checkDiskSpace(sys)
+}
@@ -0,0 +1,119 @@
+package updater
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+const (
+	// maxJobHistory is the number of recent jobs kept for the dashboard.
+	maxJobHistory = 50
+	// maxDiskSamples is the number of disk usage samples kept, one per work
+	// cycle, which is a day at the default update frequency.
+	maxDiskSamples = 144
+)
+
+// JobRecord is a job which was run, for the dashboard.
+type JobRecord struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Types    []string      `json:"types"`
+	Show     string        `json:"show,omitempty"`
+	Episode  string        `json:"episode,omitempty"`
+	CID      string        `json:"cid,omitempty"`
+	// Status is "success", or the failure category, like "no_space".
+	Status string `json:"status"`
+	// Account is the email which requested the job.
+	Account string `json:"account,omitempty"`
+}
+
+// DiskSample is the usage of the disk of the Kubo repo at a point in time.
+type DiskSample struct {
+	Time  time.Time `json:"time"`
+	Used  int64     `json:"used"`
+	Total int64     `json:"total"`
+}
+
+// history keeps the recent jobs and disk usage. It's read by the dashboard
+// while the work loop writes it.
+type history struct {
+	mu   sync.Mutex
+	jobs []JobRecord
+	disk []DiskSample
+}
+
+func (h *history) addJob(record JobRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.jobs = append(h.jobs, record)
+	if len(h.jobs) > maxJobHistory {
+		h.jobs = h.jobs[len(h.jobs)-maxJobHistory:]
+	}
+}
+
+func (h *history) addDiskSample(sample DiskSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.disk = append(h.disk, sample)
+	if len(h.disk) > maxDiskSamples {
+		h.disk = h.disk[len(h.disk)-maxDiskSamples:]
+	}
+}
+
+// jobCID is the hash the job was about, the directory of a download, or the
+// pinned or deleted hash.
+func jobCID(work *workapi.Work, r workapi.WorkResponse) string {
+	if r.Downloaded != nil {
+		_, dir, _ := strings.Cut(*r.Downloaded, "/")
+
+		return dir
+	}
+
+	if work.Pin != "" {
+		return work.Pin
+	}
+
+	return work.Delete
+}
+
+// RecentJobs returns the last jobs which were run, newest first.
+func (u *Updater) RecentJobs() []JobRecord {
+	u.history.mu.Lock()
+	defer u.history.mu.Unlock()
+
+	jobs := slices.Clone(u.history.jobs)
+	slices.Reverse(jobs)
+
+	return jobs
+}
+
+// DiskUsage returns the usage of the disk of the Kubo repo at each of the
+// last work cycles, oldest first.
+func (u *Updater) DiskUsage() []DiskSample {
+	u.history.mu.Lock()
+	defer u.history.mu.Unlock()
+
+	return slices.Clone(u.history.disk)
+}
+
+// TriggerCycle starts the next work cycle now, instead of waiting for the
+// update frequency.
+func (u *Updater) TriggerCycle() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// TriggerReconcile reconciles the catalog after the next work cycle, even
+// outside of the maintenance windows, and starts the cycle now.
+func (u *Updater) TriggerReconcile() {
+	u.reconcileRequested.Store(true)
+	u.TriggerCycle()
+}
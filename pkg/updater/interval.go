@@ -0,0 +1,87 @@
+package updater
+
+import (
+	"sync"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// pollInterval is the time between work requests. It shrinks towards min
+// while the server keeps returning work, and grows towards max after
+// idleCycles cycles in a row without any work.
+type pollInterval struct {
+	node       string
+	mu         sync.Mutex
+	current    time.Duration
+	min        time.Duration
+	max        time.Duration
+	idleCycles int
+	idleStreak int
+}
+
+func newPollInterval(node string, initial, min, max time.Duration, idleCycles int) *pollInterval {
+	p := &pollInterval{
+		node:       node,
+		current:    initial,
+		min:        min,
+		max:        max,
+		idleCycles: idleCycles,
+	}
+
+	metrics.UpdateFrequency.WithLabelValues(node).Set(initial.Seconds())
+
+	return p
+}
+
+func (p *pollInterval) Current() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current
+}
+
+// Update adjusts the interval after a work cycle. gotWork is if the server
+// returned a job, regardless of if the job succeeded.
+func (p *pollInterval) Update(gotWork bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if gotWork {
+		p.idleStreak = 0
+		p.current = max(p.current/2, p.min)
+	} else {
+		p.idleStreak += 1
+
+		if p.idleStreak >= p.idleCycles {
+			p.current = min(p.current*2, p.max)
+		}
+	}
+
+	metrics.UpdateFrequency.WithLabelValues(p.node).Set(p.current.Seconds())
+}
+
+// jobWindow is the times of the jobs completed in the last hour, which
+// caps Options.MaxJobsPerHour.
+type jobWindow struct {
+	times []time.Time
+}
+
+func (w *jobWindow) add(t time.Time) {
+	w.times = append(w.times, t)
+}
+
+// count is the number of jobs in the hour before now. Older jobs are
+// dropped.
+func (w *jobWindow) count(now time.Time) int {
+	cutoff := now.Add(-time.Hour)
+
+	i := 0
+	for i < len(w.times) && !w.times[i].After(cutoff) {
+		i += 1
+	}
+
+	w.times = w.times[i:]
+
+	return len(w.times)
+}
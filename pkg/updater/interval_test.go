@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial time.Duration
+		min     time.Duration
+		max     time.Duration
+		// cycles is if the server returned work in each cycle.
+		cycles []bool
+		want   []time.Duration
+	}{
+		{
+			name:    "halves with work",
+			initial: 8 * time.Minute,
+			min:     time.Minute,
+			max:     time.Hour,
+			cycles:  []bool{true, true, true},
+			want:    []time.Duration{4 * time.Minute, 2 * time.Minute, time.Minute},
+		},
+		{
+			name:    "stops at min",
+			initial: 2 * time.Minute,
+			min:     90 * time.Second,
+			max:     time.Hour,
+			cycles:  []bool{true, true},
+			want:    []time.Duration{90 * time.Second, 90 * time.Second},
+		},
+		{
+			name:    "doubles after idle cycles",
+			initial: 10 * time.Minute,
+			min:     time.Minute,
+			max:     time.Hour,
+			cycles:  []bool{false, false, false, false},
+			want:    []time.Duration{10 * time.Minute, 10 * time.Minute, 20 * time.Minute, 40 * time.Minute},
+		},
+		{
+			name:    "stops at max",
+			initial: 40 * time.Minute,
+			min:     time.Minute,
+			max:     time.Hour,
+			cycles:  []bool{false, false, false, false},
+			want:    []time.Duration{40 * time.Minute, 40 * time.Minute, time.Hour, time.Hour},
+		},
+		{
+			name:    "work resets the idle streak",
+			initial: 10 * time.Minute,
+			min:     time.Minute,
+			max:     time.Hour,
+			cycles:  []bool{false, false, true, false, false, false},
+			want: []time.Duration{
+				10 * time.Minute,
+				10 * time.Minute,
+				5 * time.Minute,
+				5 * time.Minute,
+				5 * time.Minute,
+				10 * time.Minute,
+			},
+		},
+		{
+			name:    "fixed with the default min and max",
+			initial: 10 * time.Minute,
+			min:     10 * time.Minute,
+			max:     10 * time.Minute,
+			cycles:  []bool{true, false, false, false, false},
+			want: []time.Duration{
+				10 * time.Minute,
+				10 * time.Minute,
+				10 * time.Minute,
+				10 * time.Minute,
+				10 * time.Minute,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPollInterval("interval "+tt.name, tt.initial, tt.min, tt.max, 3)
+
+			for i, gotWork := range tt.cycles {
+				p.Update(gotWork)
+
+				got := p.Current()
+				if got != tt.want[i] {
+					t.Errorf("cycle %d: got %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// jobLogLines is how many of the last log lines of a job are kept.
+const jobLogLines = 50
+
+// jobLog collects the log lines of the running job, so they can be sent with
+// its results when it fails. Nothing is collected between jobs.
+type jobLog struct {
+	mu        sync.Mutex
+	capturing bool
+	lines     []string
+}
+
+// Write keeps a line written by the handler of the job log. The handler
+// writes each record with a single Write.
+func (l *jobLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.capturing {
+		return len(p), nil
+	}
+
+	if len(l.lines) == jobLogLines {
+		l.lines = l.lines[1:]
+	}
+
+	l.lines = append(l.lines, string(p))
+
+	return len(p), nil
+}
+
+func (l *jobLog) active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.capturing
+}
+
+// start collects the lines of a new job.
+func (l *jobLog) start() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capturing = true
+	l.lines = nil
+}
+
+// stop returns the lines of the job, and stops collecting.
+func (l *jobLog) stop() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capturing = false
+	lines := strings.Join(l.lines, "")
+	l.lines = nil
+
+	return lines
+}
+
+// jobLogHandler passes the records to next, and, while a job is running,
+// writes the ones at info level and above to the job log too.
+type jobLogHandler struct {
+	next    slog.Handler
+	capture slog.Handler
+	log     *jobLog
+}
+
+// newJobLogHandler wraps next, which already has the attributes of the
+// node, like its name, so they aren't repeated in every line of the job log.
+func newJobLogHandler(next slog.Handler, log *jobLog) *jobLogHandler {
+	return &jobLogHandler{
+		next: next,
+		capture: slog.NewTextHandler(log, &slog.HandlerOptions{
+			Level: slog.LevelInfo,
+		}),
+		log: log,
+	}
+}
+
+func (h *jobLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next.Enabled(ctx, level) {
+		return true
+	}
+
+	return h.log.active() && h.capture.Enabled(ctx, level)
+}
+
+func (h *jobLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.log.active() && h.capture.Enabled(ctx, r.Level) {
+		_ = h.capture.Handle(ctx, r.Clone())
+	}
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *jobLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &jobLogHandler{
+		next:    h.next.WithAttrs(attrs),
+		capture: h.capture.WithAttrs(attrs),
+		log:     h.log,
+	}
+}
+
+func (h *jobLogHandler) WithGroup(name string) slog.Handler {
+	return &jobLogHandler{
+		next:    h.next.WithGroup(name),
+		capture: h.capture.WithGroup(name),
+		log:     h.log,
+	}
+}
@@ -0,0 +1,714 @@
+package updater
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrEpisodeTooSmall is returned when an episode is smaller than the minimum
+// episode size. Empty or tiny files are almost always an error on the origin.
+var ErrEpisodeTooSmall = errors.New("episode too small")
+
+// ErrEpisodeTooLarge is returned when an episode is larger than the maximum
+// episode size.
+var ErrEpisodeTooLarge = errors.New("episode too large")
+
+// ErrNoSpace is returned when a job would make the hosted episodes use more
+// than the storage quota.
+var ErrNoSpace = errors.New("no space")
+
+// ErrDiskLow is returned for download and pin jobs while the free space on
+// the Kubo repo's disk is below the minimum.
+var ErrDiskLow = errors.New("disk space low")
+
+// ErrJobDisabled is returned for jobs of a type which isn't in
+// Options.Jobs.
+var ErrJobDisabled = errors.New("job type disabled")
+
+// ErrDownloadTimeout is returned when the origin of a download didn't
+// respond, or stopped sending the file, within the timeout.
+var ErrDownloadTimeout = errors.New("download timed out")
+
+// ErrDownloadFailed is returned when the download failed for another reason
+// than a timeout or an error status, like the origin being unreachable.
+var ErrDownloadFailed = errors.New("download failed")
+
+// ErrNotMedia is returned when a download isn't audio or video, like an HTML
+// error page which the origin sent with 200 OK.
+var ErrNotMedia = errors.New("not audio or video")
+
+// ErrKuboUnreachable is returned when a job couldn't connect to Kubo.
+var ErrKuboUnreachable = errors.New("kubo unreachable")
+
+// ErrCIDMismatch is returned when the content of a CID isn't what the job
+// expected, like a pin of a CID which isn't a directory wrapping a single
+// file.
+var ErrCIDMismatch = errors.New("cid mismatch")
+
+// ErrContentChanged is returned when a download has different content than
+// the last time the node downloaded the same URL, or than the CID other nodes
+// reported for it.
+var ErrContentChanged = errors.New("content changed")
+
+// DownloadStatusError is returned when the origin of a download responded
+// with a status other than 200 OK.
+type DownloadStatusError struct {
+	StatusCode int
+	// RetryAfter is the origin's Retry-After header, zero if it wasn't sent.
+	RetryAfter time.Duration
+}
+
+func (e *DownloadStatusError) Error() string {
+	return fmt.Sprintf("download file not OK: %d", e.StatusCode)
+}
+
+// downloadError wraps an error of a request to the origin of a download in
+// ErrDownloadTimeout, or ErrDownloadFailed.
+func downloadError(err error) error {
+	var netErr net.Error
+
+	if errors.Is(err, ErrDownloadTimeout) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrDownloadTimeout, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+}
+
+// kuboError wraps err in ErrKuboUnreachable if it's a failure to connect.
+func kuboError(err error) error {
+	var opErr *net.OpError
+
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return fmt.Errorf("%w: %w", ErrKuboUnreachable, err)
+	}
+
+	return err
+}
+
+// errorReason is the failure category of err, used for metrics and sent to
+// the server.
+func errorReason(err error) string {
+	var statusErr *DownloadStatusError
+
+	switch {
+	// The timeout cancels the job, so it's the reason, rather than the
+	// error of whatever was running at the time.
+	case errors.Is(err, ErrJobTimeout):
+		return "timeout"
+	case errors.Is(err, ErrEpisodeTooSmall):
+		return "too_small"
+	case errors.Is(err, ErrEpisodeTooLarge):
+		return "too_large"
+	case errors.Is(err, ErrNoSpace):
+		return "no_space"
+	case errors.Is(err, ErrDiskLow):
+		return "disk_low"
+	case errors.Is(err, ErrJobDisabled):
+		return "job_disabled"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("download_%d", statusErr.StatusCode)
+	case errors.Is(err, ErrDownloadTimeout):
+		return "download_timeout"
+	case errors.Is(err, ErrDownloadFailed):
+		return "download_failed"
+	case errors.Is(err, ErrNotMedia):
+		return "not_media"
+	case errors.Is(err, ErrAddTimeout):
+		return "add_timeout"
+	case errors.Is(err, ErrKuboUnreachable):
+		return "kubo_unreachable"
+	case errors.Is(err, ErrCIDMismatch):
+		return "cid_mismatch"
+	case errors.Is(err, ErrContentChanged):
+		return "content_changed"
+	default:
+		return "error"
+	}
+}
+
+// transientRetryAfter is suggested to the server when a download failed for
+// a reason which may go away, and the origin didn't say when to retry.
+const transientRetryAfter = time.Hour
+
+// retryHint is if the job failed with err is expected to fail again no
+// matter which node runs it, and if not, when the server should retry the
+// job. A zero retryAfter means there's no suggestion, like when the failure
+// is caused by this node, so the job can be given to another node right away.
+func retryHint(err error) (permanent bool, retryAfter time.Duration) {
+	var statusErr *DownloadStatusError
+
+	switch {
+	case errors.Is(err, ErrJobTimeout):
+		// A faster node may finish it.
+		return false, 0
+	case errors.As(err, &statusErr):
+		switch statusErr.StatusCode {
+		case http.StatusNotFound, http.StatusGone:
+			return true, 0
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return false, cmp.Or(statusErr.RetryAfter, transientRetryAfter)
+		}
+
+		if statusErr.StatusCode >= http.StatusInternalServerError {
+			return false, cmp.Or(statusErr.RetryAfter, transientRetryAfter)
+		}
+
+		// Other client errors, like 401 or 403, won't go away by
+		// themselves.
+		return statusErr.StatusCode >= http.StatusBadRequest, 0
+	case errors.Is(err, ErrEpisodeTooSmall), errors.Is(err, ErrCIDMismatch):
+		return true, 0
+	case errors.Is(err, ErrDownloadTimeout), errors.Is(err, ErrDownloadFailed):
+		return false, transientRetryAfter
+	case errors.Is(err, ErrNotMedia):
+		// Origins and CDNs send error pages with 200 OK while they have
+		// problems, so the episode may be back later.
+		return false, transientRetryAfter
+	default:
+		return false, 0
+	}
+}
+
+// setError marks workResponse as failed with err, with its reason, message,
+// and retry hint.
+func setError(workResponse *workapi.WorkResponse, err error) {
+	workResponse.SetErrorMessage(errorReason(err), err.Error())
+	workResponse.SetRetryHint(retryHint(err))
+}
+
+func (u *Updater) checkEpisodeSize(size int) error {
+	if size < u.opts.MinEpisodeSize {
+		return fmt.Errorf("%w: %d bytes, minimum is %d", ErrEpisodeTooSmall, size, u.opts.MinEpisodeSize)
+	}
+
+	return u.checkMaxEpisodeSize(size)
+}
+
+func (u *Updater) checkMaxEpisodeSize(size int) error {
+	if u.opts.MaxEpisodeSize != 0 && size > u.opts.MaxEpisodeSize {
+		return fmt.Errorf("%w: %d bytes, maximum is %d", ErrEpisodeTooLarge, size, u.opts.MaxEpisodeSize)
+	}
+
+	return nil
+}
+
+// preflight declines downloads which are too large for the maximum episode
+// size or the quota, before downloading anything. The size is the one sent
+// by the server, or else the Content-Length of a HEAD request. If neither is
+// known, the download goes ahead, and the size is checked while
+// downloading.
+func (u *Updater) preflight(ctx context.Context, download string, size int) error {
+	if u.opts.MaxEpisodeSize == 0 && u.opts.MaxStorage == 0 {
+		return nil
+	}
+
+	if size == 0 {
+		size = u.headSize(ctx, download)
+	}
+
+	if size <= 0 {
+		return nil
+	}
+
+	return errors.Join(u.checkMaxEpisodeSize(size), u.checkQuota(size))
+}
+
+// headSize is the Content-Length of a HEAD request to download, or -1 if it's
+// unknown. Some origins don't support HEAD, so errors only mean the size is
+// unknown.
+func (u *Updater) headSize(ctx context.Context, download string) int {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, download, nil)
+	if err != nil {
+		return -1
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		u.log.Debug("head request failed", "download", download, "err", err)
+
+		return -1
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		u.log.Debug("head request not OK", "download", download, "status", resp.StatusCode)
+
+		return -1
+	}
+
+	return int(resp.ContentLength)
+}
+
+// checkQuota returns ErrNoSpace if adding size bytes to the catalog would go
+// over the storage quota.
+func (u *Updater) checkQuota(size int) error {
+	if u.opts.MaxStorage == 0 {
+		return nil
+	}
+
+	used := u.opts.Catalog.Size()
+
+	if used+size > u.opts.MaxStorage {
+		return fmt.Errorf(
+			"%w: %d bytes used, %d bytes needed, quota is %d",
+			ErrNoSpace,
+			used,
+			size,
+			u.opts.MaxStorage,
+		)
+	}
+
+	return nil
+}
+
+// pinName is the name of the pins of the work, in the form show/episode, so
+// the pins can be mapped back to episodes. It's empty if the work has neither.
+func pinName(work *workapi.Work) string {
+	if work.Show == "" && work.Episode == "" {
+		return ""
+	}
+
+	return work.Show + "/" + work.Episode
+}
+
+type pinFileResponse struct {
+	Pinned string
+	File   string
+	Length int
+}
+
+func (u *Updater) pinFile(ctx context.Context, hash string, name string) (*pinFileResponse, error) {
+	// Episodes in the catalog are already pinned, and their size is known.
+	episode, ok := u.opts.Catalog.Get(hash)
+	if ok && episode.File != "" {
+		u.log.Info("already pinned", "cid", hash)
+
+		return &pinFileResponse{
+			Pinned: episode.File + "/" + hash,
+			File:   episode.File,
+			Length: episode.Size,
+		}, nil
+	}
+
+	if u.opts.MaxStorage != 0 || u.opts.MaxEpisodeSize != 0 {
+		stat, err := u.kubo.FilesStat(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("files stat failed: %w", kuboError(err))
+		}
+
+		err = errors.Join(u.checkMaxEpisodeSize(stat.CumulativeSize), u.checkQuota(stat.CumulativeSize))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The CID is checked to be a directory with the one episode before it's
+	// pinned, so a mismatch doesn't leave it pinned. Only the directory is
+	// fetched for it.
+	lsResp, err := u.kubo.Ls(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("ls failed: %w", kuboError(err))
+	}
+
+	if len(lsResp.Objects) != 1 || len(lsResp.Objects[0].Links) != 1 {
+		return nil, fmt.Errorf("%w: ls objects or links is not 1", ErrCIDMismatch)
+	}
+
+	link := lsResp.Objects[0].Links[0]
+	pinned := link.Hash + "/" + hash
+
+	err = u.checkEpisodeSize(link.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.pinner.Pin(ctx, hash, name)
+	if err != nil {
+		return nil, fmt.Errorf("pin add failed: %w", kuboError(err))
+	}
+
+	return &pinFileResponse{
+		Pinned: pinned,
+		File:   link.Hash,
+		Length: link.Size,
+	}, nil
+}
+
+// removeAdded unpins the wrapped directory added by a download which then
+// failed, unless it's an episode in the catalog, like the same file under
+// another URL. It still runs when the job timed out, so the directory isn't
+// left pinned without being in the catalog.
+func (u *Updater) removeAdded(ctx context.Context, hash string, message string) {
+	_, ok := u.opts.Catalog.Get(hash)
+	if ok {
+		return
+	}
+
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+
+	err := u.kubo.PinRm(ctx, hash)
+	if err != nil {
+		u.log.Error(message, "cid", hash, "err", err)
+	}
+}
+
+// observeDownload records the size and speed of a download, so slow origins
+// can be found.
+func (u *Updater) observeDownload(host string, size int, duration time.Duration) {
+	throughput := float64(size) / duration.Seconds()
+
+	metrics.DownloadThroughput.WithLabelValues(u.opts.Name).Observe(throughput)
+	metrics.DownloadSize.WithLabelValues(u.opts.Name).Observe(float64(size))
+
+	u.log.Info(
+		"downloaded episode",
+		"host", host,
+		"size", size,
+		"duration", duration,
+		"bytes_per_second", int(throughput),
+	)
+}
+
+// originReader records the first error reading the download from the origin,
+// so a failed add can be blamed on the origin, or on Kubo. Kubo can still be
+// reading when the add fails, so err is guarded by mu.
+type originReader struct {
+	r io.Reader
+
+	mu  sync.Mutex
+	err error
+}
+
+func (o *originReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		o.mu.Lock()
+		if o.err == nil {
+			o.err = err
+		}
+		o.mu.Unlock()
+	}
+
+	return n, err
+}
+
+func (o *originReader) failure() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.err
+}
+
+// checkAddedSize cross-checks the bytes read by the add against the size in
+// Kubo's response. The response has the size of the DAG, which includes the
+// UnixFS metadata of the blocks, so it's never smaller than the file.
+func checkAddedSize(added kubo.AddResponse, size int) error {
+	if added.Size != 0 && added.Size < size {
+		return fmt.Errorf("%w: added %s is %d bytes, but %d bytes were read", ErrCIDMismatch, added.Hash, added.Size, size)
+	}
+
+	return nil
+}
+
+type downloadFileResponse struct {
+	DownloadedFile string
+	File           string
+	Dir            string
+	Length         int
+	// SHA256 is the hex encoded SHA-256 of the downloaded file. Empty
+	// if the episode was pinned instead.
+	SHA256 string
+	// MirrorKey is the key of the file in the S3 mirror, if it was
+	// mirrored.
+	MirrorKey string
+}
+
+// pinnedDownload returns the pinned episode, when download is an /ipfs/ URL
+// of content which is already pinned on the node, so it doesn't have to be
+// downloaded again.
+func (u *Updater) pinnedDownload(ctx context.Context, download string, name string) (*downloadFileResponse, bool) {
+	downloadURL, err := url.Parse(download)
+	if err != nil {
+		return nil, false
+	}
+
+	namespace, cidStr, err := gatewayPath(downloadURL)
+	if err != nil || namespace != "ipfs" {
+		return nil, false
+	}
+
+	downloadCid, err := cid.Decode(cidStr)
+	if err != nil {
+		return nil, false
+	}
+
+	hash := downloadCid.String()
+
+	_, inCatalog := u.opts.Catalog.Get(hash)
+	if !inCatalog {
+		pinned, err := u.kubo.IsPinned(ctx, hash)
+		if err != nil {
+			u.log.Warn("checking pin failed", "cid", hash, "err", err)
+
+			return nil, false
+		}
+
+		if !pinned {
+			return nil, false
+		}
+	}
+
+	pin, err := u.pinFile(ctx, hash, name)
+	if err != nil {
+		u.log.Error("using pinned episode failed, downloading", "cid", hash, "err", err)
+
+		return nil, false
+	}
+
+	u.log.Info("skipping download of pinned episode", "cid", hash, "download", download)
+
+	return &downloadFileResponse{
+		DownloadedFile: pin.Pinned,
+		File:           pin.File,
+		Dir:            hash,
+		Length:         pin.Length,
+	}, true
+}
+
+// downloadOrPinFile downloads the episode, or pins it if it's on IPFS and
+// the download fails. size is the size sent by the server, or zero if it's
+// unknown.
+func (u *Updater) downloadOrPinFile(ctx context.Context, download string, filename string, name string, size int) (*downloadFileResponse, error) {
+	pinned, ok := u.pinnedDownload(ctx, download, name)
+	if ok {
+		return pinned, nil
+	}
+
+	err := u.preflight(ctx, download, size)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadResp, err := u.downloadFile(ctx, download, filename, name)
+	if err == nil {
+		return downloadResp, nil
+	}
+
+	// Trying again won't make space, or change the size, or the error page
+	// of the origin, and there's no time left when the job timed out.
+	if errors.Is(err, ErrNoSpace) ||
+		errors.Is(err, ErrEpisodeTooLarge) ||
+		errors.Is(err, ErrEpisodeTooSmall) ||
+		errors.Is(err, ErrNotMedia) ||
+		ctx.Err() != nil {
+		return nil, err
+	}
+
+	u.log.Error("download failed, try pin", "err", err, "download", download)
+
+	url, err := url.Parse(download)
+	if err != nil {
+		u.log.Info("parse download url failed", "err", err, "download", download)
+
+		return u.downloadFile(ctx, download, filename, name)
+	}
+
+	downloadCid, err := u.gatewayCID(ctx, url)
+	if err != nil {
+		if !errors.Is(err, errNotGateway) {
+			u.log.Info("finding cid failed", "err", err, "download", download)
+		}
+
+		return u.downloadFile(ctx, download, filename, name)
+	}
+
+	u.log.Info("found ipfs file", "download", download, "cid", downloadCid)
+
+	pin, err := u.pinFile(ctx, downloadCid.String(), name)
+	if err != nil {
+		u.log.Error("pin instead of download failed", "err", err)
+
+		return u.downloadFile(ctx, download, filename, name)
+	}
+
+	return &downloadFileResponse{
+		DownloadedFile: pin.Pinned,
+		File:           pin.File,
+		Dir:            downloadCid.String(),
+		Length:         pin.Length,
+	}, nil
+}
+
+func (u *Updater) downloadFile(ctx context.Context, download string, filename string, name string) (*downloadFileResponse, error) {
+	start := time.Now()
+
+	// The origin and Kubo each get their own deadline, which only runs
+	// while they hold up the download, so a large episode can take as long
+	// as it needs, as long as the bytes keep flowing.
+	downloadCtx, cancelDownload := context.WithCancelCause(ctx)
+	defer cancelDownload(nil)
+
+	downloadDeadline := newPhaseDeadline(u.opts.DownloadTimeout, cancelDownload, ErrDownloadTimeout)
+	defer downloadDeadline.stop()
+
+	req, err := http.NewRequestWithContext(downloadCtx, http.MethodGet, download, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	downloadDeadline.start()
+	downloadResp, err := u.downloadClient.Do(req)
+	downloadDeadline.stop()
+
+	u.observeDownloadResponse(download, downloadResp, err)
+	if err != nil {
+		return nil, downloadError(downloadDeadline.wrap(err))
+	}
+	defer downloadResp.Body.Close()
+
+	u.log.Debug(
+		"download response",
+		"download", download,
+		"status", downloadResp.StatusCode,
+		"content_length", downloadResp.ContentLength,
+		"duration", time.Since(start),
+	)
+
+	if downloadResp.StatusCode != http.StatusOK {
+		return nil, &DownloadStatusError{
+			StatusCode: downloadResp.StatusCode,
+			RetryAfter: workapi.ParseRetryAfter(downloadResp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	// Fail early if the origin tells us the file is too small, or too large
+	// for the quota. The size is checked again after the add, because the
+	// length isn't always known.
+	if downloadResp.ContentLength != -1 {
+		err = u.checkEpisodeSize(int(downloadResp.ContentLength))
+		if err != nil {
+			return nil, err
+		}
+
+		err = u.checkQuota(int(downloadResp.ContentLength))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Some origins respond to episode URLs with an HTML error page, which
+	// must not be pinned as the episode.
+	err = checkContentType(downloadResp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	head := bufio.NewReaderSize(waitingReader{
+		r:        downloadResp.Body,
+		deadline: downloadDeadline,
+	}, sniffLength)
+
+	if u.opts.SniffContent {
+		sniffed, err := head.Peek(sniffLength)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, downloadError(downloadDeadline.wrap(err))
+		}
+
+		err = checkMediaSignature(sniffed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	origin := &originReader{r: u.limitDownload(ctx, head)}
+
+	body, mirror := u.startMirror(ctx, origin, downloadResp.ContentLength)
+	defer mirror.abort()
+
+	addCtx, cancelAdd := context.WithCancelCause(ctx)
+	defer cancelAdd(nil)
+
+	addDeadline := newPhaseDeadline(u.opts.AddTimeout, cancelAdd, ErrAddTimeout)
+	defer addDeadline.stop()
+
+	// The bytes are counted, and hashed, while they're streamed to Kubo,
+	// so the size doesn't need another request, which walks the DAG of the
+	// file.
+	progress := u.startProgress(ctx, download, downloadResp.ContentLength)
+	defer progress.stop()
+
+	hash := sha256.New()
+
+	addDeadline.start()
+	added, err := u.kubo.AddWrapped(
+		addCtx,
+		busyReader{r: io.TeeReader(body, io.MultiWriter(progress, hash)), deadline: addDeadline},
+		filename,
+		u.opts.AddOptions,
+	)
+	addDeadline.stop()
+
+	if err != nil {
+		// The add fails when reading the download fails, which is the
+		// origin's fault, not Kubo's.
+		originErr := origin.failure()
+		if originErr != nil {
+			return nil, fmt.Errorf("add failed: %w", downloadError(downloadDeadline.wrap(originErr)))
+		}
+
+		return nil, fmt.Errorf("add failed: %w", addDeadline.wrap(kuboError(err)))
+	}
+
+	// The add streams the body, so this is the time of the download.
+	duration := time.Since(start)
+
+	size := int(progress.read.Load())
+
+	u.observeDownload(downloadResp.Request.URL.Host, size, duration)
+
+	err = errors.Join(checkAddedSize(added.File, size), u.checkEpisodeSize(size), u.checkQuota(size))
+	if err != nil {
+		u.removeAdded(ctx, added.Dir.Hash, "unpin of rejected episode failed")
+
+		return nil, err
+	}
+
+	// Add already pinned the directory on Kubo. Pinning it again names the
+	// pin, and lets the cluster pin it on its peers.
+	err = u.pinner.Pin(ctx, added.Dir.Hash, name)
+	if err != nil {
+		u.removeAdded(ctx, added.Dir.Hash, "unpin of unfinished download failed")
+
+		return nil, fmt.Errorf("pinning added directory failed: %w", err)
+	}
+
+	return &downloadFileResponse{
+		DownloadedFile: added.File.Hash + "/" + added.Dir.Hash,
+		File:           added.File.Hash,
+		Dir:            added.Dir.Hash,
+		Length:         size,
+		SHA256:         hex.EncodeToString(hash.Sum(nil)),
+		MirrorKey:      u.finishMirror(ctx, mirror, added.Dir.Hash, filename),
+	}, nil
+}
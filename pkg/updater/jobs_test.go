@@ -0,0 +1,306 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubotest"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// testEpisode is a tiny MP3, as far as the signature goes.
+var testEpisode = append([]byte("ID3"), bytes.Repeat([]byte{0}, 1000)...)
+
+func TestDownloadJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		contentType string
+		addError    string
+		// reason is the error_code of the result, or empty if it
+		// succeeds.
+		reason string
+	}{
+		{
+			name:        "success",
+			contentType: "audio/mpeg",
+		},
+		{
+			name:        "too small",
+			opts:        Options{MinEpisodeSize: 2000},
+			contentType: "audio/mpeg",
+			reason:      "too_small",
+		},
+		{
+			name:        "too large",
+			opts:        Options{MaxEpisodeSize: 500},
+			contentType: "audio/mpeg",
+			reason:      "too_large",
+		},
+		{
+			name:        "over quota",
+			opts:        Options{MaxStorage: 500},
+			contentType: "audio/mpeg",
+			reason:      "no_space",
+		},
+		{
+			name:        "error page",
+			contentType: "text/html; charset=utf-8",
+			reason:      "not_media",
+		},
+		{
+			name:        "add failure",
+			contentType: "audio/mpeg",
+			addError:    "blockstore: write failed",
+			reason:      "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, node, server := newTestUpdater(t, tt.opts)
+			origin := newOrigin(t, tt.contentType, testEpisode)
+
+			node.SetError("add", tt.addError)
+
+			result := runJob(t, u, server, workapi.Work{
+				Show:     "show",
+				Episode:  "episode",
+				Download: origin.URL + "/episode.mp3",
+				Filename: "episode.mp3",
+			})
+
+			if tt.reason != "" {
+				if result.Error == nil || result.ErrorReason != tt.reason {
+					t.Fatalf("got error_code %q, want %q", result.ErrorReason, tt.reason)
+				}
+
+				if result.Downloaded != nil {
+					t.Errorf("failed download reported as downloaded: %s", *result.Downloaded)
+				}
+
+				if pins := node.Pins(); len(pins) != 0 {
+					t.Errorf("failed download left pins: %v", pins)
+				}
+
+				if episodes := u.Catalog().Episodes(); len(episodes) != 0 {
+					t.Errorf("failed download is in the catalog: %v", episodes)
+				}
+
+				return
+			}
+
+			if result.Error != nil {
+				t.Fatalf("download failed: %s: %s", result.ErrorReason, result.ErrorMessage)
+			}
+
+			if result.Length == nil || *result.Length != len(testEpisode) {
+				t.Errorf("got length %v, want %d", result.Length, len(testEpisode))
+			}
+
+			episodes := u.Catalog().Episodes()
+			if len(episodes) != 1 {
+				t.Fatalf("got %d episodes in the catalog, want 1", len(episodes))
+			}
+
+			episode := episodes[0]
+
+			if *result.Downloaded != episode.File+"/"+episode.Hash {
+				t.Errorf("got downloaded %s, want %s/%s", *result.Downloaded, episode.File, episode.Hash)
+			}
+
+			if name, ok := node.Pins()[episode.Hash]; !ok || name != "show/episode" {
+				t.Errorf("directory %s isn't pinned as show/episode: %v", episode.Hash, node.Pins())
+			}
+
+			content, ok := node.Content(episode.File)
+			if !ok || !bytes.Equal(content, testEpisode) {
+				t.Errorf("added file %s isn't the episode", episode.File)
+			}
+		})
+	}
+}
+
+func TestPinJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		pinError string
+		// pinFile pins the CID of the file, instead of the directory
+		// wrapping it.
+		pinFile bool
+		reason  string
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:    "not a wrapped file",
+			pinFile: true,
+			reason:  "cid_mismatch",
+		},
+		{
+			name:     "pin failure",
+			pinError: "context deadline exceeded",
+			reason:   "error",
+		},
+		{
+			name:   "too small",
+			opts:   Options{MinEpisodeSize: 2000},
+			reason: "too_small",
+		},
+		{
+			name:   "too large",
+			opts:   Options{MaxEpisodeSize: 500},
+			reason: "too_large",
+		},
+		{
+			name:   "over quota",
+			opts:   Options{MaxStorage: 500},
+			reason: "no_space",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, node, server := newTestUpdater(t, tt.opts)
+
+			dir, file := node.Provide("episode.mp3", testEpisode)
+			node.SetError("pin/add", tt.pinError)
+
+			pin := dir
+			if tt.pinFile {
+				pin = file
+			}
+
+			result := runJob(t, u, server, workapi.Work{
+				Show:    "show",
+				Episode: "episode",
+				Pin:     pin,
+			})
+
+			if tt.reason != "" {
+				if result.Error == nil || result.ErrorReason != tt.reason {
+					t.Fatalf("got error_code %q, want %q", result.ErrorReason, tt.reason)
+				}
+
+				if pins := node.Pins(); len(pins) != 0 {
+					t.Errorf("failed pin left pins: %v", pins)
+				}
+
+				return
+			}
+
+			if result.Error != nil {
+				t.Fatalf("pin failed: %s: %s", result.ErrorReason, result.ErrorMessage)
+			}
+
+			if result.Pinned == nil || *result.Pinned != file+"/"+dir {
+				t.Errorf("got pinned %v, want %s/%s", result.Pinned, file, dir)
+			}
+
+			if result.Length == nil || *result.Length != len(testEpisode) {
+				t.Errorf("got length %v, want %d", result.Length, len(testEpisode))
+			}
+
+			if name, ok := node.Pins()[dir]; !ok || name != "show/episode" {
+				t.Errorf("directory %s isn't pinned as show/episode: %v", dir, node.Pins())
+			}
+
+			if _, ok := u.Catalog().Get(dir); !ok {
+				t.Errorf("directory %s isn't in the catalog", dir)
+			}
+		})
+	}
+}
+
+func TestDeleteJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		pinned  bool
+		rmError string
+		reason  string
+	}{
+		{
+			name:   "success",
+			pinned: true,
+		},
+		{
+			// The server sends deletes of episodes the node doesn't
+			// have, which are done already.
+			name: "not pinned",
+		},
+		{
+			name:    "unpin failure",
+			pinned:  true,
+			rmError: "pin is locked",
+			reason:  "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, node, server := newTestUpdater(t, Options{})
+
+			dir, _ := node.Provide("episode.mp3", testEpisode)
+
+			if tt.pinned {
+				pinEpisode(t, u, node, dir)
+			}
+
+			node.SetError("pin/rm", tt.rmError)
+
+			result := runJob(t, u, server, workapi.Work{
+				Show:    "show",
+				Episode: "episode",
+				Delete:  dir,
+			})
+
+			if tt.reason != "" {
+				if result.Error == nil || result.ErrorReason != tt.reason {
+					t.Fatalf("got error_code %q, want %q", result.ErrorReason, tt.reason)
+				}
+
+				if _, ok := u.Catalog().Get(dir); !ok {
+					t.Errorf("directory %s was removed from the catalog, but is still pinned", dir)
+				}
+
+				return
+			}
+
+			if result.Error != nil {
+				t.Fatalf("delete failed: %s: %s", result.ErrorReason, result.ErrorMessage)
+			}
+
+			if result.Deleted == nil || *result.Deleted != dir {
+				t.Errorf("got deleted %v, want %s", result.Deleted, dir)
+			}
+
+			if _, ok := node.Pins()[dir]; ok {
+				t.Errorf("directory %s is still pinned", dir)
+			}
+
+			if _, ok := u.Catalog().Get(dir); ok {
+				t.Errorf("directory %s is still in the catalog", dir)
+			}
+		})
+	}
+}
+
+// pinEpisode pins the directory dir, and adds it to the catalog, like a pin
+// job did.
+func pinEpisode(t *testing.T, u *Updater, node *kubotest.Server, dir string) {
+	t.Helper()
+
+	pinned, err := u.pinFile(context.Background(), dir, "show/episode")
+	if err != nil {
+		t.Fatalf("pinning %s failed: %v", dir, err)
+	}
+
+	u.addToCatalog(context.Background(), &workapi.Work{Show: "show", Episode: "episode"}, dir, pinned.File, pinned.Length, "", "")
+
+	if _, ok := node.Pins()[dir]; !ok {
+		t.Fatalf("directory %s isn't pinned", dir)
+	}
+}
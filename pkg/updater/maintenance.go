@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time window in local time, like a maintenance window, in
+// which heavy maintenance tasks, like gc, reconciliation, and verification,
+// are allowed to run, or the window of a bandwidth profile.
+type Window struct {
+	// Start and End are offsets from midnight. A window which ends before
+	// it starts wraps around midnight.
+	Start time.Duration
+	End   time.Duration
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWindow parses a window in the form 02:00-05:00.
+func ParseWindow(s string) (Window, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("window must be in the form 02:00-05:00: %q", s)
+	}
+
+	start, err := parseTimeOfDay(startStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("parsing window start failed: %w", err)
+	}
+
+	end, err := parseTimeOfDay(endStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("parsing window end failed: %w", err)
+	}
+
+	return Window{
+		Start: start,
+		End:   end,
+	}, nil
+}
+
+func (w Window) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+
+	return format(w.Start) + "-" + format(w.End)
+}
+
+// Contains is if t is inside the window.
+func (w Window) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	sinceMidnight := t.Sub(midnight)
+
+	if w.Start <= w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+// inMaintenanceWindow is if maintenance tasks may run at t. Without any
+// windows, they may always run.
+func (u *Updater) inMaintenanceWindow(t time.Time) bool {
+	if len(u.opts.MaintenanceWindows) == 0 {
+		return true
+	}
+
+	for _, window := range u.opts.MaintenanceWindows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runMaintenance runs the heavy maintenance tasks, if inside a maintenance
+// window. Tasks which are needed outside a window are run in the next one,
+// except for a reconciliation triggered from the dashboard.
+func (u *Updater) runMaintenance(ctx context.Context) {
+	if u.reconcileRequested.Swap(false) {
+		err := u.reconcile(ctx)
+		if err != nil {
+			u.log.Error("reconciliation failed", "err", err)
+		}
+	}
+
+	if !u.inMaintenanceWindow(time.Now()) {
+		return
+	}
+
+	u.maybeReconcile(ctx)
+	u.maybeVerify(ctx)
+	u.maybeGC(ctx)
+}
@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window string
+		// valid is if the window parses, and inside and outside are times
+		// of day in and out of it.
+		valid   bool
+		inside  []string
+		outside []string
+	}{
+		{
+			name:    "same day",
+			window:  "02:00-05:00",
+			valid:   true,
+			inside:  []string{"02:00", "03:30", "04:59"},
+			outside: []string{"01:59", "05:00", "23:00"},
+		},
+		{
+			name:    "past midnight",
+			window:  "22:30-03:00",
+			valid:   true,
+			inside:  []string{"22:30", "23:59", "00:00", "02:59"},
+			outside: []string{"03:00", "12:00", "22:29"},
+		},
+		{
+			name:    "until midnight",
+			window:  "20:00-00:00",
+			valid:   true,
+			inside:  []string{"20:00", "23:59"},
+			outside: []string{"00:00", "19:59"},
+		},
+		{name: "missing end", window: "02:00"},
+		{name: "empty", window: ""},
+		{name: "invalid start", window: "2am-05:00"},
+		{name: "invalid end", window: "02:00-25:00"},
+		{name: "seconds", window: "02:00:00-05:00:00"},
+		{name: "extra dash", window: "02:00-05:00-06:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ParseWindow(tt.window)
+
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("got %s, want an error", window)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsing failed: %v", err)
+			}
+
+			if window.String() != tt.window {
+				t.Errorf("got %s, want %s", window, tt.window)
+			}
+
+			day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+
+			for _, s := range tt.inside {
+				offset, _ := parseTimeOfDay(s)
+
+				if !window.Contains(day.Add(offset)) {
+					t.Errorf("%s isn't inside %s", s, window)
+				}
+			}
+
+			for _, s := range tt.outside {
+				offset, _ := parseTimeOfDay(s)
+
+				if window.Contains(day.Add(offset)) {
+					t.Errorf("%s is inside %s", s, window)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+)
+
+// manifestLifetime is how long a published manifest record is valid. It's
+// republished well before, every ManifestRepublish.
+const manifestLifetime = 48 * time.Hour
+
+// Manifest lists the episodes hosted by a node. It's published under an IPNS
+// name as manifest.json, in a directory.
+type Manifest struct {
+	Version  int               `json:"version"`
+	Node     string            `json:"node"`
+	Episodes []catalog.Episode `json:"episodes"`
+}
+
+// publishManifest adds the manifest of the catalog to Kubo, and publishes it
+// under the IPNS name of the ManifestKey, if it changed, or if the record
+// needs republishing.
+func (u *Updater) publishManifest(ctx context.Context) error {
+	manifest := Manifest{
+		Version:  1,
+		Node:     u.opts.Name,
+		Episodes: u.opts.Catalog.Episodes(),
+	}
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest failed: %w", err)
+	}
+
+	added, err := u.kubo.AddWrapped(ctx, bytes.NewReader(body), "manifest.json", u.opts.AddOptions)
+	if err != nil {
+		return fmt.Errorf("adding manifest failed: %w", err)
+	}
+
+	changed := added.Dir.Hash != u.manifestHash
+
+	if !changed && time.Since(u.lastManifestPublish) < u.opts.ManifestRepublish {
+		return nil
+	}
+
+	published, err := u.kubo.NamePublish(ctx, "/ipfs/"+added.Dir.Hash, u.opts.ManifestKey, manifestLifetime)
+	if err != nil {
+		return fmt.Errorf("publishing manifest failed: %w", err)
+	}
+
+	u.log.Info(
+		"published manifest",
+		"name", published.Name,
+		"cid", added.Dir.Hash,
+		"episodes", len(manifest.Episodes),
+		"changed", changed,
+	)
+
+	if changed && u.manifestHash != "" {
+		err := u.kubo.PinRm(ctx, u.manifestHash)
+		if err != nil {
+			u.log.Warn("unpinning previous manifest failed", "cid", u.manifestHash, "err", err)
+		}
+	}
+
+	u.manifestHash = added.Dir.Hash
+	u.lastManifestPublish = time.Now()
+
+	return nil
+}
+
+// maybePublishManifest publishes the manifest, if publishing is enabled.
+func (u *Updater) maybePublishManifest(ctx context.Context) {
+	if u.opts.ManifestKey == "" {
+		return
+	}
+
+	err := u.publishManifest(ctx)
+	if err != nil {
+		u.log.Error("publishing manifest failed", "err", err)
+	}
+}
@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// sniffLength is the number of bytes read from the start of a download to
+// check its signature.
+const sniffLength = 512
+
+// textContentTypes are the content types of documents, which no episode
+// has, unlike application/octet-stream, which some origins send for any
+// file.
+var textContentTypes = []string{
+	"application/json",
+	"application/xhtml+xml",
+	"application/xml",
+}
+
+// checkContentType fails for downloads with the content type of a document,
+// like text/html. A missing or unknown content type is fine, because many
+// origins don't set it correctly.
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") {
+		return fmt.Errorf("%w: content type %s", ErrNotMedia, mediaType)
+	}
+
+	for _, textType := range textContentTypes {
+		if mediaType == textType {
+			return fmt.Errorf("%w: content type %s", ErrNotMedia, mediaType)
+		}
+	}
+
+	return nil
+}
+
+// mediaSignatures are the first bytes of audio and video containers. The
+// MPEG audio frame sync, which has no fixed bytes, is checked separately.
+var mediaSignatures = []struct {
+	offset    int
+	signature []byte
+}{
+	{0, []byte("ID3")},                  // MP3 with an ID3v2 tag
+	{0, []byte("OggS")},                 // Ogg Vorbis, Opus
+	{0, []byte("fLaC")},                 // FLAC
+	{0, []byte("RIFF")},                 // WAV, AVI
+	{0, []byte("FORM")},                 // AIFF
+	{0, []byte{0x1a, 0x45, 0xdf, 0xa3}}, // Matroska, WebM
+	{0, []byte{0x30, 0x26, 0xb2, 0x75}}, // ASF, WMA, WMV
+	{0, []byte{0x47}},                   // MPEG transport stream
+	{4, []byte("ftyp")},                 // MP4, M4A
+	{4, []byte("moov")},                 // QuickTime
+	{4, []byte("mdat")},                 // QuickTime
+}
+
+// checkMediaSignature checks that head, the start of a download, is an audio
+// or video container.
+func checkMediaSignature(head []byte) error {
+	for _, media := range mediaSignatures {
+		if bytes.HasPrefix(head[min(media.offset, len(head)):], media.signature) {
+			return nil
+		}
+	}
+
+	// The frame sync of MPEG audio, and of AAC in ADTS, is 11 set bits.
+	if len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: content looks like %s", ErrNotMedia, http.DetectContentType(head))
+}
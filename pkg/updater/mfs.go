@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+)
+
+// mfsName makes a show or episode name safe to use as an MFS path segment.
+func mfsName(name string, fallback string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "/", "_"))
+
+	if name == "" || name == "." || name == ".." {
+		return fallback
+	}
+
+	return name
+}
+
+// mfsPath is where the episode is in MFS, as <MFSDir>/<show>/<episode>.
+func (u *Updater) mfsPath(episode catalog.Episode) string {
+	return path.Join(
+		u.opts.MFSDir,
+		mfsName(episode.Show, "unknown"),
+		mfsName(episode.Episode, episode.Hash),
+	)
+}
+
+// addToMFS copies the episode into the MFS library, replacing what was
+// there before.
+func (u *Updater) addToMFS(ctx context.Context, episode catalog.Episode) error {
+	if u.opts.MFSDir == "" {
+		return nil
+	}
+
+	dst := u.mfsPath(episode)
+
+	err := u.kubo.FilesMkdir(ctx, path.Dir(dst))
+	if err != nil {
+		return fmt.Errorf("creating mfs directory failed: %w", err)
+	}
+
+	err = u.kubo.FilesRm(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("removing previous mfs entry failed: %w", err)
+	}
+
+	err = u.kubo.FilesCp(ctx, "/ipfs/"+episode.Hash, dst)
+	if err != nil {
+		return fmt.Errorf("copying to mfs failed: %w", err)
+	}
+
+	return nil
+}
+
+// removeFromMFS removes the episode from the MFS library. MFS keeps its
+// content from being garbage collected, so the episode has to be removed
+// from it, as well as unpinned, to free the space.
+func (u *Updater) removeFromMFS(ctx context.Context, episode catalog.Episode) {
+	if u.opts.MFSDir == "" {
+		return
+	}
+
+	err := u.kubo.FilesRm(ctx, u.mfsPath(episode))
+	if err != nil {
+		u.log.Error("removing from mfs failed", "cid", episode.Hash, "err", err)
+	}
+}
@@ -0,0 +1,267 @@
+package updater
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures the S3 compatible bucket, like AWS S3, Backblaze B2,
+// or MinIO, which the downloaded episodes are mirrored to.
+type S3Config struct {
+	// Endpoint is the URL of the S3 API, like https://s3.amazonaws.com.
+	Endpoint string
+	// Region of the bucket. Empty lets the client look it up.
+	Region string
+	Bucket string
+	// Prefix is prepended to the object keys, like "podcasts/".
+	Prefix string
+
+	// AccessKey and SecretKey of the bucket. If AccessKey is empty, they
+	// are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	// environment variables.
+	AccessKey string
+	SecretKey string
+}
+
+// S3Mirror uploads the downloaded episodes to an S3 compatible bucket, as a
+// backup outside of IPFS.
+type S3Mirror struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Mirror creates an S3Mirror for the bucket of config.
+func NewS3Mirror(config S3Config) (*S3Mirror, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing s3 endpoint failed: %w", err)
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("s3 endpoint must be an http:// or https:// URL")
+	}
+
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	creds := credentials.NewEnvAWS()
+	if config.AccessKey != "" {
+		creds = credentials.NewStaticV4(config.AccessKey, config.SecretKey, "")
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  creds,
+		Secure: endpoint.Scheme == "https",
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating s3 client failed: %w", err)
+	}
+
+	return &S3Mirror{
+		client: client,
+		bucket: config.Bucket,
+		prefix: config.Prefix,
+	}, nil
+}
+
+// Key is the object key of the file of the episode with the directory hash.
+func (m *S3Mirror) Key(hash string, filename string) string {
+	return m.prefix + hash + "/" + path.Base(filename)
+}
+
+// mirrorUpload streams a download to the bucket while it's added to Kubo.
+// The hash isn't known until the add is done, so the file is uploaded to a
+// temporary key, and copied to its final key by the bucket.
+type mirrorUpload struct {
+	mirror *S3Mirror
+	key    string
+	pw     *io.PipeWriter
+	done   chan error
+
+	// err is the first error writing to the upload. Later writes are
+	// dropped, so a failed upload doesn't fail the download. The add can
+	// still be writing when it fails, so it's guarded by mu.
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// errMirrorAborted stops the upload of a download which wasn't hosted.
+var errMirrorAborted = errors.New("download aborted")
+
+// upload starts uploading the data written to the returned mirrorUpload.
+// size is the length of the file, or -1 if it's unknown.
+func (m *S3Mirror) upload(ctx context.Context, size int64) *mirrorUpload {
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+
+	pr, pw := io.Pipe()
+
+	upload := &mirrorUpload{
+		mirror: m,
+		key:    m.prefix + ".uploads/" + hex.EncodeToString(random),
+		pw:     pw,
+		done:   make(chan error, 1),
+	}
+
+	go func() {
+		_, err := m.client.PutObject(ctx, m.bucket, upload.key, pr, size, minio.PutObjectOptions{})
+		pr.CloseWithError(err)
+
+		upload.done <- err
+	}()
+
+	return upload
+}
+
+func (mu *mirrorUpload) Write(p []byte) (int, error) {
+	if mu.failure() != nil {
+		return len(p), nil
+	}
+
+	_, err := mu.pw.Write(p)
+	if err != nil {
+		mu.fail(err)
+	}
+
+	return len(p), nil
+}
+
+func (mu *mirrorUpload) failure() error {
+	mu.mu.Lock()
+	defer mu.mu.Unlock()
+
+	return mu.err
+}
+
+func (mu *mirrorUpload) fail(err error) {
+	mu.mu.Lock()
+	defer mu.mu.Unlock()
+
+	if mu.err == nil {
+		mu.err = err
+	}
+}
+
+// close ends the data of the upload, with err if it's not nil, and waits
+// for the upload. Only the first call closes it.
+func (mu *mirrorUpload) close(err error) error {
+	if !mu.closed {
+		mu.closed = true
+
+		mu.pw.CloseWithError(err)
+
+		// The error of the upload explains why writing to it failed.
+		uploadErr := <-mu.done
+		if uploadErr != nil {
+			mu.mu.Lock()
+			mu.err = uploadErr
+			mu.mu.Unlock()
+		}
+	}
+
+	return mu.failure()
+}
+
+// abort stops the upload if it wasn't finished, like when the add failed.
+// It's safe to call on a nil upload, when mirroring is disabled.
+func (mu *mirrorUpload) abort() {
+	if mu == nil {
+		return
+	}
+
+	_ = mu.close(errMirrorAborted)
+}
+
+// finish waits for the upload, and copies it to the key of the episode with
+// the directory hash.
+func (mu *mirrorUpload) finish(ctx context.Context, hash string, filename string) (string, error) {
+	err := mu.close(nil)
+	if err != nil {
+		return "", fmt.Errorf("uploading failed: %w", err)
+	}
+
+	defer mu.mirror.remove(ctx, mu.key)
+
+	key := mu.mirror.Key(hash, filename)
+
+	_, err = mu.mirror.client.CopyObject(
+		ctx,
+		minio.CopyDestOptions{Bucket: mu.mirror.bucket, Object: key},
+		minio.CopySrcOptions{Bucket: mu.mirror.bucket, Object: mu.key},
+	)
+	if err != nil {
+		return "", fmt.Errorf("copying upload failed: %w", err)
+	}
+
+	return key, nil
+}
+
+func (m *S3Mirror) remove(ctx context.Context, key string) error {
+	err := m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("removing object failed: %w", err)
+	}
+
+	return nil
+}
+
+// startMirror starts mirroring the download, if mirroring is enabled, and
+// returns the body to add to Kubo, which also writes to the mirror.
+func (u *Updater) startMirror(ctx context.Context, body io.Reader, size int64) (io.Reader, *mirrorUpload) {
+	if u.opts.Mirror == nil {
+		return body, nil
+	}
+
+	upload := u.opts.Mirror.upload(ctx, size)
+
+	return io.TeeReader(body, upload), upload
+}
+
+// finishMirror completes the upload of the download, and returns its key.
+// Failures are logged, and return an empty key, the episode is still hosted
+// on IPFS.
+func (u *Updater) finishMirror(ctx context.Context, upload *mirrorUpload, hash string, filename string) string {
+	if upload == nil {
+		return ""
+	}
+
+	key, err := upload.finish(ctx, hash, filename)
+	if err != nil {
+		metrics.MirrorUploads.WithLabelValues(u.opts.Name, "failed").Inc()
+		u.log.Error("mirroring episode failed", "cid", hash, "err", err)
+
+		return ""
+	}
+
+	metrics.MirrorUploads.WithLabelValues(u.opts.Name, "ok").Inc()
+	u.log.Info("mirrored episode", "cid", hash, "key", key)
+
+	return key
+}
+
+// removeFromMirror removes the mirrored file of a deleted episode.
+func (u *Updater) removeFromMirror(ctx context.Context, key string) {
+	if u.opts.Mirror == nil || key == "" {
+		return
+	}
+
+	err := u.opts.Mirror.remove(ctx, key)
+	if err != nil {
+		u.log.Error("removing mirrored episode failed", "key", key, "err", err)
+	}
+}
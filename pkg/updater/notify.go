@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/notify"
+)
+
+// notify sends an event to the notifier, if there is one.
+func (u *Updater) notify(eventType notify.EventType, message string, fields map[string]any) {
+	if u.opts.Notifier == nil {
+		return
+	}
+
+	u.opts.Notifier.Notify(notify.Event{
+		Type:    eventType,
+		Node:    u.opts.Name,
+		Time:    time.Now(),
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// notifyKubo sends a kubo_unreachable event when Kubo stops responding. It's
+// only sent again after Kubo was reachable in between.
+func (u *Updater) notifyKubo(err error) {
+	wasDown := u.kuboDown
+	u.kuboDown = err != nil
+
+	if u.kuboDown && !wasDown {
+		u.notify(notify.EventKuboUnreachable, "Kubo is unreachable", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+// notifyDisk sends a disk_low event when the free disk space drops below the
+// minimum. It's only sent again after the disk space recovered in between.
+func (u *Updater) notifyDisk(err error) {
+	wasLow := u.diskLow
+	u.diskLow = err != nil
+
+	if u.diskLow && !wasLow {
+		u.notify(notify.EventDiskLow, "Free disk space is below the minimum", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+// notifyNoWork sends a no_work event once the node went NoWorkAlertCycles
+// cycles in a row without any work.
+func (u *Updater) notifyNoWork(gotWork bool) {
+	if gotWork {
+		u.noWorkStreak = 0
+
+		return
+	}
+
+	u.noWorkStreak += 1
+
+	if u.opts.NoWorkAlertCycles != 0 && u.noWorkStreak == u.opts.NoWorkAlertCycles {
+		u.notify(notify.EventNoWork, "No work from the server", map[string]any{
+			"cycles": u.noWorkStreak,
+		})
+	}
+}
+
+// notifyFailing sends a failing event once every cycle failed for
+// FailureAlertAfter, with the last error and some stats of the node. It's
+// only sent again after a cycle succeeded in between.
+func (u *Updater) notifyFailing(ctx context.Context, start time.Time, err error) {
+	if err == nil {
+		u.failingSince = time.Time{}
+		u.failingSent = false
+
+		return
+	}
+
+	if u.failingSince.IsZero() {
+		u.failingSince = start
+	}
+
+	failingFor := time.Since(u.failingSince)
+
+	if u.opts.FailureAlertAfter == 0 || u.failingSent || failingFor < u.opts.FailureAlertAfter {
+		return
+	}
+
+	u.failingSent = true
+
+	fields := map[string]any{
+		"last_error":    err.Error(),
+		"failing_since": u.failingSince.Format(time.RFC3339),
+		"failing_for":   failingFor.Round(time.Second).String(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	peers, err := u.kubo.Peers(ctx)
+	if err == nil {
+		fields["peers"] = peers
+	}
+
+	stat, err := u.kubo.RepoStat(ctx)
+	if err == nil {
+		fields["repo_size"] = stat.RepoSize
+		fields["storage_max"] = stat.StorageMax
+		fields["num_objects"] = stat.NumObjects
+	}
+
+	u.notify(notify.EventFailing, "Every work cycle is failing", fields)
+}
@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// peeringState is the backbone peers added to Kubo's peering.
+type peeringState struct {
+	// peers are the IDs of the peers which were added, so the ones the
+	// server stops listing are removed again, but the peers the operator
+	// added themselves are left alone.
+	peers    map[string]struct{}
+	lastSync time.Time
+}
+
+// maybeSyncPeering syncs the peering, if it wasn't done within the peering
+// interval.
+func (u *Updater) maybeSyncPeering(ctx context.Context) {
+	if !u.opts.Peering || time.Since(u.peering.lastSync) < u.opts.PeeringInterval {
+		return
+	}
+
+	err := u.syncPeering(ctx)
+	if err != nil {
+		u.log.Warn("syncing peering failed", "err", err)
+	}
+}
+
+// syncPeering adds the backbone peers listed by the work server to Kubo's
+// peering, which keeps connections to them, and removes the peers it added
+// before, which aren't listed anymore. Kubo forgets the peers when it
+// restarts, so they're added again on every sync.
+func (u *Updater) syncPeering(ctx context.Context) error {
+	// A server which is down isn't asked again before the next interval.
+	u.peering.lastSync = time.Now()
+
+	peers, err := u.workClient.Peers(ctx, u.accounts.emails...)
+	if errors.Is(err, workapi.ErrPeersNotSupported) {
+		u.log.Debug("work server doesn't list peers")
+
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	nID, err := u.kubo.ID(ctx)
+	if err != nil {
+		return fmt.Errorf("getting node id failed: %w", err)
+	}
+
+	added := make(map[string]struct{}, len(peers))
+
+	for _, peer := range peers {
+		if peer.ID == "" || peer.ID == nID.ID {
+			continue
+		}
+
+		addrs := make([]string, 0, max(len(peer.Addrs), 1))
+		for _, addr := range peer.Addrs {
+			addrs = append(addrs, addr+"/p2p/"+peer.ID)
+		}
+
+		// Without addresses, Kubo looks the peer up in the DHT.
+		if len(addrs) == 0 {
+			addrs = append(addrs, "/p2p/"+peer.ID)
+		}
+
+		err := u.kubo.PeeringAdd(ctx, addrs...)
+		if err != nil {
+			u.log.Warn("adding peer failed", "peer", peer.ID, "err", err)
+
+			continue
+		}
+
+		added[peer.ID] = struct{}{}
+	}
+
+	for id := range u.peering.peers {
+		_, ok := added[id]
+		if ok {
+			continue
+		}
+
+		err := u.kubo.PeeringRm(ctx, id)
+		if err != nil {
+			u.log.Warn("removing peer failed", "peer", id, "err", err)
+
+			// Tried again at the next sync.
+			added[id] = struct{}{}
+		}
+	}
+
+	if len(added) != len(u.peering.peers) {
+		u.log.Info("synced peering with the backbone peers", "peers", len(added))
+	}
+
+	u.peering.peers = added
+	metrics.PeeringPeers.WithLabelValues(u.opts.Name).Set(float64(len(added)))
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// loadPending reads the results which couldn't be sent before a restart.
+func (u *Updater) loadPending() error {
+	if u.opts.PendingPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(u.opts.PendingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading pending results failed: %w", err)
+	}
+
+	err = json.Unmarshal(data, &u.pending)
+	if err != nil {
+		return fmt.Errorf("decoding pending results failed: %w", err)
+	}
+
+	u.pendingHeld = len(u.pending) != 0
+
+	metrics.PendingResponses.WithLabelValues(u.opts.Name).Set(float64(len(u.pending)))
+
+	return nil
+}
+
+// savePending writes the pending results through a temporary file, which is
+// synced before it replaces the old one, so the results of finished jobs
+// survive a crash or a power cut, or removes the file when there are none
+// left.
+func (u *Updater) savePending() error {
+	metrics.PendingResponses.WithLabelValues(u.opts.Name).Set(float64(len(u.pending)))
+
+	if u.opts.PendingPath == "" {
+		return nil
+	}
+
+	if len(u.pending) == 0 {
+		err := os.Remove(u.opts.PendingPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing pending results failed: %w", err)
+		}
+
+		return nil
+	}
+
+	data, err := json.Marshal(u.pending)
+	if err != nil {
+		return fmt.Errorf("encoding pending results failed: %w", err)
+	}
+
+	dir := filepath.Dir(u.opts.PendingPath)
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("creating pending results directory failed: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, ".pending-*.json")
+	if err != nil {
+		return fmt.Errorf("creating temp file failed: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("writing pending results failed: %w", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("syncing pending results failed: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("closing temp file failed: %w", err)
+	}
+
+	err = os.Rename(f.Name(), u.opts.PendingPath)
+	if err != nil {
+		return fmt.Errorf("replacing pending results failed: %w", err)
+	}
+
+	// The rename is only durable once the directory is synced too.
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("opening pending results directory failed: %w", err)
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing pending results directory failed: %w", err)
+	}
+
+	return nil
+}
+
+// addPending queues the results of a job, which are saved before they're
+// sent, so they aren't lost if the updater stops while the server is
+// retried. Until the server is reachable, they're kept, and sent in the
+// next work cycles, instead of the server assigning the job again.
+func (u *Updater) addPending(r workapi.WorkResponse) {
+	u.pending = append(u.pending, r)
+
+	err := u.savePending()
+	if err != nil {
+		u.log.Error("saving pending results failed", "err", err)
+	}
+}
+
+// submitPending sends the pending results, oldest first. It stops at the
+// first failure, and keeps the rest for the next cycle. Only results which
+// the server refused for good are dropped, since sending them again can't
+// succeed.
+func (u *Updater) submitPending(ctx context.Context) error {
+	if len(u.pending) == 0 {
+		return nil
+	}
+
+	var err error
+
+	sent := 0
+
+	for _, r := range u.pending {
+		err = u.workClient.RespondWork(ctx, r)
+		if errors.Is(err, workapi.ErrRejected) {
+			u.log.Error("server rejected results, dropping them", "err", err)
+
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+
+		sent += 1
+	}
+
+	held := u.pendingHeld
+
+	u.pending = u.pending[sent:]
+	u.pendingHeld = len(u.pending) != 0
+
+	if err != nil {
+		u.log.Warn("keeping results until the server is reachable", "pending", len(u.pending), "err", err)
+	}
+
+	if sent > 0 {
+		// Only the results which were held back are worth a mention.
+		if held {
+			u.log.Info("sent pending results", "sent", sent, "pending", len(u.pending))
+		}
+
+		saveErr := u.savePending()
+		if saveErr != nil {
+			u.log.Error("saving pending results failed", "err", saveErr)
+		}
+	}
+
+	return err
+}
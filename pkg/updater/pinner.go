@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/angaz/ipfspodcasting/pkg/cluster"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+)
+
+// Pinner pins and unpins content. Either directly on the Kubo node, or
+// through an ipfs-cluster, which pins it on its peers.
+type Pinner interface {
+	// Pin recursively pins hash. name is optional, and labels the pin, in
+	// the form show/episode.
+	Pin(ctx context.Context, hash string, name string) error
+	Unpin(ctx context.Context, hash string) error
+}
+
+type kuboPinner struct {
+	kubo *kubo.Client
+}
+
+// NewKuboPinner returns a Pinner which pins on the Kubo node.
+func NewKuboPinner(k *kubo.Client) Pinner {
+	return kuboPinner{kubo: k}
+}
+
+func (p kuboPinner) Pin(ctx context.Context, hash string, name string) error {
+	return p.kubo.PinAdd(ctx, hash, name)
+}
+
+func (p kuboPinner) Unpin(ctx context.Context, hash string) error {
+	return p.kubo.PinRm(ctx, hash)
+}
+
+type clusterPinner struct {
+	cluster *cluster.Client
+	kubo    *kubo.Client
+}
+
+// NewClusterPinner returns a Pinner which pins through the cluster.
+//
+// Downloads are still added through the Kubo node, which should be one of the
+// cluster's peers, so the content is available to the cluster. Unpins are
+// also sent to the Kubo node, because the local pin created by add is not
+// managed by the cluster.
+func NewClusterPinner(c *cluster.Client, k *kubo.Client) Pinner {
+	return clusterPinner{
+		cluster: c,
+		kubo:    k,
+	}
+}
+
+func (p clusterPinner) Pin(ctx context.Context, hash string, name string) error {
+	return p.cluster.Pin(ctx, hash, name)
+}
+
+func (p clusterPinner) Unpin(ctx context.Context, hash string) error {
+	err := p.cluster.Unpin(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	err = p.kubo.PinRm(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("kubo unpin failed: %w", err)
+	}
+
+	return nil
+}
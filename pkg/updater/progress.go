@@ -0,0 +1,137 @@
+package updater
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// progressInterval is the time between the progress logs of a
+	// download.
+	progressInterval = 30 * time.Second
+	// progressEventInterval is the time between the DownloadProgress
+	// events, and the updates of the gauge, which are cheap enough to be
+	// live.
+	progressEventInterval = 2 * time.Second
+)
+
+// downloadProgress counts the bytes of a download as they're added to Kubo,
+// and reports them every progressInterval, so large episodes, which can
+// take hours, aren't silent until they finish.
+type downloadProgress struct {
+	read atomic.Int64
+
+	// total is the Content-Length of the download, -1 if it's unknown.
+	total int64
+	start time.Time
+
+	progressGauge prometheus.Gauge
+	expectedGauge prometheus.Gauge
+
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// Write counts the bytes, it's used with an io.TeeReader.
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.read.Add(int64(len(b)))
+
+	return len(b), nil
+}
+
+// startProgress reports the progress of download until stop is called, or
+// ctx is done. total is the Content-Length, or -1.
+func (u *Updater) startProgress(ctx context.Context, download string, total int64) *downloadProgress {
+	p := &downloadProgress{
+		total:         total,
+		start:         time.Now(),
+		progressGauge: metrics.DownloadProgress.WithLabelValues(u.opts.Name),
+		expectedGauge: metrics.DownloadExpected.WithLabelValues(u.opts.Name),
+		done:          make(chan struct{}),
+	}
+
+	p.progressGauge.Set(0)
+	p.expectedGauge.Set(float64(max(total, 0)))
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(progressEventInterval)
+		defer ticker.Stop()
+
+		lastLog := p.start
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.done:
+				return
+			case now := <-ticker.C:
+				read := p.read.Load()
+				elapsed := now.Sub(p.start)
+
+				p.progressGauge.Set(float64(read))
+				u.emit(DownloadProgress{
+					Node:     u.opts.Name,
+					Download: download,
+					Read:     read,
+					Total:    p.total,
+					Elapsed:  elapsed,
+				})
+
+				if now.Sub(lastLog) >= progressInterval {
+					lastLog = now
+
+					u.logProgress(download, read, p.total, elapsed)
+				}
+			}
+		}
+	}()
+
+	return p
+}
+
+// stop stops the reports, and resets the gauges. It's safe to call more
+// than once.
+func (p *downloadProgress) stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+
+		p.progressGauge.Set(0)
+		p.expectedGauge.Set(0)
+	})
+}
+
+// logProgress logs the bytes read so far, and the average rate. The percent,
+// and the estimated time left, are only logged when the total is known.
+func (u *Updater) logProgress(download string, read int64, total int64, elapsed time.Duration) {
+	rate := float64(read) / elapsed.Seconds()
+
+	args := []any{
+		"download", download,
+		"bytes", read,
+		"elapsed", elapsed.Round(time.Second),
+		"bytes_per_second", int64(rate),
+	}
+
+	if total > 0 {
+		args = append(args, "total", total, "percent", 100*read/total)
+
+		if rate > 0 && read < total {
+			eta := time.Duration(float64(total-read) / rate * float64(time.Second))
+
+			args = append(args, "eta", eta.Round(time.Second))
+		}
+	}
+
+	u.log.Info("download progress", args...)
+}
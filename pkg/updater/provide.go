@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// announce provides a pinned episode to the DHT, when enabled, and then
+// checks that it's retrievable. Providing runs in the background, so the
+// result of the job isn't held up by the DHT.
+func (u *Updater) announce(ctx context.Context, hash string, file string) {
+	if !u.opts.Provide {
+		u.checkRetrievable(ctx, hash)
+
+		return
+	}
+
+	go func() {
+		u.provide(ctx, hash, file)
+		u.checkRetrievable(ctx, hash)
+	}()
+}
+
+// provide announces the directory, and the file, of an episode to the DHT,
+// and records when it succeeded in the catalog.
+func (u *Updater) provide(ctx context.Context, hash string, file string) {
+	start := time.Now()
+
+	provideCtx, cancel := context.WithTimeout(ctx, u.opts.ProvideTimeout)
+	defer cancel()
+
+	hashes := []string{hash}
+	if file != "" {
+		hashes = append(hashes, file)
+	}
+
+	err := u.kubo.Provide(provideCtx, hashes...)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		u.log.Warn("providing episode to the dht failed", "cid", hash, "err", err)
+
+		metrics.Provides.WithLabelValues(u.opts.Name, "failed").Inc()
+
+		return
+	}
+
+	duration := time.Since(start)
+
+	u.log.Info("provided episode to the dht", "cid", hash, "duration", duration)
+
+	metrics.Provides.WithLabelValues(u.opts.Name, "ok").Inc()
+	metrics.ProvideDuration.WithLabelValues(u.opts.Name).Observe(duration.Seconds())
+
+	err = u.opts.Catalog.SetProvided(hash, start)
+	if err != nil {
+		u.log.Error("saving provide in catalog failed", "cid", hash, "err", err)
+	}
+}
@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// downloadTransport is Options.DownloadTransport, or the default transport
+// with the DownloadProxy.
+func downloadTransport(opts Options) (http.RoundTripper, error) {
+	if opts.DownloadTransport != nil {
+		if opts.DownloadProxy != "" {
+			return nil, errors.New("download proxy can't be used with a download transport")
+		}
+
+		return opts.DownloadTransport, nil
+	}
+
+	transport, err := newTransport(opts.DownloadProxy)
+	if err != nil {
+		return nil, fmt.Errorf("download proxy: %w", err)
+	}
+
+	return transport, nil
+}
+
+// serverTransport is Options.ServerTransport, or the default transport with
+// the ServerProxy, and the client certificate.
+func serverTransport(opts Options, log *slog.Logger) (http.RoundTripper, error) {
+	hasClientCert := opts.ClientCert != "" || opts.ClientKey != ""
+
+	if opts.ServerTransport != nil {
+		if opts.ServerProxy != "" || hasClientCert {
+			return nil, errors.New("server proxy and client certificate can't be used with a server transport")
+		}
+
+		return opts.ServerTransport, nil
+	}
+
+	transport, err := newTransport(opts.ServerProxy)
+	if err != nil {
+		return nil, fmt.Errorf("server proxy: %w", err)
+	}
+
+	if hasClientCert {
+		reloader, err := newCertReloader(opts.ClientCert, opts.ClientKey, log)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			GetClientCertificate: reloader.GetClientCertificate,
+		}
+	}
+
+	return transport, nil
+}
+
+// newTransport is a copy of the default transport, which uses the proxy
+// at proxyURL. Supported schemes are http, https, and socks5. An empty
+// proxyURL uses the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+// variables, like the default transport.
+func newTransport(proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL == "" {
+		return transport, nil
+	}
+
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy url failed: %w", err)
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", proxy.Scheme)
+	}
+
+	transport.Proxy = http.ProxyURL(proxy)
+
+	return transport, nil
+}
@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+const (
+	// minPushBackoff is the wait before reconnecting to the events, which
+	// doubles with each failed attempt, up to maxPushBackoff.
+	minPushBackoff = 5 * time.Second
+	maxPushBackoff = 5 * time.Minute
+	// unsupportedPushBackoff is the wait before trying again when the
+	// server doesn't have an event stream, in case it gets one.
+	unsupportedPushBackoff = time.Hour
+)
+
+// runPush keeps a connection to the event stream of the work server, and
+// starts a work cycle as soon as it announces work. While it's
+// disconnected, the work loop polls at the update frequency.
+func (u *Updater) runPush(ctx context.Context) {
+	backoff := minPushBackoff
+
+	for {
+		connected, err := u.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			backoff = minPushBackoff
+
+			// Poll right away, then at the update frequency, until
+			// it's connected again.
+			u.TriggerCycle()
+		}
+
+		wait := backoff
+
+		if errors.Is(err, workapi.ErrEventsNotSupported) {
+			wait = unsupportedPushBackoff
+
+			u.log.Info("work server doesn't support events, polling", "err", err, "retry_in", wait)
+		} else {
+			u.log.Warn("work server events disconnected, polling until reconnected", "err", err, "retry_in", wait)
+
+			backoff = min(backoff*2, maxPushBackoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// subscribe reads the event stream until it ends. It returns if it was
+// connected.
+func (u *Updater) subscribe(ctx context.Context) (bool, error) {
+	stream, err := u.workClient.Subscribe(ctx, u.accounts.emails...)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	u.log.Info("connected to the work server events")
+
+	u.pushConnected.Store(true)
+	metrics.PushConnected.WithLabelValues(u.opts.Name).Set(1)
+
+	defer func() {
+		u.pushConnected.Store(false)
+		metrics.PushConnected.WithLabelValues(u.opts.Name).Set(0)
+	}()
+
+	// Work may have been assigned while it was disconnected.
+	u.TriggerCycle()
+
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			return true, err
+		}
+
+		if event.Type == workapi.EventWork {
+			u.log.Debug("work server announced work", "id", event.ID, "account", event.Data)
+
+			u.accounts.announce(event.Data)
+			u.TriggerCycle()
+		}
+	}
+}
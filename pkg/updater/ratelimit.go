@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// serverLimiter counts the time the node waited for Options.ServerLimiter,
+// which may be shared with other nodes.
+type serverLimiter struct {
+	node    string
+	log     *slog.Logger
+	limiter workapi.Limiter
+}
+
+func (l *serverLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+
+	err := l.limiter.Wait(ctx)
+
+	waited := time.Since(start)
+
+	metrics.ServerRateLimitWait.WithLabelValues(l.node).Add(waited.Seconds())
+
+	if waited >= time.Second {
+		l.log.Debug("waited for the work server rate limit", "waited", waited)
+	}
+
+	return err
+}
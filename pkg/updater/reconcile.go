@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+)
+
+// reconcile brings the catalog in line with the pins on the Kubo node.
+// Episodes which are no longer pinned are removed from the catalog, and
+// named pins, in the form show/episode, which are missing from the catalog
+// are added to it.
+func (u *Updater) reconcile(ctx context.Context) error {
+	start := time.Now()
+
+	pins, err := u.kubo.PinLs(ctx)
+	if err != nil {
+		return fmt.Errorf("pin ls failed: %w", err)
+	}
+
+	removed := 0
+
+	for _, episode := range u.opts.Catalog.Episodes() {
+		_, ok := pins[episode.Hash]
+		if ok {
+			continue
+		}
+
+		u.log.Info("episode is no longer pinned, removing from catalog", "cid", episode.Hash)
+
+		err := u.removeFromCatalog(ctx, episode.Hash)
+		if err != nil {
+			return fmt.Errorf("removing from catalog failed: %w", err)
+		}
+
+		removed += 1
+	}
+
+	added := 0
+
+	for hash, name := range pins {
+		show, episode, ok := strings.Cut(name, "/")
+		if !ok {
+			continue
+		}
+
+		_, ok = u.opts.Catalog.Get(hash)
+		if ok {
+			continue
+		}
+
+		lsResp, err := u.kubo.Ls(ctx, hash)
+		if err != nil {
+			u.log.Warn("ls of pinned episode failed", "cid", hash, "err", err)
+
+			continue
+		}
+
+		if len(lsResp.Objects) != 1 || len(lsResp.Objects[0].Links) != 1 {
+			continue
+		}
+
+		link := lsResp.Objects[0].Links[0]
+
+		err = u.opts.Catalog.Add(catalog.Episode{
+			Hash:    hash,
+			File:    link.Hash,
+			Size:    link.Size,
+			Show:    show,
+			Episode: episode,
+		})
+		if err != nil {
+			return fmt.Errorf("adding to catalog failed: %w", err)
+		}
+
+		added += 1
+	}
+
+	u.lastReconcile = time.Now()
+	u.observeCatalog()
+
+	u.log.Info(
+		"reconciliation finished",
+		"added", added,
+		"removed", removed,
+		"duration", time.Since(start),
+	)
+
+	return nil
+}
+
+// maybeReconcile reconciles the catalog if it hasn't been done within the
+// reconcile interval.
+func (u *Updater) maybeReconcile(ctx context.Context) {
+	if u.opts.ReconcileInterval < 0 || time.Since(u.lastReconcile) < u.opts.ReconcileInterval {
+		return
+	}
+
+	err := u.reconcile(ctx)
+	if err != nil {
+		u.log.Error("reconciliation failed", "err", err)
+	}
+}
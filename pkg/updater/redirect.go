@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// checkRedirect is the http.Client.CheckRedirect which follows up to
+// maxRedirects redirects.
+func checkRedirect(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	maxRedirects = max(maxRedirects, 0)
+
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		return nil
+	}
+}
+
+// redirectChain is the URLs of the requests which lead to resp, starting
+// with the original request.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+
+	for req := resp.Request; req != nil; {
+		chain = append([]string{req.URL.String()}, chain...)
+
+		if req.Response == nil {
+			break
+		}
+
+		req = req.Response.Request
+	}
+
+	return chain
+}
+
+// observeDownloadResponse logs the redirect chain of the download, and
+// counts the download by the host it ended up at. resp is nil when err is
+// set.
+func (u *Updater) observeDownloadResponse(download string, resp *http.Response, err error) {
+	host := ""
+	status := "error"
+
+	if resp != nil {
+		chain := redirectChain(resp)
+
+		host = resp.Request.URL.Host
+		status = strconv.Itoa(resp.StatusCode)
+
+		u.log.Debug("download redirects", "download", download, "redirects", len(chain)-1, "chain", chain)
+		metrics.DownloadRedirects.WithLabelValues(u.opts.Name).Observe(float64(len(chain) - 1))
+	} else {
+		// The error has the URL of the last request, which is the host
+		// which failed.
+		var urlErr *url.Error
+
+		if errors.As(err, &urlErr) {
+			parsed, parseErr := url.Parse(urlErr.URL)
+			if parseErr == nil {
+				host = parsed.Host
+			}
+		}
+	}
+
+	metrics.Downloads.WithLabelValues(u.opts.Name, host, status).Inc()
+}
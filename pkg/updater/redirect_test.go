@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMaxRedirects(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRedirects int
+		// followed is the number of redirects which are followed.
+		followed int
+	}{
+		{name: "default", maxRedirects: 0, followed: 10},
+		{name: "limit", maxRedirects: 3, followed: 3},
+		{name: "none", maxRedirects: -1, followed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{MaxRedirects: tt.maxRedirects}
+			opts.setDefaults()
+
+			check := checkRedirect(opts.MaxRedirects)
+			req := &http.Request{}
+
+			// via has the requests before req, so the nth redirect has
+			// n requests before it.
+			for n := 1; n <= tt.followed; n++ {
+				err := check(req, make([]*http.Request, n))
+				if err != nil {
+					t.Fatalf("redirect %d wasn't followed: %v", n, err)
+				}
+			}
+
+			err := check(req, make([]*http.Request, tt.followed+1))
+			if err == nil {
+				t.Errorf("redirect %d was followed, want at most %d", tt.followed+1, tt.followed)
+			}
+		})
+	}
+}
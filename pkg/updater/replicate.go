@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/web3storage"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// replicateEpisodesPerCycle is the number of episodes uploaded to the
+// pinning service per work cycle, so the uploads don't hold up the work from
+// the server.
+const replicateEpisodesPerCycle = 3
+
+// replicate uploads the episodes which weren't replicated yet, newest first,
+// up to replicateEpisodesPerCycle.
+func (u *Updater) replicate(ctx context.Context) {
+	episodes := u.opts.Catalog.Episodes()
+	slices.Reverse(episodes)
+
+	budget := replicateEpisodesPerCycle
+
+	for _, episode := range episodes {
+		if budget == 0 {
+			return
+		}
+
+		// The CAR is a little larger than the file, with the blocks'
+		// headers, and the wrapping directory.
+		if episode.Replicated != nil || episode.Size >= web3storage.MaxCARSize {
+			continue
+		}
+
+		budget -= 1
+
+		err := u.replicateEpisode(ctx, episode)
+		if err != nil {
+			metrics.Replications.WithLabelValues(u.opts.Name, "failed").Inc()
+			u.log.Error("replicating episode failed", "cid", episode.Hash, "err", err)
+
+			continue
+		}
+
+		metrics.Replications.WithLabelValues(u.opts.Name, "ok").Inc()
+	}
+}
+
+// replicateEpisode uploads the DAG of the episode to the pinning service, and
+// marks it as replicated in the catalog.
+func (u *Updater) replicateEpisode(ctx context.Context, episode catalog.Episode) error {
+	car, err := u.kubo.DagExport(ctx, episode.Hash)
+	if err != nil {
+		return fmt.Errorf("exporting car failed: %w", err)
+	}
+	defer car.Close()
+
+	name := pinName(&workapi.Work{Show: episode.Show, Episode: episode.Episode})
+
+	root, err := u.opts.Replicator.UploadCAR(ctx, car, name)
+	if err != nil {
+		return fmt.Errorf("uploading car failed: %w", err)
+	}
+
+	if root != episode.Hash {
+		return fmt.Errorf("uploaded root %s doesn't match", root)
+	}
+
+	u.log.Info("replicated episode", "cid", episode.Hash, "show", episode.Show, "episode", episode.Episode)
+
+	return u.opts.Catalog.SetReplicated(episode.Hash, time.Now())
+}
+
+// maybeReplicate replicates the episodes, if replication is enabled.
+func (u *Updater) maybeReplicate(ctx context.Context) {
+	if u.opts.Replicator == nil {
+		return
+	}
+
+	u.replicate(ctx)
+}
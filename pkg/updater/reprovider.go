@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// reproviderStats is the state of the checks of Kubo's reprovider.
+type reproviderStats struct {
+	lastCheck time.Time
+	// behind is if the last reprovide took longer than the interval at the
+	// last check, so the warning is only logged when it changes.
+	behind bool
+}
+
+// maybeCheckReprovider checks the reprovider, if it wasn't done within the
+// check interval.
+func (u *Updater) maybeCheckReprovider(ctx context.Context) {
+	if u.opts.ReproviderCheckInterval == 0 || time.Since(u.reprovider.lastCheck) < u.opts.ReproviderCheckInterval {
+		return
+	}
+
+	err := u.checkReprovider(ctx)
+	if err != nil {
+		u.log.Warn("checking reprovider failed", "err", err)
+	}
+}
+
+// checkReprovider updates the reprovider metrics, and warns when the last
+// reprovide took longer than the reprovide interval. The DHT records expire,
+// so when Kubo can't announce all of its content within the interval, some
+// of the pinned episodes can't be found by other nodes until their turn
+// comes around again.
+func (u *Updater) checkReprovider(ctx context.Context) error {
+	stats, err := u.kubo.ProvideStat(ctx)
+	if err != nil {
+		return err
+	}
+
+	interval, err := u.kubo.ReprovideInterval(ctx)
+	if err != nil {
+		return err
+	}
+
+	u.reprovider.lastCheck = time.Now()
+
+	metrics.ReprovideBatchSize.WithLabelValues(u.opts.Name).Set(float64(stats.LastReprovideBatchSize))
+	metrics.ReprovideDuration.WithLabelValues(u.opts.Name).Set(stats.LastReprovideDuration.Seconds())
+	metrics.ProvideAvgDuration.WithLabelValues(u.opts.Name).Set(stats.AvgProvideDuration.Seconds())
+	metrics.ReprovideInterval.WithLabelValues(u.opts.Name).Set(interval.Seconds())
+
+	// Reproviding is disabled when the interval is zero, so there's
+	// nothing to keep up with.
+	behind := interval != 0 && stats.LastReprovideDuration > interval
+
+	if behind {
+		metrics.ReproviderBehind.WithLabelValues(u.opts.Name).Set(1)
+	} else {
+		metrics.ReproviderBehind.WithLabelValues(u.opts.Name).Set(0)
+	}
+
+	switch {
+	case behind && !u.reprovider.behind:
+		u.log.Warn(
+			"reprovider can't keep up, some pinned content isn't announced to the DHT",
+			"last_reprovide_duration", stats.LastReprovideDuration,
+			"reprovide_interval", interval,
+			"batch_size", stats.LastReprovideBatchSize,
+			"avg_provide_duration", stats.AvgProvideDuration,
+		)
+	case !behind && u.reprovider.behind:
+		u.log.Info(
+			"reprovider caught up",
+			"last_reprovide_duration", stats.LastReprovideDuration,
+			"reprovide_interval", interval,
+		)
+	}
+
+	u.reprovider.behind = behind
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// checkRetrievable checks, in the background, that hash can be fetched
+// through the retrieval gateway. Only one check runs at a time, and the
+// episodes pinned while one is running aren't checked, since a single
+// failure already shows the node can't be reached.
+func (u *Updater) checkRetrievable(ctx context.Context, hash string) {
+	if u.opts.RetrievalGateway == "" || !u.retrievalChecking.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer u.retrievalChecking.Store(false)
+
+		start := time.Now()
+
+		err := u.fetchFromGateway(ctx, hash)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			u.log.Warn(
+				"pinned episode isn't retrievable through the gateway, check that other peers can connect to the node",
+				"cid", hash,
+				"gateway", u.opts.RetrievalGateway,
+				"err", err,
+			)
+
+			metrics.RetrievalChecks.WithLabelValues(u.opts.Name, "failed").Inc()
+			metrics.Retrievable.WithLabelValues(u.opts.Name).Set(0)
+
+			return
+		}
+
+		u.log.Debug("pinned episode is retrievable through the gateway", "cid", hash, "duration", time.Since(start))
+
+		metrics.RetrievalChecks.WithLabelValues(u.opts.Name, "ok").Inc()
+		metrics.Retrievable.WithLabelValues(u.opts.Name).Set(1)
+	}()
+}
+
+// fetchFromGateway sends a HEAD request for hash to the retrieval gateway,
+// which has to fetch at least the root of the episode from the network to
+// respond with 200 OK.
+func (u *Updater) fetchFromGateway(ctx context.Context, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, u.opts.RetrievalTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.opts.RetrievalGateway+"/ipfs/"+hash+"/", nil)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+
+	resp, err := u.downloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
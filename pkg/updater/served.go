@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+)
+
+// servedStats are the bytes sent to peers over bitswap, from samples of the
+// bitswap ledgers.
+type servedStats struct {
+	// sent is the bytes sent to each peer in its ledger, at the last
+	// sample.
+	sent       map[string]uint64
+	total      atomic.Uint64
+	lastSample time.Time
+}
+
+// maybeSampleServed samples the ledgers, if it wasn't done within the sample
+// interval.
+func (u *Updater) maybeSampleServed(ctx context.Context) {
+	if u.opts.ServedSampleInterval == 0 || time.Since(u.served.lastSample) < u.opts.ServedSampleInterval {
+		return
+	}
+
+	err := u.sampleServed(ctx)
+	if err != nil {
+		u.log.Warn("sampling bitswap ledgers failed", "err", err)
+	}
+}
+
+// sampleServed adds the bytes sent to each connected peer since the last
+// sample to the total. The ledgers are per peer, and Kubo forgets them, so a
+// ledger which went backwards started over, and all of it is new. The bytes
+// sent to a peer which disconnected after the last sample, but before this
+// one, are missed, so the total is an estimate.
+func (u *Updater) sampleServed(ctx context.Context) error {
+	peers, err := u.kubo.PeerIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	u.served.lastSample = time.Now()
+
+	first := u.served.sent == nil
+	sent := make(map[string]uint64, len(peers))
+
+	var (
+		served      uint64
+		servedPeers int
+	)
+
+	for _, peer := range peers {
+		// Peers with several connections are listed once for each.
+		_, ok := sent[peer]
+		if ok {
+			continue
+		}
+
+		ledger, err := u.kubo.BitswapLedger(ctx, peer)
+		if err != nil {
+			u.log.Debug("bitswap ledger failed", "peer", peer, "err", err)
+
+			continue
+		}
+
+		sent[peer] = ledger.Sent
+
+		// The ledgers at the first sample include what was sent
+		// before the updater started.
+		if first {
+			continue
+		}
+
+		previous := u.served.sent[peer]
+		if ledger.Sent < previous {
+			previous = 0
+		}
+
+		if ledger.Sent > previous {
+			served += ledger.Sent - previous
+			servedPeers += 1
+		}
+	}
+
+	u.served.sent = sent
+	u.served.total.Add(served)
+
+	metrics.BytesServed.WithLabelValues(u.opts.Name).Add(float64(served))
+	metrics.PeersServed.WithLabelValues(u.opts.Name).Set(float64(servedPeers))
+
+	u.log.Debug("sampled bitswap ledgers", "peers", len(peers), "served", served, "served_peers", servedPeers)
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package updater
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the work cycles. It uses the global tracer
+// provider, so spans are dropped unless the application sets one up.
+var tracer = otel.Tracer("github.com/angaz/ipfspodcasting/pkg/updater")
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
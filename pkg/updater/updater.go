@@ -0,0 +1,1240 @@
+package updater
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/filecoin"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/notify"
+	"github.com/angaz/ipfspodcasting/pkg/web3storage"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures an Updater. Zero durations are replaced with the
+// defaults documented on each field.
+type Options struct {
+	// Email address of the IPFS Podcasting account. Required.
+	Email string
+	// Emails are more accounts the node works for, like a personal account
+	// and one for a show. The work requests take turns between Email and
+	// Emails, and the result of each job is reported with the email which
+	// requested it.
+	Emails []string
+
+	// Name identifies the node in metrics and logs, when running more than
+	// one Updater in a process. Defaults to "default".
+	Name string
+	// Logger is used for the logs of the Updater, with the name of the
+	// node as the node attribute. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	// ServerURL of the work server. Defaults to workapi.DefaultBaseURL.
+	ServerURL string
+	// ClientCert and ClientKey are the files of a certificate for mutual
+	// TLS with the work server. They are loaded again when they change.
+	// Empty doesn't send a certificate.
+	ClientCert string
+	ClientKey  string
+
+	// MaxRedirects is the number of redirects an episode download may
+	// follow, which often goes through a few trackers. Zero uses the
+	// default of 10, and negative doesn't follow any.
+	MaxRedirects int
+
+	// DownloadProxy and ServerProxy are the URLs of the proxies for the
+	// episode downloads and the work server. The schemes http, https, and
+	// socks5 are supported. Empty uses the HTTP_PROXY, HTTPS_PROXY, and
+	// NO_PROXY environment variables.
+	DownloadProxy string
+	ServerProxy   string
+	// DownloadTransport and ServerTransport replace the transports of the
+	// episode downloads and feeds, and of the work server, like to add
+	// middleware for auth, tracing, or caching. They can't be combined
+	// with the proxies, or the client certificate, which configure the
+	// default transports. Nil uses a copy of http.DefaultTransport.
+	DownloadTransport http.RoundTripper
+	ServerTransport   http.RoundTripper
+
+	// UserAgent sent to the work server, which identifies the build of
+	// the client. Defaults to Go's User-Agent.
+	UserAgent string
+
+	// NodeSecret is shared with the work server, and signs the requests
+	// with HMAC-SHA256, so others can't send results in the name of this
+	// node. Empty sends them unsigned.
+	NodeSecret string
+	// Protocol selects the encoding of the requests to the work server.
+	// Defaults to workapi.ProtocolAuto, which switches to the v2 JSON
+	// protocol if the server supports it.
+	Protocol workapi.Protocol
+	// WorkRecorder records the requests to the work server, and their
+	// responses, to reproduce protocol bugs. Nil doesn't record them.
+	WorkRecorder *workapi.Recorder
+	// Jobs are the types of jobs the node runs, of "download", "pin", and
+	// "delete", like only pins and deletes on a metered connection, where
+	// the downloads would be costly. The accepted jobs sent to the server
+	// only include them, and other jobs are declined with ErrJobDisabled.
+	// Defaults to all of them.
+	Jobs []string
+	// Tags are sent with every work request, like {"region": "eu"}, so the
+	// server can assign work by location or size. See workapi.CheckTags.
+	Tags map[string]string
+	// Push keeps a connection to the event stream of the work server, and
+	// checks for work as soon as it announces some. While it's connected,
+	// the checks wait for MaxUpdateFrequency, and when it drops, they fall
+	// back to the update frequency.
+	Push bool
+	// ServerLimiter limits the rate of the requests to the work server,
+	// like a rate.Limiter. Sharing one between the Updaters of several
+	// nodes limits their combined rate, so a fleet of nodes in one process
+	// stays within a budget. Nil doesn't limit the requests.
+	ServerLimiter workapi.Limiter
+	// Peering adds the backbone peers listed by the work server to Kubo's
+	// peering, which keeps connections to them, so content is found
+	// between the nodes without going through the DHT.
+	Peering bool
+	// PeeringInterval is how often the backbone peers are fetched again.
+	// Defaults to 1 hour.
+	PeeringInterval time.Duration
+
+	// Pinner pins and unpins content. Defaults to pinning on the Kubo node.
+	Pinner Pinner
+
+	// Catalog tracks the hosted episodes. Defaults to a catalog which is
+	// only kept in memory.
+	Catalog *catalog.Catalog
+	// PendingPath is a file where results which couldn't be sent to the
+	// server are kept, until they are sent in a later work cycle. Empty
+	// only keeps them in memory.
+	PendingPath string
+
+	// KuboRetryMax is the longest wait between the checks if Kubo is
+	// reachable again, after a work cycle failed to reach it. Defaults to 5
+	// minutes.
+	KuboRetryMax time.Duration
+
+	// ServerFailures is how many requests to the work server in a row may
+	// fail before the circuit opens, and work requests are paused for
+	// ServerCooldown. Defaults to 5.
+	ServerFailures int
+	// ServerCooldown is how long work requests are paused once the
+	// circuit opened, before trying again. Defaults to 10 minutes.
+	ServerCooldown time.Duration
+
+	// HTTPTimeout for fetching feeds and communicating with the work
+	// server. Defaults to 10 minutes.
+	HTTPTimeout time.Duration
+	// DownloadTimeout is how long the origin of an episode may take to
+	// respond, or to send more of the file. It resets with every read, so
+	// it doesn't limit the length of the whole download. Defaults to 2
+	// minutes.
+	DownloadTimeout time.Duration
+	// AddTimeout is how long Kubo may take to read more of a download, or
+	// to respond once it has read all of it. It resets with every read.
+	// Defaults to 10 minutes.
+	AddTimeout time.Duration
+	// JobTimeout is how long a download or pin job may take as a whole,
+	// after which it's cancelled, and fails with ErrJobTimeout, so a job
+	// which trickles along doesn't hold up the node for hours. Defaults to
+	// 2 hours.
+	JobTimeout time.Duration
+
+	// UpdateFrequency is the initial time between checks for new work.
+	// Defaults to 10 minutes.
+	UpdateFrequency time.Duration
+	// MinUpdateFrequency is the shortest time between checks, used while
+	// the server keeps returning work. Defaults to UpdateFrequency.
+	MinUpdateFrequency time.Duration
+	// MaxUpdateFrequency is the longest time between checks, used after
+	// long idle streaks. Defaults to UpdateFrequency.
+	MaxUpdateFrequency time.Duration
+	// IdleCycles is the number of cycles in a row without work before the
+	// time between checks starts growing towards MaxUpdateFrequency.
+	// Defaults to 3.
+	IdleCycles int
+	// PollAfterWork requests work again right after a job completed,
+	// instead of waiting for the update frequency, so a backlog of jobs
+	// drains faster. Failed jobs still wait.
+	PollAfterWork bool
+	// MaxJobsPerHour caps the jobs run with PollAfterWork. Once that many
+	// jobs completed in the last hour, the checks wait for the update
+	// frequency again. Zero doesn't cap them.
+	MaxJobsPerHour int
+	// UpdateJitter is the maximum random time added to each wait between
+	// checks, so nodes which started together don't all poll the server
+	// at the same time. Zero disables it.
+	UpdateJitter time.Duration
+	// FollowRetryAfter waits for the time in the server's Retry-After
+	// header, when it sends one, instead of the update frequency.
+	FollowRetryAfter bool
+	// Schedule is a cron spec, like "*/5 8-23 * * *", of the times when
+	// checks are allowed. After waiting for the update frequency, the next
+	// check is at the next scheduled time. Empty allows checks at any time.
+	Schedule string
+
+	// AddOptions are used when adding downloaded episodes, like the CID
+	// version. The reported hashes use the same format.
+	AddOptions kubo.AddOptions
+
+	// BandwidthProfiles limit the speed of downloads during the day, like
+	// full speed overnight, and 2 MB/s during the day. The first profile
+	// with a window containing the current time applies. Without a
+	// profile, downloads are unlimited.
+	BandwidthProfiles []BandwidthProfile
+
+	// MinEpisodeSize in bytes. Smaller episodes fail with a too_small
+	// error. Zero allows episodes of any size.
+	MinEpisodeSize int
+	// MaxEpisodeSize in bytes. Larger episodes fail with a too_large error,
+	// before they are downloaded when the size is known. Zero allows
+	// episodes of any size.
+	MaxEpisodeSize int
+
+	// SniffContent checks that the first bytes of downloads are an audio or
+	// video container, like MP3 or MP4, and fails the others with a
+	// not_media error. Downloads with the content type of a document, like
+	// text/html, always fail.
+	SniffContent bool
+
+	// JobLogs sends the log lines of a failed job with its results, so
+	// the owners of the feed can see why it failed. Only the v2 JSON
+	// protocol carries them.
+	JobLogs bool
+
+	// MinFreeSpace in bytes on the Kubo repo's disk. Download and pin jobs
+	// are declined with a disk_low error while there is less free space.
+	// Zero disables the check.
+	MinFreeSpace int
+
+	// PinTTL is how long downloaded and pinned episodes are hosted, when
+	// the work doesn't have a TTL. They are unpinned when it expires, and
+	// reported as deleted. Zero hosts them until a delete job.
+	PinTTL time.Duration
+
+	// GCAfterDelete runs the repo garbage collector after delete jobs.
+	GCAfterDelete bool
+	// GCThreshold runs the repo garbage collector when the repo uses more
+	// than this fraction of Kubo's StorageMax, e.g. 0.9. Zero disables it.
+	GCThreshold float64
+
+	// MaintenanceWindows in which heavy maintenance tasks, like gc,
+	// reconciliation, and verification, are allowed to run. Without any
+	// windows, they run whenever needed.
+	MaintenanceWindows []Window
+
+	// ReconcileInterval is how often the catalog is reconciled with the
+	// pins on the Kubo node. Defaults to 24 hours. Negative disables it.
+	ReconcileInterval time.Duration
+
+	// VerifyInterval is how often the blocks of the pinned episodes are
+	// verified, and episodes with missing or corrupt blocks are repinned.
+	// Zero disables it.
+	VerifyInterval time.Duration
+
+	// MaxStorage in bytes, which the episodes in the catalog may use. Jobs
+	// which would go over the quota fail with a no_space error. Zero
+	// disables the quota.
+	MaxStorage int
+
+	// EvictHighWater is the fraction of MaxStorage, like 0.95, above which
+	// episodes are unpinned, in the order of EvictPolicy, until they use
+	// less than EvictLowWater. The evicted episodes are reported as
+	// deleted, so the server can assign them to other nodes. Zero disables
+	// eviction.
+	EvictHighWater float64
+	// EvictLowWater is the fraction of MaxStorage eviction stops at.
+	// Defaults to 90% of EvictHighWater.
+	EvictLowWater float64
+	// EvictPolicy is which episodes are evicted first, EvictLRU,
+	// EvictLargest, or EvictOldest. Defaults to EvictLRU.
+	EvictPolicy string
+
+	// BandwidthClass is sent to the server as a hint of the download
+	// speed of the node, one of BandwidthLow, BandwidthMedium, or
+	// BandwidthHigh. Defaults to the class of the current bandwidth
+	// profile's rate, or none if the downloads aren't limited.
+	BandwidthClass string
+
+	// Feeds are the URLs of RSS feeds which are hosted by this node,
+	// without the server assigning them. Every episode is downloaded, and
+	// tracked in the catalog.
+	Feeds []string
+	// FeedInterval is how often the Feeds are checked for new episodes.
+	// Defaults to 1 hour.
+	FeedInterval time.Duration
+
+	// EnclosureDir is a directory where a podcast:alternateEnclosure
+	// snippet is written for each pinned episode, as <cid>.xml. Empty
+	// doesn't write them.
+	EnclosureDir string
+	// EnclosureGateway is the URL of an IPFS gateway, like
+	// https://ipfs.io, which is added as a source of the alternate
+	// enclosures, next to the ipfs:// URI.
+	EnclosureGateway string
+
+	// Provide announces the episodes to the DHT right after they're
+	// pinned, so other peers can find them without waiting for Kubo's next
+	// reprovide, which can take hours.
+	Provide bool
+	// ProvideTimeout is how long the announcement may take. Defaults to 5
+	// minutes.
+	ProvideTimeout time.Duration
+
+	// RetrievalGateway is the URL of a public IPFS gateway, like
+	// https://ipfs.io, through which the hosted episodes are checked to be
+	// retrievable after they're pinned, to catch NAT or firewall problems
+	// which keep other peers from fetching them. Empty disables the check.
+	RetrievalGateway string
+	// RetrievalTimeout is how long the gateway may take to fetch an
+	// episode. Defaults to 2 minutes.
+	RetrievalTimeout time.Duration
+
+	// MFSDir is an MFS directory, like /podcasts, where the pinned episodes
+	// are copied to, as <MFSDir>/<show>/<episode>, so they can be browsed
+	// in the WebUI. Empty doesn't copy them.
+	MFSDir string
+
+	// ManifestKey is the name of the Kubo key, like "self", under which a
+	// manifest of the hosted episodes is published to IPNS. Empty doesn't
+	// publish a manifest.
+	ManifestKey string
+	// ManifestRepublish is how often the manifest is published again, when
+	// it didn't change, so the IPNS record doesn't expire. Defaults to 12
+	// hours.
+	ManifestRepublish time.Duration
+
+	// Mirror uploads the downloaded episodes to an S3 compatible bucket,
+	// while they are added to Kubo. Nil disables mirroring.
+	Mirror *S3Mirror
+
+	// Replicator uploads the hosted episodes to web3.storage, so they stay
+	// available while the node is offline. Nil disables replication.
+	Replicator *web3storage.Client
+
+	// Archiver submits episodes for Filecoin archival, and tracks their
+	// deals in the catalog. Nil disables archival.
+	Archiver *filecoin.Client
+	// ArchiveAfter is how long an episode has to be hosted before it's
+	// archived, so only the long tail goes to cold storage. Defaults to 30
+	// days.
+	ArchiveAfter time.Duration
+	// ArchiveInterval is how often episodes are submitted, and the deals
+	// are checked. Defaults to 6 hours.
+	ArchiveInterval time.Duration
+
+	// ServedSampleInterval is how often the bitswap ledgers of the
+	// connected peers are sampled, to count the bytes the node served.
+	// Zero disables it.
+	ServedSampleInterval time.Duration
+	// ReportServed sends the bytes served since the updater started with
+	// the work requests, as served.
+	ReportServed bool
+	// ReproviderCheckInterval is how often Kubo's reprovider statistics
+	// are checked, to warn when it can't announce the pinned content to
+	// the DHT within the reprovide interval. Zero disables it.
+	ReproviderCheckInterval time.Duration
+
+	// Notifier is sent events, like failed jobs, low disk space, or Kubo
+	// being unreachable. Nil disables notifications.
+	Notifier *notify.Notifier
+	// OnEvent is called with the progress of the work loop, like jobs
+	// starting and finishing, for applications which show the live state
+	// of the node. It holds up the work loop, so it must not block, and
+	// DownloadProgress is sent from another goroutine. Nil disables it.
+	OnEvent func(Event)
+	// NoWorkAlertCycles sends a no_work event after this many work cycles
+	// in a row without any work. Zero disables it.
+	NoWorkAlertCycles int
+	// FailureAlertAfter sends a failing event once every work cycle failed
+	// for this long. Zero disables it.
+	FailureAlertAfter time.Duration
+
+	// MaxShowLabels is the number of distinct shows which get their own
+	// label on the per-show job counter. Jobs of any later shows are
+	// counted as "other", so the number of series is bounded. Zero
+	// disables the per-show counter.
+	MaxShowLabels int
+}
+
+func (o *Options) setDefaults() {
+	if o.Name == "" {
+		o.Name = "default"
+	}
+	if o.KuboRetryMax == 0 {
+		o.KuboRetryMax = 5 * time.Minute
+	}
+	if o.ServerFailures == 0 {
+		o.ServerFailures = 5
+	}
+	if o.ServerCooldown == 0 {
+		o.ServerCooldown = 10 * time.Minute
+	}
+	if o.HTTPTimeout == 0 {
+		o.HTTPTimeout = 10 * time.Minute
+	}
+	if o.DownloadTimeout == 0 {
+		o.DownloadTimeout = 2 * time.Minute
+	}
+	if o.AddTimeout == 0 {
+		o.AddTimeout = 10 * time.Minute
+	}
+	if o.JobTimeout == 0 {
+		o.JobTimeout = 2 * time.Hour
+	}
+	if o.MaxRedirects == 0 {
+		o.MaxRedirects = 10
+	}
+	if o.ManifestRepublish == 0 {
+		o.ManifestRepublish = 12 * time.Hour
+	}
+	if o.ArchiveAfter == 0 {
+		o.ArchiveAfter = 30 * 24 * time.Hour
+	}
+	if o.ArchiveInterval == 0 {
+		o.ArchiveInterval = 6 * time.Hour
+	}
+	if o.FeedInterval == 0 {
+		o.FeedInterval = time.Hour
+	}
+	if o.PeeringInterval == 0 {
+		o.PeeringInterval = time.Hour
+	}
+	if o.ReconcileInterval == 0 {
+		o.ReconcileInterval = 24 * time.Hour
+	}
+	if o.UpdateFrequency == 0 {
+		o.UpdateFrequency = 10 * time.Minute
+	}
+	if o.MinUpdateFrequency == 0 {
+		o.MinUpdateFrequency = o.UpdateFrequency
+	}
+	if o.MaxUpdateFrequency == 0 {
+		o.MaxUpdateFrequency = o.UpdateFrequency
+	}
+	if o.IdleCycles == 0 {
+		o.IdleCycles = 3
+	}
+	if o.Jobs == nil {
+		o.Jobs = jobTypesAll
+	}
+	if o.EvictLowWater == 0 {
+		o.EvictLowWater = o.EvictHighWater * 0.9
+	}
+	if o.ProvideTimeout == 0 {
+		o.ProvideTimeout = 5 * time.Minute
+	}
+	if o.RetrievalTimeout == 0 {
+		o.RetrievalTimeout = 2 * time.Minute
+	}
+	if o.EvictPolicy == "" {
+		o.EvictPolicy = EvictLRU
+	}
+}
+
+// Updater runs the work loop of an IPFS Podcasting node. It requests work
+// from the server, runs the jobs on the Kubo node, and reports the results.
+type Updater struct {
+	opts   Options
+	log    *slog.Logger
+	jobLog *jobLog
+
+	kubo       *kubo.Client
+	pinner     Pinner
+	httpClient *http.Client
+	// downloadClient is httpClient without the timeout, for episode
+	// downloads.
+	downloadClient *http.Client
+	workClient     *workapi.Client
+	interval       *pollInterval
+	schedule       cron.Schedule
+	shows          map[string]struct{}
+	accounts       *accounts
+	retryAfter     time.Duration
+	recentJobs     jobWindow
+
+	kuboDown     bool
+	watchdog     watchdogState
+	breaker      breakerState
+	diskLow      bool
+	noWorkStreak int
+	failingSince time.Time
+	failingSent  bool
+
+	deletedSinceGC bool
+	lastReconcile  time.Time
+	lastVerify     time.Time
+	lastFeedSync   time.Time
+	lastArchive    time.Time
+	served         servedStats
+	reprovider     reproviderStats
+	peering        peeringState
+
+	manifestHash        string
+	lastManifestPublish time.Time
+
+	pending []workapi.WorkResponse
+	// pendingHeld is if some of the pending results couldn't be sent.
+	pendingHeld bool
+
+	history            history
+	wake               chan struct{}
+	reconcileRequested atomic.Bool
+	pushConnected      atomic.Bool
+	retrievalChecking  atomic.Bool
+}
+
+// New creates an Updater for the Kubo node k.
+func New(k *kubo.Client, opts Options) (*Updater, error) {
+	if opts.Email == "" || slices.Contains(opts.Emails, "") {
+		return nil, fmt.Errorf("email is required")
+	}
+
+	opts.setDefaults()
+
+	for _, jobType := range opts.Jobs {
+		if !slices.Contains(jobTypesAll, jobType) {
+			return nil, fmt.Errorf("unknown job type: %q", jobType)
+		}
+	}
+
+	err := workapi.CheckTags(opts.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+
+	err = opts.checkEviction()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MinUpdateFrequency > opts.UpdateFrequency || opts.MaxUpdateFrequency < opts.UpdateFrequency {
+		return nil, fmt.Errorf("update frequency must be between the min and max update frequency")
+	}
+
+	var schedule cron.Schedule
+
+	if opts.Schedule != "" {
+		var err error
+
+		schedule, err = cron.ParseStandard(opts.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("parsing schedule failed: %w", err)
+		}
+	}
+
+	pinner := opts.Pinner
+	if pinner == nil {
+		pinner = NewKuboPinner(k)
+	}
+
+	if opts.Catalog == nil {
+		opts.Catalog, _ = catalog.Open("")
+	}
+
+	log := cmp.Or(opts.Logger, slog.Default()).With("node", opts.Name)
+
+	logs := new(jobLog)
+	if opts.JobLogs {
+		log = slog.New(newJobLogHandler(log.Handler(), logs))
+	}
+
+	downloadTransport, err := downloadTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Timeout:       opts.HTTPTimeout,
+		Transport:     otelhttp.NewTransport(downloadTransport),
+		CheckRedirect: checkRedirect(opts.MaxRedirects),
+	}
+
+	// The episode downloads have deadlines per phase, instead of one
+	// timeout for the whole download, see downloadFile.
+	downloadClient := &http.Client{
+		Transport:     httpClient.Transport,
+		CheckRedirect: httpClient.CheckRedirect,
+	}
+
+	serverTransport, err := serverTransport(opts, log)
+	if err != nil {
+		return nil, err
+	}
+
+	workHTTPClient := &http.Client{
+		Timeout:   opts.HTTPTimeout,
+		Transport: otelhttp.NewTransport(serverTransport),
+	}
+
+	workClient := workapi.NewClient(workHTTPClient, opts.ServerURL)
+	workClient.SetUserAgent(opts.UserAgent)
+	workClient.SetSecret(opts.NodeSecret)
+	workClient.SetLogger(log)
+	workClient.SetRecorder(opts.WorkRecorder)
+
+	if opts.ServerLimiter != nil {
+		workClient.SetLimiter(&serverLimiter{
+			node:    opts.Name,
+			log:     log,
+			limiter: opts.ServerLimiter,
+		})
+	}
+
+	if opts.Protocol != "" {
+		workClient.SetProtocol(opts.Protocol)
+	}
+
+	u := &Updater{
+		opts:           opts,
+		log:            log,
+		jobLog:         logs,
+		kubo:           k,
+		pinner:         pinner,
+		httpClient:     httpClient,
+		downloadClient: downloadClient,
+		workClient:     workClient,
+		schedule:       schedule,
+		shows:          map[string]struct{}{},
+		accounts:       newAccounts(opts.Email, opts.Emails),
+		wake:           make(chan struct{}, 1),
+		interval: newPollInterval(
+			opts.Name,
+			opts.UpdateFrequency,
+			opts.MinUpdateFrequency,
+			opts.MaxUpdateFrequency,
+			opts.IdleCycles,
+		),
+	}
+
+	err = u.loadPending()
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Name identifies the node in metrics and logs.
+func (u *Updater) Name() string {
+	return u.opts.Name
+}
+
+// Catalog tracks the episodes hosted by the node.
+func (u *Updater) Catalog() *catalog.Catalog {
+	return u.opts.Catalog
+}
+
+// Kubo is the client of the Kubo node this Updater runs jobs on.
+func (u *Updater) Kubo() *kubo.Client {
+	return u.kubo
+}
+
+// UpdateFrequency is the current time between checks for new work.
+func (u *Updater) UpdateFrequency() time.Duration {
+	return u.interval.Current()
+}
+
+// Run requests and runs work until ctx is done. It returns an error wrapping
+// kubo.ErrUnsupportedVersion if Kubo is older than kubo.MinVersion, when it
+// starts, or after Kubo was unreachable.
+func (u *Updater) Run(ctx context.Context) error {
+	err := u.detectKuboVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	u.observeCatalog()
+
+	if u.opts.Push {
+		go u.runPush(ctx)
+	}
+
+	for {
+		start := time.Now()
+
+		metrics.LastAttempt.WithLabelValues(u.opts.Name).SetToCurrentTime()
+
+		gotWork, complete, err := u.DoWork(ctx)
+		if errors.Is(err, ErrCircuitOpen) {
+			// It was logged when the circuit opened.
+			u.log.Debug("skipped work request", "err", err)
+		} else if err != nil {
+			u.log.Error("job failed", "err", err)
+		} else {
+			metrics.LastSuccess.WithLabelValues(u.opts.Name).SetToCurrentTime()
+		}
+
+		u.log.Info("job finished", "complete", complete, "duration", time.Since(start))
+
+		u.interval.Update(gotWork)
+		u.notifyNoWork(gotWork)
+		u.notifyFailing(ctx, start, err)
+
+		// The maintenance needs Kubo, so while it's unreachable, the
+		// watchdog waits for it instead, and the next cycle runs as
+		// soon as it's back.
+		kuboDown := u.kuboDown
+
+		if !kuboDown {
+			u.runMaintenance(ctx)
+			u.expirePins(ctx)
+			u.evictEpisodes(ctx)
+			u.maybeSyncFeeds(ctx)
+			u.maybePublishManifest(ctx)
+			u.maybeReplicate(ctx)
+			u.maybeArchive(ctx)
+			u.maybeSampleServed(ctx)
+			u.maybeCheckReprovider(ctx)
+			u.maybeSyncPeering(ctx)
+		}
+
+		next := u.nextCheck(start, gotWork && complete)
+
+		u.emit(CycleCompleted{
+			Node:      u.opts.Name,
+			Time:      start,
+			Duration:  time.Since(start),
+			GotWork:   gotWork,
+			Err:       err,
+			NextCheck: next,
+		})
+
+		if kuboDown {
+			err := u.reconnectKubo(ctx)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(next)):
+		case <-u.wake:
+			u.log.Info("work cycle triggered")
+		}
+	}
+}
+
+// nextCheck is the time of the check after the one which started at start.
+// It's after the current update frequency, or right away after a completed
+// job with PollAfterWork, or MaxUpdateFrequency while the events are
+// connected, or the server's Retry-After, at the next scheduled time, plus
+// the jitter.
+func (u *Updater) nextCheck(start time.Time, completed bool) time.Time {
+	wait := u.interval.Current()
+
+	if completed {
+		u.recentJobs.add(start)
+	}
+
+	if u.opts.PollAfterWork && completed {
+		jobs := u.recentJobs.count(start)
+
+		if u.opts.MaxJobsPerHour == 0 || jobs < u.opts.MaxJobsPerHour {
+			wait = 0
+		} else {
+			u.log.Debug("jobs per hour cap reached, waiting for the update frequency", "jobs", jobs)
+		}
+	}
+
+	// The events announce new work, so polling is only a fallback.
+	if u.pushConnected.Load() && wait != 0 {
+		wait = max(wait, u.opts.MaxUpdateFrequency)
+	}
+
+	if u.opts.FollowRetryAfter && u.retryAfter > 0 {
+		wait = u.retryAfter
+	}
+
+	next := start.Add(wait)
+
+	if u.schedule != nil {
+		next = u.schedule.Next(next)
+	}
+
+	if u.opts.UpdateJitter > 0 {
+		next = next.Add(rand.N(u.opts.UpdateJitter))
+	}
+
+	return next
+}
+
+func (u *Updater) getKuboStats(ctx context.Context, workResponse *workapi.WorkResponse) (*kubo.DiagSysResponse, error) {
+	nID, err := u.kubo.ID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting node id failed: %w", err)
+	}
+
+	workResponse.IPFSID = nID.ID
+	u.checkNodeID(nID.ID)
+
+	sys, err := u.kubo.DiagSys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting diag/sys failed: %w", err)
+	}
+
+	workResponse.IPFSVersion = sys.IPFSVersion
+	workResponse.Online = sys.Net.Online
+
+	peers, err := u.kubo.Peers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching peers failed: %w", err)
+	}
+
+	workResponse.Peers = peers
+
+	return sys, nil
+}
+
+// checkDiskSpace returns ErrDiskLow if the free space on the Kubo repo's disk
+// is below the minimum.
+func (u *Updater) checkDiskSpace(sys *kubo.DiagSysResponse) error {
+	freeSpace := sys.DiskInfo.FreeSpace
+	isLow := u.opts.MinFreeSpace != 0 && freeSpace < int64(u.opts.MinFreeSpace)
+
+	metrics.DiskFreeBytes.WithLabelValues(u.opts.Name).Set(float64(freeSpace))
+
+	if !isLow {
+		metrics.DiskLow.WithLabelValues(u.opts.Name).Set(0)
+
+		return nil
+	}
+
+	metrics.DiskLow.WithLabelValues(u.opts.Name).Set(1)
+
+	return fmt.Errorf(
+		"%w: %d bytes free, minimum is %d",
+		ErrDiskLow,
+		freeSpace,
+		u.opts.MinFreeSpace,
+	)
+}
+
+// DoWork runs a single work cycle.
+//
+// first return value is if the server returned any work. The second is if the
+// operation was complete, or false if it exited early for any reason
+func (u *Updater) DoWork(ctx context.Context) (bool, bool, error) {
+	ctx, span := tracer.Start(ctx, "DoWork", trace.WithAttributes(
+		attribute.String("node", u.opts.Name),
+	))
+
+	gotWork, complete, err := u.doWork(ctx)
+
+	span.SetAttributes(
+		attribute.Bool("got_work", gotWork),
+		attribute.Bool("complete", complete),
+	)
+	endSpan(span, err)
+
+	return gotWork, complete, err
+}
+
+func (u *Updater) doWork(ctx context.Context) (bool, bool, error) {
+	start := time.Now()
+
+	workResponse := workapi.WorkResponse{
+		Email:   u.accounts.next(),
+		Version: workapi.ProtocolVersion,
+		Tags:    u.opts.Tags,
+	}
+
+	sys, err := u.getKuboStats(ctx, &workResponse)
+	u.observeKubo(err)
+	u.notifyKubo(err)
+
+	if err != nil {
+		return false, false, fmt.Errorf("get kubo stats failed: %w", err)
+	}
+
+	u.history.addDiskSample(DiskSample{
+		Time:  time.Now(),
+		Used:  sys.DiskInfo.TotalSpace - sys.DiskInfo.FreeSpace,
+		Total: sys.DiskInfo.TotalSpace,
+	})
+
+	// Still request work while the disk is low, so the condition is
+	// reported to the server, and delete jobs can free up some space.
+	diskErr := u.checkDiskSpace(sys)
+	u.notifyDisk(diskErr)
+
+	if diskErr != nil {
+		u.log.Warn("free disk space below minimum, declining downloads and pins", "err", diskErr)
+	}
+
+	u.setCapacity(&workResponse, sys, diskErr)
+
+	if u.opts.ReportServed {
+		served := int(u.served.total.Load())
+		workResponse.Served = &served
+	}
+
+	u.retryAfter = 0
+
+	err = u.checkCircuit()
+	if err != nil {
+		return false, false, err
+	}
+
+	// Results from earlier cycles go first, so the server doesn't assign
+	// the same jobs again.
+	err = u.submitPending(ctx)
+	if err != nil {
+		u.observeServer(err)
+
+		return false, false, fmt.Errorf("sending pending results failed: %w", err)
+	}
+
+	work, err := u.workClient.RequestWork(ctx, workResponse)
+	u.observeServer(err)
+
+	if err != nil {
+		return false, false, fmt.Errorf("requesting work failed: %w", err)
+	}
+
+	u.retryAfter = work.RetryAfter
+
+	if work.NoWork() {
+		return false, false, nil
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("show", work.Show),
+		attribute.String("episode", work.Episode),
+		attribute.StringSlice("job_types", jobTypes(work)),
+	)
+
+	log := u.log.With("show", work.Show, "episode", work.Episode)
+	if u.accounts.several() {
+		log = log.With("account", workResponse.Email)
+	}
+	log.Debug("got work", "work", work)
+
+	u.emit(JobStarted{
+		Node:    u.opts.Name,
+		Time:    start,
+		Types:   jobTypes(work),
+		Show:    work.Show,
+		Episode: work.Episode,
+	})
+
+	defer func() {
+		u.observeJob(log, work, workResponse, start)
+	}()
+
+	// The capture ends once the jobs ran, before the results are sent.
+	// There's no return in between.
+	u.jobLog.start()
+
+	if work.Download != "" && work.Filename != "" {
+		log.Info("Got download job", "job_type", "download", "download", work.Download, "filename", work.Filename)
+
+		var downloaded *downloadFileResponse
+
+		err := u.declineJob("download", diskErr)
+		if err == nil {
+			ctx, span := tracer.Start(ctx, "download", trace.WithAttributes(
+				attribute.String("download", work.Download),
+				attribute.String("filename", work.Filename),
+			))
+			ctx, cancel := u.jobContext(ctx)
+
+			downloaded, err = u.downloadOrPinFile(ctx, work.Download, work.Filename, pinName(work), work.Length)
+			if err == nil {
+				err = u.checkContent(ctx, work, downloaded)
+			}
+			err = u.jobError(ctx, err)
+			cancel()
+			endSpan(span, err)
+		}
+
+		if err != nil {
+			log.Error("downloading file failed", "job_type", "download", "download", work.Download, "err", err)
+			setError(&workResponse, err)
+		} else {
+			workResponse.Downloaded = &downloaded.DownloadedFile
+			workResponse.Length = &downloaded.Length
+			if downloaded.SHA256 != "" {
+				workResponse.SHA256 = &downloaded.SHA256
+			}
+
+			metrics.BytesDownloaded.WithLabelValues(u.opts.Name).Add(float64(downloaded.Length))
+
+			u.addToCatalog(ctx, work, downloaded.Dir, downloaded.File, downloaded.Length, downloaded.SHA256, downloaded.MirrorKey)
+		}
+	}
+
+	if work.Pin != "" {
+		log.Info("Got pin job", "job_type", "pin", "cid", work.Pin)
+
+		var pinned *pinFileResponse
+
+		err := u.declineJob("pin", diskErr)
+		if err == nil {
+			ctx, span := tracer.Start(ctx, "pin", trace.WithAttributes(
+				attribute.String("cid", work.Pin),
+			))
+			ctx, cancel := u.jobContext(ctx)
+
+			pinned, err = u.pinFile(ctx, work.Pin, pinName(work))
+			err = u.jobError(ctx, err)
+			cancel()
+			endSpan(span, err)
+		}
+
+		if err != nil {
+			log.Error("pin add failed", "job_type", "pin", "cid", work.Pin, "err", err)
+			setError(&workResponse, err)
+		} else {
+			workResponse.Pinned = &pinned.Pinned
+			workResponse.Length = &pinned.Length
+
+			metrics.BytesPinned.WithLabelValues(u.opts.Name).Add(float64(pinned.Length))
+
+			u.addToCatalog(ctx, work, work.Pin, pinned.File, pinned.Length, "", "")
+		}
+	}
+
+	if work.Delete != "" {
+		log.Info("Got delete job", "job_type", "delete", "cid", work.Delete)
+
+		err := u.declineJob("delete", nil)
+		if err == nil {
+			ctx, span := tracer.Start(ctx, "delete", trace.WithAttributes(
+				attribute.String("cid", work.Delete),
+			))
+
+			err = kuboError(u.pinner.Unpin(ctx, work.Delete))
+			endSpan(span, err)
+		}
+
+		if err != nil {
+			log.Error("pin delete failed", "job_type", "delete", "cid", work.Delete, "err", err)
+			setError(&workResponse, err)
+		} else {
+			workResponse.Deleted = &work.Delete
+			u.deletedSinceGC = true
+
+			metrics.Deletes.WithLabelValues(u.opts.Name).Inc()
+
+			err = u.removeFromCatalog(ctx, work.Delete)
+			if err != nil {
+				log.Error("removing from catalog failed", "cid", work.Delete, "err", err)
+			}
+		}
+	}
+
+	lines := u.jobLog.stop()
+	if workResponse.Error != nil && lines != "" {
+		workResponse.SetErrorLog(lines)
+	}
+
+	stats, err := u.kubo.RepoStat(ctx)
+	if err != nil {
+		u.log.Error("repo stat failed", "err", err)
+	} else {
+		workResponse.Avail = &stats.StorageMax
+		workResponse.Used = &stats.RepoSize
+	}
+
+	// The results go out after the earlier pending ones, in order, and
+	// stay queued if the server can't be reached.
+	u.addPending(workResponse)
+
+	err = u.submitPending(ctx)
+	if err != nil {
+		u.observeServer(err)
+
+		return true, false, fmt.Errorf("post stats failed: %w", err)
+	}
+
+	if workResponse.Error != nil {
+		return true, false, nil
+	}
+
+	return true, true, nil
+}
+
+func (u *Updater) addToCatalog(ctx context.Context, work *workapi.Work, hash string, file string, size int, sha256 string, mirrorKey string) {
+	episode := catalog.Episode{
+		Hash:      hash,
+		File:      file,
+		Size:      size,
+		SHA256:    sha256,
+		Show:      work.Show,
+		Episode:   work.Episode,
+		Download:  work.Download,
+		MirrorKey: mirrorKey,
+		Expires:   u.expiry(work),
+	}
+
+	// Keep what's known about an episode which is pinned again.
+	previous, ok := u.opts.Catalog.Get(hash)
+	if ok {
+		episode.Archive = previous.Archive
+		episode.Replicated = previous.Replicated
+		episode.MirrorKey = cmp.Or(episode.MirrorKey, previous.MirrorKey)
+		episode.SHA256 = cmp.Or(episode.SHA256, previous.SHA256)
+		episode.Added = previous.Added
+		episode.Provided = previous.Provided
+
+		assigned := time.Now()
+		episode.Assigned = &assigned
+	}
+
+	err := u.opts.Catalog.Add(episode)
+	if err != nil {
+		u.log.Error("adding to catalog failed", "cid", hash, "err", err)
+	}
+
+	u.writeAlternateEnclosure(ctx, hash)
+
+	err = u.addToMFS(ctx, episode)
+	if err != nil {
+		u.log.Error("adding to mfs failed", "cid", hash, "err", err)
+	}
+
+	u.announce(ctx, hash, file)
+}
+
+// removeFromCatalog removes the episode from the catalog, along with its
+// alternate enclosure and MFS entry.
+func (u *Updater) removeFromCatalog(ctx context.Context, hash string) error {
+	episode, ok := u.opts.Catalog.Get(hash)
+
+	err := u.opts.Catalog.Remove(hash)
+	if err != nil {
+		return err
+	}
+
+	u.removeAlternateEnclosure(hash)
+
+	if ok {
+		u.removeFromMFS(ctx, episode)
+		u.removeFromMirror(ctx, episode.MirrorKey)
+	}
+
+	return nil
+}
+
+func (u *Updater) observeJob(log *slog.Logger, work *workapi.Work, r workapi.WorkResponse, start time.Time) {
+	duration := time.Since(start)
+
+	status := "success"
+	if r.Error != nil {
+		status = r.ErrorReason
+	}
+
+	record := JobRecord{
+		Time:     start,
+		Duration: duration,
+		Types:    jobTypes(work),
+		Show:     work.Show,
+		Episode:  work.Episode,
+		CID:      jobCID(work, r),
+		Status:   status,
+		Account:  r.Email,
+	}
+
+	u.history.addJob(record)
+	u.observeCatalog()
+	u.emit(JobFinished{
+		Node: u.opts.Name,
+		Job:  record,
+	})
+
+	for _, jobType := range jobTypes(work) {
+		log.Info("job done", "job_type", jobType, "status", status, "duration", duration)
+
+		metrics.ObserveJob(u.opts.Name, jobType, status, duration)
+
+		if u.accounts.several() {
+			metrics.AccountJobs.WithLabelValues(u.opts.Name, r.Email, jobType, status).Inc()
+		}
+
+		if r.Error != nil {
+			u.notify(notify.EventJobFailed, "A "+jobType+" job failed", map[string]any{
+				"job_type": jobType,
+				"reason":   status,
+				"show":     work.Show,
+				"episode":  work.Episode,
+			})
+		}
+
+		if u.opts.MaxShowLabels != 0 {
+			metrics.ShowJobs.WithLabelValues(u.opts.Name, u.showLabel(work.Show), jobType, status).Inc()
+		}
+	}
+}
+
+// observeCatalog sets the gauges of the episodes in the catalog.
+func (u *Updater) observeCatalog() {
+	episodes := u.opts.Catalog.Episodes()
+	shows := map[string]struct{}{}
+	size := 0
+
+	for _, episode := range episodes {
+		shows[episode.Show] = struct{}{}
+		size += episode.Size
+	}
+
+	metrics.EpisodesPinned.WithLabelValues(u.opts.Name).Set(float64(len(episodes)))
+	metrics.PinnedBytes.WithLabelValues(u.opts.Name).Set(float64(size))
+	metrics.ShowsHosted.WithLabelValues(u.opts.Name).Set(float64(len(shows)))
+}
+
+// jobTypes are the types of the jobs in the work.
+func jobTypes(work *workapi.Work) []string {
+	var types []string
+
+	if work.Download != "" {
+		types = append(types, "download")
+	}
+	if work.Pin != "" {
+		types = append(types, "pin")
+	}
+	if work.Delete != "" {
+		types = append(types, "delete")
+	}
+
+	return types
+}
+
+// showLabel is the value of the show label for the show. The first
+// MaxShowLabels shows keep their name, any later shows are "other".
+func (u *Updater) showLabel(show string) string {
+	if show == "" {
+		return "unknown"
+	}
+
+	_, ok := u.shows[show]
+	if ok {
+		return show
+	}
+
+	if len(u.shows) >= u.opts.MaxShowLabels {
+		return "other"
+	}
+
+	u.shows[show] = struct{}{}
+
+	return show
+}
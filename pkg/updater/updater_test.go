@@ -0,0 +1,307 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubotest"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/angaz/ipfspodcasting/pkg/workapitest"
+)
+
+// newTestUpdater creates an Updater for a fake Kubo node, which requests
+// work from a fake work server.
+func newTestUpdater(t *testing.T, opts Options) (*Updater, *kubotest.Server, *workapitest.Server) {
+	t.Helper()
+
+	node := kubotest.NewServer()
+	t.Cleanup(node.Close)
+
+	server := workapitest.NewServer()
+	t.Cleanup(server.Close)
+
+	k, err := node.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Email = "email@example.com"
+	opts.ServerURL = server.URL
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	u, err := New(k, opts)
+	if err != nil {
+		t.Fatalf("creating updater failed: %v", err)
+	}
+
+	return u, node, server
+}
+
+// runJob queues work on the server, runs a work cycle, and returns the
+// result the Updater sent.
+func runJob(t *testing.T, u *Updater, server *workapitest.Server, work workapi.Work) workapi.WorkResponse {
+	t.Helper()
+
+	server.Queue(work)
+
+	gotWork, _, err := u.DoWork(context.Background())
+	if err != nil {
+		t.Fatalf("work cycle failed: %v", err)
+	}
+
+	if !gotWork {
+		t.Fatal("work cycle got no work")
+	}
+
+	responses := server.Responses()
+	if len(responses) == 0 {
+		t.Fatal("no results were sent")
+	}
+
+	return responses[len(responses)-1]
+}
+
+// newOrigin serves body as every episode, with the content type.
+func newOrigin(t *testing.T, contentType string, body []byte) *httptest.Server {
+	t.Helper()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}))
+	t.Cleanup(origin.Close)
+
+	return origin
+}
+
+// requestPaths are the paths of the requests the server received, in order.
+func requestPaths(server *workapitest.Server) []string {
+	var paths []string
+
+	for _, request := range server.Requests() {
+		paths = append(paths, request.Path)
+	}
+
+	return paths
+}
+
+func TestDoWorkPendingResults(t *testing.T) {
+	pendingPath := filepath.Join(t.TempDir(), "pending.json")
+
+	u, node, server := newTestUpdater(t, Options{PendingPath: pendingPath})
+
+	dir1, file1 := node.Provide("episode-1.mp3", testEpisode)
+	dir2, file2 := node.Provide("episode-2.mp3", testEpisode[:900])
+
+	server.SetResponseStatus(http.StatusServiceUnavailable)
+	server.Queue(workapi.Work{Show: "show", Episode: "episode-1", Pin: dir1})
+
+	// Server errors are retried with a backoff, which the deadline cuts
+	// short.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	gotWork, _, err := u.DoWork(ctx)
+	cancel()
+
+	if !gotWork || err == nil {
+		t.Fatalf("got work %t and error %v, want the result to fail", gotWork, err)
+	}
+
+	_, err = os.Stat(pendingPath)
+	if err != nil {
+		t.Fatalf("the pending result wasn't saved: %v", err)
+	}
+
+	// A restart keeps the result.
+	u, err = New(u.kubo, u.opts)
+	if err != nil {
+		t.Fatalf("creating updater failed: %v", err)
+	}
+
+	if len(u.pending) != 1 {
+		t.Fatalf("got %d pending results after the restart, want 1", len(u.pending))
+	}
+
+	server.SetResponseStatus(0)
+
+	result := runJob(t, u, server, workapi.Work{Show: "show", Episode: "episode-2", Pin: dir2})
+
+	// The result of the first job goes out before the next request, so
+	// the server doesn't assign it again.
+	paths := requestPaths(server)
+	want := []string{"/request", "/response", "/request", "/response"}
+
+	if !slices.Equal(paths, want) {
+		t.Fatalf("got requests %v, want %v", paths, want)
+	}
+
+	responses := server.Responses()
+
+	if responses[0].Pinned == nil || *responses[0].Pinned != file1+"/"+dir1 {
+		t.Errorf("got first result pinned %v, want %s/%s", responses[0].Pinned, file1, dir1)
+	}
+
+	if result.Pinned == nil || *result.Pinned != file2+"/"+dir2 {
+		t.Errorf("got second result pinned %v, want %s/%s", result.Pinned, file2, dir2)
+	}
+
+	if len(u.pending) != 0 {
+		t.Errorf("got %d pending results, want none", len(u.pending))
+	}
+
+	_, err = os.Stat(pendingPath)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the pending results file wasn't removed: %v", err)
+	}
+}
+
+func TestDoWorkResultStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		// kept is if the results stay pending, to be sent again.
+		kept bool
+	}{
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			kept:   true,
+		},
+		{
+			name:   "bad signature",
+			status: http.StatusUnauthorized,
+			kept:   true,
+		},
+		{
+			name:   "rejected",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, node, server := newTestUpdater(t, Options{})
+
+			dir1, _ := node.Provide("episode-1.mp3", testEpisode)
+			dir2, _ := node.Provide("episode-2.mp3", testEpisode[:900])
+
+			server.SetResponseStatus(tt.status)
+			server.Queue(workapi.Work{Show: "show", Episode: "episode-1", Pin: dir1})
+
+			_, _, err := u.DoWork(context.Background())
+
+			if tt.kept {
+				if err == nil || len(u.pending) != 1 {
+					t.Fatalf("got error %v and %d pending results, want the result kept", err, len(u.pending))
+				}
+			} else if err != nil || len(u.pending) != 0 {
+				t.Fatalf("got error %v and %d pending results, want the result dropped", err, len(u.pending))
+			}
+
+			server.SetResponseStatus(0)
+
+			result := runJob(t, u, server, workapi.Work{Show: "show", Episode: "episode-2", Pin: dir2})
+
+			responses := server.Responses()
+
+			want := 1
+			if tt.kept {
+				want = 2
+			}
+
+			if len(responses) != want {
+				t.Fatalf("got %d results, want %d", len(responses), want)
+			}
+
+			if tt.kept && (responses[0].Pinned == nil || !strings.HasSuffix(*responses[0].Pinned, "/"+dir1)) {
+				t.Errorf("got first result pinned %v, want the first episode", responses[0].Pinned)
+			}
+
+			if result.Pinned == nil || !strings.HasSuffix(*result.Pinned, "/"+dir2) {
+				t.Errorf("got last result pinned %v, want the second episode", result.Pinned)
+			}
+		})
+	}
+}
+
+func TestDoWorkCircuitBreaker(t *testing.T) {
+	u, node, server := newTestUpdater(t, Options{
+		ServerFailures: 2,
+		ServerCooldown: time.Hour,
+	})
+
+	dir, _ := node.Provide("episode.mp3", testEpisode)
+
+	// A reply without a body fails to decode right away, unlike server
+	// errors, which are retried.
+	failure := workapitest.Reply{Status: http.StatusBadRequest}
+
+	server.QueueReply(failure, failure, failure)
+	server.Queue(workapi.Work{Show: "show", Episode: "episode", Pin: dir})
+
+	ctx := context.Background()
+
+	for range 2 {
+		_, _, err := u.DoWork(ctx)
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("got error %v, want the request to fail", err)
+		}
+	}
+
+	requests := len(server.Requests())
+
+	_, _, err := u.DoWork(ctx)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v, want %v", err, ErrCircuitOpen)
+	}
+
+	if len(server.Requests()) != requests {
+		t.Fatal("work was requested while the circuit is open")
+	}
+
+	// After the cooldown, one failure opens the circuit again.
+	u.breaker.openUntil = time.Now()
+
+	_, _, err = u.DoWork(ctx)
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v, want the request to fail", err)
+	}
+
+	_, _, err = u.DoWork(ctx)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A success closes it.
+	u.breaker.openUntil = time.Now()
+
+	gotWork, complete, err := u.DoWork(ctx)
+	if err != nil || !gotWork || !complete {
+		t.Fatalf("got work %t, complete %t, error %v, want the job to succeed", gotWork, complete, err)
+	}
+
+	if u.breaker.failures != 0 || !u.breaker.openUntil.IsZero() {
+		t.Fatalf("circuit is still open after a success: %+v", u.breaker)
+	}
+
+	server.QueueReply(failure)
+
+	_, _, err = u.DoWork(ctx)
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v, want the request to fail", err)
+	}
+
+	_, _, err = u.DoWork(ctx)
+	if err != nil {
+		t.Fatalf("got error %v after one failure, want the circuit to stay closed", err)
+	}
+}
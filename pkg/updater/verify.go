@@ -0,0 +1,154 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/catalog"
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// verify traverses the blocks of the pins, and repairs the episodes in the
+// catalog which have missing or corrupt blocks.
+func (u *Updater) verify(ctx context.Context) error {
+	start := time.Now()
+	status := "error"
+
+	defer func() {
+		metrics.VerifyHistogram.WithLabelValues(u.opts.Name, status).Observe(time.Since(start).Seconds())
+	}()
+
+	u.log.Info("starting pin verification")
+
+	bad, err := u.kubo.PinVerify(ctx)
+	if err != nil {
+		return fmt.Errorf("pin verify failed: %w", err)
+	}
+
+	u.lastVerify = time.Now()
+	status = "success"
+
+	var fixed []string
+
+	for _, pin := range bad {
+		episode, ok := u.opts.Catalog.Get(pin.Cid)
+		if !ok {
+			continue
+		}
+
+		metrics.VerifyBadPins.WithLabelValues(u.opts.Name).Inc()
+
+		u.log.Warn(
+			"pinned episode has missing or corrupt blocks",
+			"cid", pin.Cid,
+			"bad_blocks", len(pin.BadNodes),
+			"err", pin.Err,
+		)
+
+		err := u.repair(ctx, episode, pin.BadNodes)
+		if err != nil {
+			metrics.VerifyRepairs.WithLabelValues(u.opts.Name, "error").Inc()
+			u.log.Error("repairing episode failed", "cid", pin.Cid, "err", err)
+
+			continue
+		}
+
+		fixed = append(fixed, pin.Cid)
+	}
+
+	repaired := 0
+
+	if len(fixed) != 0 {
+		repaired = u.checkRepaired(ctx, fixed)
+	}
+
+	u.log.Info(
+		"pin verification finished",
+		"bad", len(bad),
+		"repaired", repaired,
+		"duration", time.Since(start),
+	)
+
+	return nil
+}
+
+// repair unpins the episode, removes its bad blocks, and pins it again,
+// which fetches the removed blocks from the network. Kubo doesn't remove
+// blocks which are part of a pin, so it's unpinned first, and pinned again
+// even when removing the blocks failed.
+func (u *Updater) repair(ctx context.Context, episode catalog.Episode, badNodes []kubo.BadNode) error {
+	err := u.pinner.Unpin(ctx, episode.Hash)
+	if err != nil {
+		return fmt.Errorf("unpin failed: %w", err)
+	}
+
+	var rmErr error
+
+	for _, node := range badNodes {
+		err := u.kubo.BlockRm(ctx, node.Cid)
+		if err != nil {
+			rmErr = fmt.Errorf("removing bad block %s failed: %w", node.Cid, err)
+
+			break
+		}
+	}
+
+	name := pinName(&workapi.Work{Show: episode.Show, Episode: episode.Episode})
+
+	err = u.pinner.Pin(ctx, episode.Hash, name)
+	if err != nil {
+		return fmt.Errorf("repin failed: %w", err)
+	}
+
+	return rmErr
+}
+
+// checkRepaired verifies the pins again, and counts the repaired episodes
+// which no longer have bad blocks. The others still count as failed
+// repairs.
+func (u *Updater) checkRepaired(ctx context.Context, hashes []string) int {
+	bad, err := u.kubo.PinVerify(ctx)
+	if err != nil {
+		metrics.VerifyRepairs.WithLabelValues(u.opts.Name, "error").Add(float64(len(hashes)))
+		u.log.Error("verifying repaired episodes failed", "err", err)
+
+		return 0
+	}
+
+	stillBad := map[string]bool{}
+	for _, pin := range bad {
+		stillBad[pin.Cid] = true
+	}
+
+	repaired := 0
+
+	for _, hash := range hashes {
+		if stillBad[hash] {
+			metrics.VerifyRepairs.WithLabelValues(u.opts.Name, "error").Inc()
+			u.log.Error("repaired episode still has bad blocks", "cid", hash)
+
+			continue
+		}
+
+		metrics.VerifyRepairs.WithLabelValues(u.opts.Name, "success").Inc()
+		repaired += 1
+	}
+
+	return repaired
+}
+
+// maybeVerify verifies the pins if it hasn't been done within the verify
+// interval.
+func (u *Updater) maybeVerify(ctx context.Context) {
+	if u.opts.VerifyInterval == 0 || time.Since(u.lastVerify) < u.opts.VerifyInterval {
+		return
+	}
+
+	err := u.verify(ctx)
+	if err != nil {
+		u.log.Error("pin verification failed", "err", err)
+	}
+}
@@ -0,0 +1,107 @@
+package updater
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// repairs returns the count of the repairs of the node with status.
+func repairs(t *testing.T, node string, status string) float64 {
+	t.Helper()
+
+	var m dto.Metric
+
+	err := metrics.VerifyRepairs.WithLabelValues(node, status).Write(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// shared pins the file of the episode in another directory too,
+		// which keeps Kubo from removing its block.
+		shared   bool
+		pinError string
+		repaired bool
+	}{
+		{
+			name:     "repaired",
+			repaired: true,
+		},
+		{
+			name:   "block of another pin",
+			shared: true,
+		},
+		{
+			name:     "repin failure",
+			pinError: "context deadline exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, node, _ := newTestUpdater(t, Options{Name: "verify " + tt.name})
+
+			dir, file := node.Provide("episode.mp3", testEpisode)
+			pinEpisode(t, u, node, dir)
+
+			if tt.shared {
+				other, _ := node.Provide("copy.mp3", testEpisode)
+
+				err := u.pinner.Pin(context.Background(), other, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			node.Corrupt(file)
+			node.SetError("pin/add", tt.pinError)
+
+			err := u.verify(context.Background())
+			if err != nil {
+				t.Fatalf("verify failed: %v", err)
+			}
+
+			success := repairs(t, u.opts.Name, "success")
+			failed := repairs(t, u.opts.Name, "error")
+
+			if tt.repaired && (success != 1 || failed != 0) {
+				t.Errorf("got %v repaired and %v failed, want the episode repaired", success, failed)
+			}
+
+			if !tt.repaired && (success != 0 || failed != 1) {
+				t.Errorf("got %v repaired and %v failed, want the repair to fail", success, failed)
+			}
+
+			if tt.pinError != "" {
+				return
+			}
+
+			// The episode is pinned again, even when the repair failed.
+			if name, ok := node.Pins()[dir]; !ok || name != "show/episode" {
+				t.Errorf("directory %s isn't pinned as show/episode: %v", dir, node.Pins())
+			}
+
+			k, err := node.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bad, err := k.PinVerify(context.Background())
+			if err != nil {
+				t.Fatalf("pin verify failed: %v", err)
+			}
+
+			if tt.repaired && len(bad) != 0 {
+				t.Errorf("repaired episode still has bad blocks: %+v", bad)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/kubo"
+	"github.com/angaz/ipfspodcasting/pkg/metrics"
+	"github.com/angaz/ipfspodcasting/pkg/notify"
+)
+
+const (
+	// kuboRetryMin is the first wait before checking if Kubo is back. It
+	// doubles after every failed check, up to Options.KuboRetryMax.
+	kuboRetryMin = 5 * time.Second
+	// kuboCheckTimeout is how long a check if Kubo is back may take.
+	kuboCheckTimeout = 10 * time.Second
+)
+
+// watchdogState is what the watchdog knows about the Kubo node.
+type watchdogState struct {
+	// nodeID is the ID of the node at the last successful cycle, empty
+	// before the first one.
+	nodeID    string
+	version   kubo.Version
+	downSince time.Time
+}
+
+// reconnectKubo waits for Kubo to be reachable again, checking with a
+// backoff, so a node which restarts is back at work in seconds, and one
+// which is gone for a while isn't polled every few seconds. A triggered
+// cycle checks right away. It only returns an error when ctx is done.
+func (u *Updater) reconnectKubo(ctx context.Context) error {
+	backoff := kuboRetryMin
+
+	for {
+		u.log.Info("waiting for kubo to be reachable", "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		case <-u.wake:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, kuboCheckTimeout)
+		err := u.CheckKubo(checkCtx)
+		cancel()
+
+		if err == nil {
+			metrics.KuboUp.WithLabelValues(u.opts.Name).Set(1)
+			u.log.Info("kubo is reachable again", "down_for", time.Since(u.watchdog.downSince).Round(time.Second))
+
+			// Kubo may have been upgraded while it was down.
+			return u.detectKuboVersion(ctx)
+		}
+
+		u.log.Debug("kubo is still unreachable", "err", err)
+
+		backoff = min(backoff*2, u.opts.KuboRetryMax)
+	}
+}
+
+// detectKuboVersion detects the version of Kubo, so the client adapts to
+// it. It only returns an error, wrapping kubo.ErrUnsupportedVersion, when
+// Kubo is too old to run the updater. When Kubo is unreachable, the client
+// keeps assuming the version it detected last, or the latest.
+func (u *Updater) detectKuboVersion(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, kuboCheckTimeout)
+	defer cancel()
+
+	version, err := u.kubo.DetectVersion(ctx)
+	if errors.Is(err, kubo.ErrUnsupportedVersion) {
+		return err
+	}
+	if err != nil {
+		u.log.Warn("detecting kubo version failed", "err", err)
+
+		return nil
+	}
+
+	if version != u.watchdog.version {
+		u.log.Info("detected kubo version", "version", version, "pin_names", u.kubo.PinNames())
+		u.watchdog.version = version
+	}
+
+	return nil
+}
+
+// checkNodeID warns, and sends a kubo_identity_changed event, when the ID of
+// the node changed since the last cycle, like when the API address points at
+// another node, or the repo was replaced. The pins may be gone with the old
+// repo, so the catalog is reconciled after the cycle.
+func (u *Updater) checkNodeID(id string) {
+	previous := u.watchdog.nodeID
+	u.watchdog.nodeID = id
+
+	if previous == "" || previous == id {
+		return
+	}
+
+	u.log.Warn("kubo node id changed", "previous_id", previous, "id", id)
+	u.notify(notify.EventKuboIdentityChanged, "Kubo's node ID changed", map[string]any{
+		"previous_id": previous,
+		"id":          id,
+	})
+
+	u.reconcileRequested.Store(true)
+}
+
+// observeKubo updates the availability of Kubo, from the result of the
+// cycle's requests to it.
+func (u *Updater) observeKubo(err error) {
+	if err == nil {
+		metrics.KuboUp.WithLabelValues(u.opts.Name).Set(1)
+
+		return
+	}
+
+	metrics.KuboUp.WithLabelValues(u.opts.Name).Set(0)
+
+	if !u.kuboDown {
+		u.watchdog.downSince = time.Now()
+	}
+}
@@ -0,0 +1,5 @@
+// Package web3storage is a client for uploading CAR files to web3.storage,
+// or a service with a compatible HTTP API, with an API token.
+//
+// A CAR larger than MaxCARSize has to be split before it's uploaded.
+package web3storage
@@ -0,0 +1,93 @@
+package web3storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// DefaultEndpoint is the URL of the web3.storage API.
+const DefaultEndpoint = "https://api.web3.storage"
+
+// MaxCARSize is the largest CAR, in bytes, which can be uploaded in one
+// request. Larger content has to be split into several CARs.
+const MaxCARSize = 100 * 1000 * 1000
+
+// Client uploads CAR files to the API.
+type Client struct {
+	httpClient *http.Client
+	apiURL     *url.URL
+	token      string
+}
+
+// New creates a Client for the API at endpoint, like DefaultEndpoint.
+// token is sent as a Bearer token.
+func New(httpClient *http.Client, endpoint string, token string) (*Client, error) {
+	apiURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing web3.storage endpoint failed: %w", err)
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("web3.storage token is required")
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		apiURL:     apiURL,
+		token:      token,
+	}, nil
+}
+
+type uploadResponse struct {
+	CID     string `json:"cid"`
+	Message string `json:"message"`
+}
+
+// UploadCAR uploads the CAR car, named name, and returns the CID of its
+// root.
+func (c *Client) UploadCAR(ctx context.Context, car io.Reader, name string) (string, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.apiURL.JoinPath("car").String(),
+		car,
+	)
+	if err != nil {
+		return "", fmt.Errorf("creating request failed: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/vnd.ipld.car")
+
+	if name != "" {
+		req.Header.Set("X-Name", url.PathEscape(name))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var uploaded uploadResponse
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(&uploaded)
+
+	if resp.StatusCode != http.StatusOK {
+		if uploaded.Message != "" {
+			return "", fmt.Errorf("response not OK: %d: %s", resp.StatusCode, uploaded.Message)
+		}
+
+		return "", fmt.Errorf("response not OK: %d", resp.StatusCode)
+	}
+
+	if decodeErr != nil {
+		return "", fmt.Errorf("decoding json failed: %w", decodeErr)
+	}
+
+	return uploaded.CID, nil
+}
@@ -0,0 +1,352 @@
+package workapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the IPFS Podcasting server.
+	DefaultBaseURL = "https://ipfspodcasting.net"
+
+	// ProtocolVersion is sent as the version of the client.
+	ProtocolVersion = "0.6g" // g postfix used for this Go client.
+)
+
+// ErrServerError is when the server kept responding with a 5xx status.
+var ErrServerError = errors.New("server error")
+
+// ErrRejected is when the server refused the results for good, because
+// sending them again can't succeed, like when they're malformed.
+var ErrRejected = errors.New("results rejected")
+
+// ErrJSONRejected is when the server rejected a request in the v2 JSON
+// protocol, and the protocol is ProtocolJSON, so it isn't sent again with the
+// form encoding.
+var ErrJSONRejected = errors.New("work server rejected the json protocol")
+
+// Client talks to the IPFS Podcasting work server.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	secret     []byte
+	log        *slog.Logger
+	recorder   *Recorder
+	limiter    Limiter
+
+	protocol Protocol
+	// useJSON is if the requests are sent with the v2 JSON protocol. It
+	// changes when the server accepts, or rejects, the protocol.
+	useJSON atomic.Bool
+}
+
+// NewClient creates a Client for the server at baseURL. An empty baseURL
+// uses DefaultBaseURL.
+func NewClient(httpClient *http.Client, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		protocol:   ProtocolAuto,
+	}
+}
+
+// SetProtocol selects the encoding of the requests. The default is
+// ProtocolAuto.
+func (c *Client) SetProtocol(protocol Protocol) {
+	c.protocol = protocol
+	c.useJSON.Store(protocol == ProtocolJSON)
+}
+
+// SetUserAgent sets the User-Agent header of the requests to the server.
+// Empty uses Go's default.
+func (c *Client) SetUserAgent(userAgent string) {
+	c.userAgent = userAgent
+}
+
+// SetLogger sets the logger of the client. Nil uses slog.Default().
+func (c *Client) SetLogger(log *slog.Logger) {
+	c.log = log
+}
+
+// logger is the logger set with SetLogger, or the default logger.
+func (c *Client) logger() *slog.Logger {
+	if c.log == nil {
+		return slog.Default()
+	}
+
+	return c.log
+}
+
+// Limiter limits the rate of the requests to the server, like a
+// rate.Limiter from golang.org/x/time/rate.
+type Limiter interface {
+	// Wait blocks until a request may be sent, or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// SetLimiter makes every request, including the retries, wait for limiter
+// first. A Limiter shared by several clients limits their combined rate.
+// Nil doesn't limit the requests.
+func (c *Client) SetLimiter(limiter Limiter) {
+	c.limiter = limiter
+}
+
+// wait waits for the limiter, if one is set.
+func (c *Client) wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	err := c.limiter.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for rate limit failed: %w", err)
+	}
+
+	return nil
+}
+
+// SetSecret signs the requests with the node's shared secret, and sends
+// SignedProtocolVersion as the version. Empty disables signing.
+func (c *Client) SetSecret(secret string) {
+	c.secret = []byte(secret)
+}
+
+// retryable is if a failed request may succeed when it's sent again. The
+// server closes connections early from time to time, and times out, or
+// responds with server errors, while it's overloaded or restarting.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+
+		return strings.Contains(err.Error(), "EOF") ||
+			(errors.As(err, &netErr) && netErr.Timeout())
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// post sends workResponse to path, retrying a few times, with a backoff,
+// when the request fails in a way which may succeed later, see retryable.
+// It's sent with the v2 JSON protocol if the server supports it.
+func (c *Client) post(ctx context.Context, path string, workResponse WorkResponse) (*http.Response, error) {
+	retries := 5
+	backoff := 5 * time.Second
+
+	if len(c.secret) != 0 {
+		workResponse.Version = SignedProtocolVersion
+	}
+
+	for {
+		sentJSON := c.useJSON.Load()
+
+		body, contentType, err := encode(workResponse, sentJSON)
+		if err != nil {
+			return nil, fmt.Errorf("encoding body failed: %w", err)
+		}
+
+		// The error log is too long, and too noisy, to log every time.
+		logged := workResponse
+		logged.ErrorLog = ""
+
+		c.logger().Info("work response", "data", logged.String())
+
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodPost,
+			c.baseURL+path,
+			bytes.NewReader(body),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("creating request failed: %w", err)
+		}
+
+		req.Header.Set("Content-Type", contentType)
+		if c.protocol != ProtocolForm {
+			req.Header.Set("Accept", JSONContentType+", application/json;q=0.9")
+		}
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+
+		resp, err := c.send(c.httpClient, req, body)
+		c.record(path, contentType, body, resp, err)
+
+		if retryable(resp, err) && ctx.Err() == nil {
+			if err == nil {
+				resp.Body.Close()
+
+				err = fmt.Errorf("%w: %d", ErrServerError, resp.StatusCode)
+			}
+
+			if retries == 0 {
+				return nil, err
+			}
+
+			c.logger().Info("ipfspodcasting.net"+path+" failed, retrying", "err", err, "retries_left", retries, "backoff", backoff)
+			retries -= 1
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.logger().Debug("work server response", "path", path, "status", resp.StatusCode)
+
+		if sentJSON && rejectsJSON(resp.StatusCode) {
+			resp.Body.Close()
+
+			// JSON was asked for explicitly, so it isn't silently
+			// replaced by the form encoding.
+			if c.protocol != ProtocolAuto {
+				return nil, fmt.Errorf("%w: status %d", ErrJSONRejected, resp.StatusCode)
+			}
+
+			c.logger().Info("work server rejected the json protocol, falling back to form encoding", "status", resp.StatusCode)
+			c.useJSON.Store(false)
+
+			continue
+		}
+
+		if !sentJSON && c.protocol == ProtocolAuto && isJSONResponse(resp) {
+			c.logger().Info("work server supports the json protocol, switching to it")
+
+			c.useJSON.Store(true)
+		}
+
+		return resp, nil
+	}
+}
+
+// RequestWork asks the server for work. Work.NoWork is true if there is
+// nothing to do.
+func (c *Client) RequestWork(ctx context.Context, workResponse WorkResponse) (*Work, error) {
+	resp, err := c.post(ctx, "/request", workResponse)
+	if err != nil {
+		return nil, fmt.Errorf("fetching work failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	var work Work
+
+	err = decoder.Decode(&work)
+	if err != nil {
+		return nil, fmt.Errorf("decoding work failed: %w", err)
+	}
+
+	work.RetryAfter = ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
+	return &work, nil
+}
+
+// ParseRetryAfter parses a Retry-After header, which is either a number of
+// seconds, or an HTTP date relative to now. It returns zero if the header is
+// empty or invalid.
+func ParseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	date, err := http.ParseTime(header)
+	if err == nil {
+		return max(date.Sub(now), 0)
+	}
+
+	return 0
+}
+
+// rejected is if the status refuses the results for good. Other client
+// errors, like a rate limit, a timeout, or a signature which failed because
+// of clock skew, may succeed later.
+func rejected(status int) bool {
+	switch status {
+	case http.StatusBadRequest,
+		http.StatusConflict,
+		http.StatusGone,
+		http.StatusRequestEntityTooLarge,
+		http.StatusUnprocessableEntity:
+		return true
+	}
+
+	return false
+}
+
+// RespondWork sends the results of the work to the server. Only a 2xx
+// status counts as delivered. The results fail with ErrRejected if the
+// server refused them for good, and with another error if they should be
+// sent again later.
+func (c *Client) RespondWork(ctx context.Context, workResponse WorkResponse) error {
+	resp, err := c.post(ctx, "/response", workResponse)
+	if err != nil {
+		return fmt.Errorf("posting response failed: %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if rejected(resp.StatusCode) {
+			return fmt.Errorf("%w: status %d", ErrRejected, resp.StatusCode)
+		}
+
+		return fmt.Errorf("posting response failed: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Ping checks if the server is reachable. Any response, other than a server
+// error, counts as reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.baseURL+"/", nil)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+
+	err = c.wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("server error: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package workapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRespondWorkStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		// delivered is if the results count as delivered, and rejected if
+		// they were refused for good.
+		delivered bool
+		rejected  bool
+	}{
+		{status: http.StatusOK, delivered: true},
+		{status: http.StatusNoContent, delivered: true},
+		{status: http.StatusBadRequest, rejected: true},
+		{status: http.StatusUnprocessableEntity, rejected: true},
+		{status: http.StatusUnauthorized},
+		{status: http.StatusForbidden},
+		{status: http.StatusNotFound},
+		{status: http.StatusRequestTimeout},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.Client(), server.URL)
+
+			err := client.RespondWork(context.Background(), WorkResponse{Email: "email@example.com"})
+
+			if tt.delivered != (err == nil) {
+				t.Fatalf("got error %v, want delivered %t", err, tt.delivered)
+			}
+
+			if errors.Is(err, ErrRejected) != tt.rejected {
+				t.Errorf("got error %v, want rejected %t", err, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "empty", header: "", want: 0},
+		{name: "seconds", header: "120", want: 2 * time.Minute},
+		{name: "negative seconds", header: "-5", want: 0},
+		{name: "date", header: "Tue, 02 Jan 2024 15:34:05 GMT", want: 30 * time.Minute},
+		{name: "past date", header: "Tue, 02 Jan 2024 14:04:05 GMT", want: 0},
+		{name: "garbage", header: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseRetryAfter(tt.header, now)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONRejected(t *testing.T) {
+	tests := []struct {
+		protocol Protocol
+		// fallback is if the request is sent again with the form encoding.
+		fallback bool
+	}{
+		{protocol: ProtocolAuto, fallback: true},
+		{protocol: ProtocolJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.protocol), func(t *testing.T) {
+			var contentTypes []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+
+				if r.Header.Get("Content-Type") == JSONContentType {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient(server.Client(), server.URL)
+			client.SetProtocol(tt.protocol)
+			// Auto only sends JSON once the server supports it.
+			client.useJSON.Store(true)
+
+			err := client.RespondWork(context.Background(), WorkResponse{Email: "email@example.com"})
+
+			if tt.fallback {
+				if err != nil {
+					t.Errorf("responding failed: %v", err)
+				}
+
+				if len(contentTypes) != 2 || contentTypes[1] != "application/x-www-form-urlencoded" {
+					t.Errorf("got requests %v, want JSON, then the form encoding", contentTypes)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrJSONRejected) || errors.Is(err, ErrRejected) {
+				t.Errorf("got error %v, want %v", err, ErrJSONRejected)
+			}
+
+			if len(contentTypes) != 1 {
+				t.Errorf("got requests %v, want only the JSON one", contentTypes)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package workapi implements the IPFS Podcasting work protocol.
+//
+// A node requests work by POSTing its stats to /request, and receives a Work
+// with the jobs to run. The results are POSTed to /response:
+//
+//	client := workapi.NewClient(http.DefaultClient, "")
+//
+//	work, err := client.RequestWork(ctx, workapi.WorkResponse{
+//		Email:   "email@example.com",
+//		Version: workapi.ProtocolVersion,
+//	})
+//
+// The server sends the work as JSON, but the requests are form encoded,
+// which loses the types of the fields. Servers which support the v2 protocol
+// respond with JSONContentType, and the Client then sends JSON requests, with
+// the same fields, and the error as an object, see WorkResponse.JSON. Only
+// the v2 protocol carries the log lines of a failed job, see
+// WorkResponse.SetErrorLog.
+//
+// Servers may also list the backbone peers of the network at /peers, which
+// the nodes keep connections to, see Client.Peers.
+package workapi
@@ -0,0 +1,196 @@
+package workapi
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// EventWork is the type of the event which announces work for the node,
+	// which it requests with RequestWork, so the result is tracked like any
+	// other job. Its data is empty, or the email of the account which has
+	// the work, for nodes which work for several.
+	EventWork = "work"
+
+	// EventStreamContentType is the media type of the event stream.
+	EventStreamContentType = "text/event-stream"
+
+	// EventIdleTimeout is how long the stream may be silent before it's
+	// considered dropped. Servers send a comment as a keepalive more often.
+	EventIdleTimeout = 2 * time.Minute
+)
+
+// ErrEventsNotSupported is when the server doesn't have an event stream.
+var ErrEventsNotSupported = errors.New("server doesn't support events")
+
+// Event is a server-sent event from the work server.
+type Event struct {
+	// Type is the event field, like EventWork. Defaults to "message".
+	Type string
+	Data string
+	ID   string
+}
+
+// EventStream is a connection to the event stream of the server, see
+// Client.Subscribe.
+type EventStream struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	idle    *time.Timer
+}
+
+// Subscribe connects to the server's event stream at /events, which sends
+// server-sent events. It returns ErrEventsNotSupported if the server doesn't
+// have the stream. The caller should call Close when finished.
+//
+// The emails of the accounts the node works for identify it, and the
+// request is signed like the others, with an empty body, when a secret is
+// set.
+func (c *Client) Subscribe(ctx context.Context, emails ...string) (*EventStream, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	stream, err := c.subscribe(ctx, emails)
+	if err != nil {
+		cancel(nil)
+
+		return nil, err
+	}
+
+	stream.ctx = ctx
+	stream.cancel = cancel
+	stream.idle = time.AfterFunc(EventIdleTimeout, func() {
+		cancel(errors.New("events idle timeout"))
+	})
+
+	return stream, nil
+}
+
+func (c *Client) subscribe(ctx context.Context, emails []string) (*EventStream, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		c.baseURL+"/events?"+url.Values{"email": emails}.Encode(),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	req.Header.Set("Accept", EventStreamContentType)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	// The stream stays open, so the timeout of the other requests can't
+	// apply. The idle timer of the EventStream detects dead connections
+	// instead.
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	resp, err := c.send(&streamClient, req, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to events failed: %w", err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		err = ErrEventsNotSupported
+	case resp.StatusCode != http.StatusOK:
+		err = fmt.Errorf("connecting to events failed: status %d", resp.StatusCode)
+	case mediaType != EventStreamContentType:
+		err = fmt.Errorf("%w: content type %q", ErrEventsNotSupported, mediaType)
+	}
+
+	if err != nil {
+		resp.Body.Close()
+
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+
+	return &EventStream{
+		body:    resp.Body,
+		scanner: scanner,
+	}, nil
+}
+
+// Next waits for the next event. It returns an error when the stream ends,
+// or was silent for longer than EventIdleTimeout, or the context of
+// Subscribe is done.
+func (s *EventStream) Next() (Event, error) {
+	var (
+		event Event
+		data  []string
+	)
+
+	for s.scanner.Scan() {
+		s.idle.Reset(EventIdleTimeout)
+
+		line := s.scanner.Text()
+
+		if line == "" {
+			// Events without data are dispatched too, unlike in
+			// browsers, so "event: work" alone is enough.
+			if data != nil || event.Type != "" {
+				event.Data = strings.Join(data, "\n")
+				event.Type = cmp.Or(event.Type, "message")
+
+				return event, nil
+			}
+
+			continue
+		}
+
+		// Comments are keepalives.
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		}
+	}
+
+	cause := context.Cause(s.ctx)
+	if cause != nil {
+		return Event{}, cause
+	}
+
+	err := s.scanner.Err()
+	if err != nil {
+		return Event{}, fmt.Errorf("reading events failed: %w", err)
+	}
+
+	return Event{}, errors.New("events stream ended")
+}
+
+// Close closes the connection.
+func (s *EventStream) Close() error {
+	s.idle.Stop()
+	s.cancel(nil)
+
+	return s.body.Close()
+}
@@ -0,0 +1,79 @@
+package workapi_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+	"github.com/angaz/ipfspodcasting/pkg/workapitest"
+)
+
+// A node requests work, runs the job, and reports the result.
+func ExampleClient_RequestWork() {
+	server := workapitest.NewServer()
+	defer server.Close()
+
+	server.Queue(workapi.Work{
+		Show:     "show",
+		Episode:  "episode",
+		Download: "https://example.com/episode.mp3",
+		Filename: "episode.mp3",
+	})
+
+	ctx := context.Background()
+	client := workapi.NewClient(http.DefaultClient, server.URL)
+
+	workResponse := workapi.WorkResponse{
+		Email:   "email@example.com",
+		Version: workapi.ProtocolVersion,
+	}
+
+	work, err := client.RequestWork(ctx, workResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("download:", work.Download)
+
+	downloaded := "QmFile/QmDir"
+	length := 1048576
+
+	// The result is sent with the same stats as the request.
+	result := workResponse
+	result.Downloaded = &downloaded
+	result.Length = &length
+
+	err = client.RespondWork(ctx, result)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	work, err = client.RequestWork(ctx, workResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("no work:", work.NoWork())
+
+	// Output:
+	// download: https://example.com/episode.mp3
+	// no work: true
+}
+
+// A failed job is reported with its category, and the error.
+func ExampleWorkResponse_SetErrorMessage() {
+	workResponse := workapi.WorkResponse{
+		Email:   "email@example.com",
+		Version: workapi.ProtocolVersion,
+	}
+
+	workResponse.SetErrorMessage("too_small", "episode too small: 512 bytes, minimum is 1024")
+	workResponse.SetRetryHint(true, 0)
+
+	fmt.Println(*workResponse.Error, workResponse.ErrorReason, workResponse.ErrorPermanent)
+
+	// Output:
+	// 1 too_small true
+}
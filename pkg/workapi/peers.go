@@ -0,0 +1,73 @@
+package workapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrPeersNotSupported is when the server doesn't have a list of peers.
+var ErrPeersNotSupported = errors.New("server doesn't support peers")
+
+// Peer is a well-connected node of the network, which the other nodes keep
+// a connection to, so they find each other's content without going through
+// the DHT.
+type Peer struct {
+	// ID is the peer ID, like 12D3KooW...
+	ID string `json:"id"`
+	// Addrs are the multiaddrs of the peer, without the /p2p part, like
+	// /ip4/203.0.113.1/tcp/4001.
+	Addrs []string `json:"addrs"`
+}
+
+type peersResponse struct {
+	Peers []Peer `json:"peers"`
+}
+
+// Peers fetches the backbone peers of the network from /peers. It returns
+// ErrPeersNotSupported if the server doesn't have the list.
+//
+// The emails of the accounts the node works for identify it, and the
+// request is signed like the others, with an empty body, when a secret is
+// set.
+func (c *Client) Peers(ctx context.Context, emails ...string) ([]Peer, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		c.baseURL+"/peers?"+url.Values{"email": emails}.Encode(),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.send(c.httpClient, req, nil)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		return nil, ErrPeersNotSupported
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetching peers failed: status %d", resp.StatusCode)
+	}
+
+	var peers peersResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&peers)
+	if err != nil {
+		return nil, fmt.Errorf("decoding peers failed: %w", err)
+	}
+
+	return peers.Peers, nil
+}
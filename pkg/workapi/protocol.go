@@ -0,0 +1,155 @@
+package workapi
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// JSONContentType is the media type of the v2 protocol, where the requests
+// and responses are JSON, instead of the legacy form encoding.
+const JSONContentType = "application/vnd.ipfspodcasting.v2+json"
+
+// Protocol selects the encoding of the requests to the server.
+type Protocol string
+
+const (
+	// ProtocolAuto sends the legacy form encoding, and advertises the v2
+	// JSON protocol with the Accept header. It switches to JSON once the
+	// server responds with JSONContentType.
+	ProtocolAuto Protocol = "auto"
+	// ProtocolJSON only sends JSON. Requests the server rejects fail with
+	// ErrJSONRejected, instead of falling back to the form encoding.
+	ProtocolJSON Protocol = "json"
+	// ProtocolForm only sends the legacy form encoding.
+	ProtocolForm Protocol = "form"
+)
+
+// ParseProtocol parses auto, json, or form.
+func ParseProtocol(s string) (Protocol, error) {
+	switch Protocol(s) {
+	case ProtocolAuto, ProtocolJSON, ProtocolForm:
+		return Protocol(s), nil
+	}
+
+	return "", fmt.Errorf("protocol must be auto, json, or form: %q", s)
+}
+
+// JobError is the failure of a job, in the v2 protocol.
+type JobError struct {
+	// Code is the failure category, like "too_small" or "no_space".
+	Code string `json:"code"`
+	// Message is the error, truncated to MaxErrorMessageLength.
+	Message string `json:"message,omitempty"`
+	// Permanent is if the job is expected to fail on every node.
+	Permanent bool `json:"permanent,omitempty"`
+	// RetryAfter is the suggested number of seconds before the job is
+	// retried.
+	RetryAfter *int `json:"retry_after,omitempty"`
+	// Log is the log lines of the job, truncated to MaxErrorLogLength.
+	Log string `json:"log,omitempty"`
+}
+
+// jsonWorkResponse is the v2 encoding of a WorkResponse. Unlike the form
+// encoding, the numbers and booleans keep their types, and the error is an
+// object instead of 0 or 1.
+type jsonWorkResponse struct {
+	Email       string `json:"email"`
+	Version     string `json:"version"`
+	IPFSID      string `json:"ipfs_id"`
+	IPFSVersion string `json:"ipfs_ver"`
+	Online      bool   `json:"online"`
+	Peers       int    `json:"peers"`
+
+	Downloaded *string   `json:"downloaded,omitempty"`
+	Length     *int      `json:"length,omitempty"`
+	SHA256     *string   `json:"sha256,omitempty"`
+	Error      *JobError `json:"error,omitempty"`
+	Pinned     *string   `json:"pinned,omitempty"`
+	Deleted    *string   `json:"deleted,omitempty"`
+
+	Used           *int              `json:"used,omitempty"`
+	Avail          *int              `json:"avail,omitempty"`
+	FreeSpace      *int              `json:"free_space,omitempty"`
+	MaxEpisodeSize *int              `json:"max_size,omitempty"`
+	BandwidthClass string            `json:"bandwidth,omitempty"`
+	AcceptedJobs   []string          `json:"accept,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Served         *int              `json:"served,omitempty"`
+}
+
+// JSON returns the v2 JSON encoded body sent to the server.
+func (r WorkResponse) JSON() ([]byte, error) {
+	body := jsonWorkResponse{
+		Email:          r.Email,
+		Version:        r.Version,
+		IPFSID:         r.IPFSID,
+		IPFSVersion:    r.IPFSVersion,
+		Online:         r.Online,
+		Peers:          r.Peers,
+		Downloaded:     r.Downloaded,
+		Length:         r.Length,
+		SHA256:         r.SHA256,
+		Pinned:         r.Pinned,
+		Deleted:        r.Deleted,
+		Used:           r.Used,
+		Avail:          r.Avail,
+		FreeSpace:      r.FreeSpace,
+		MaxEpisodeSize: r.MaxEpisodeSize,
+		BandwidthClass: r.BandwidthClass,
+		AcceptedJobs:   r.AcceptedJobs,
+		Tags:           r.Tags,
+		Served:         r.Served,
+	}
+
+	if r.Error != nil && *r.Error != 0 {
+		body.Error = &JobError{
+			Code:       cmp.Or(r.ErrorReason, "unknown"),
+			Message:    r.ErrorMessage,
+			Permanent:  r.ErrorPermanent,
+			RetryAfter: r.RetryAfter,
+			Log:        r.ErrorLog,
+		}
+	}
+
+	return json.Marshal(body)
+}
+
+// isJSONResponse is if the server responded with the v2 protocol.
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return err == nil && mediaType == JSONContentType
+}
+
+// encode returns the body of workResponse, in the v2 JSON protocol, or the
+// legacy form encoding, with its content type.
+func encode(workResponse WorkResponse, useJSON bool) ([]byte, string, error) {
+	if useJSON {
+		body, err := workResponse.JSON()
+
+		return body, JSONContentType, err
+	}
+
+	body, err := io.ReadAll(workResponse.Reader())
+
+	return body, "application/x-www-form-urlencoded", err
+}
+
+// rejectsJSON is if the status means the server doesn't understand the v2
+// protocol, so the request should be sent again with the form encoding.
+func rejectsJSON(status int) bool {
+	switch status {
+	case http.StatusBadRequest,
+		http.StatusNotFound,
+		http.StatusMethodNotAllowed,
+		http.StatusNotAcceptable,
+		http.StatusUnsupportedMediaType:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package workapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Exchange is a request to the work server, and its response, as recorded
+// by a Recorder.
+type Exchange struct {
+	Time time.Time `json:"time"`
+	// Path is /request or /response.
+	Path        string `json:"path"`
+	ContentType string `json:"content_type"`
+	// Request is the body, as it was sent. The signature headers of
+	// signed requests aren't recorded.
+	Request string `json:"request"`
+
+	// Status of the response. Zero if the request failed, see Error.
+	Status              int    `json:"status,omitempty"`
+	RetryAfter          string `json:"retry_after,omitempty"`
+	ResponseContentType string `json:"response_content_type,omitempty"`
+	Response            string `json:"response,omitempty"`
+	// Error is why the request failed without a response, like a timeout.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder writes the exchanges with the work server as JSON lines, so
+// protocol bugs can be reproduced from the capture, see ReadExchanges. It's
+// safe for concurrent use, so several clients can share a Recorder.
+//
+// The requests contain the email of the node, so captures should only be
+// shared with people who may see it.
+type Recorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecorder creates a Recorder which writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Record writes exchange.
+func (r *Recorder) Record(exchange Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.encoder.Encode(exchange)
+}
+
+// ReadExchanges reads the exchanges written by a Recorder.
+func ReadExchanges(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+
+	line := 0
+
+	for scanner.Scan() {
+		line += 1
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var exchange Exchange
+
+		err := json.Unmarshal(scanner.Bytes(), &exchange)
+		if err != nil {
+			return nil, fmt.Errorf("decoding line %d failed: %w", line, err)
+		}
+
+		exchanges = append(exchanges, exchange)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("reading exchanges failed: %w", err)
+	}
+
+	return exchanges, nil
+}
+
+// SetRecorder records every request sent to the server, including the
+// retries, and its response. Nil disables recording.
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+// record records a request, when a Recorder is set. The body of the response
+// is read, and replaced, so the caller can still read it.
+func (c *Client) record(path string, contentType string, body []byte, resp *http.Response, err error) {
+	if c.recorder == nil {
+		return
+	}
+
+	exchange := Exchange{
+		Time:        time.Now(),
+		Path:        path,
+		ContentType: contentType,
+		Request:     string(body),
+	}
+
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		respBody, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+		exchange.Status = resp.StatusCode
+		exchange.RetryAfter = resp.Header.Get("Retry-After")
+		exchange.ResponseContentType = resp.Header.Get("Content-Type")
+		exchange.Response = string(respBody)
+
+		if readErr != nil {
+			exchange.Error = readErr.Error()
+		}
+	}
+
+	err = c.recorder.Record(exchange)
+	if err != nil {
+		c.logger().Warn("recording work server exchange failed", "err", err)
+	}
+}
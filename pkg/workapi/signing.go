@@ -0,0 +1,88 @@
+package workapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignedProtocolVersion is sent as the version instead of
+	// ProtocolVersion when the requests are signed, so the server knows to
+	// check the signature.
+	SignedProtocolVersion = ProtocolVersion + "+hmac"
+
+	// TimestampHeader is the Unix time in seconds when a signed request was
+	// made.
+	TimestampHeader = "X-Ipfspodcasting-Timestamp"
+	// SignatureHeader is the HMAC-SHA256 of a signed request, in the form
+	// sha256=<hex>.
+	SignatureHeader = "X-Ipfspodcasting-Signature"
+
+	// MaxClockSkew is how far the timestamp of a signed request may be from
+	// the server's time, which limits replays of captured requests.
+	MaxClockSkew = 5 * time.Minute
+)
+
+var (
+	ErrBadTimestamp = errors.New("bad timestamp")
+	ErrBadSignature = errors.New("bad signature")
+)
+
+// Sign is the value of the SignatureHeader of a request with the form
+// encoded body, made at the Unix time timestamp. The HMAC is over the
+// timestamp, a dot, and the body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the headers of a signed request, for servers which
+// implement the protocol. The timestamp must be within MaxClockSkew of now.
+func Verify(secret []byte, timestamp string, signature string, body []byte, now time.Time) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadTimestamp
+	}
+
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrBadTimestamp
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+// send sends req with httpClient, signing it with the body it was created
+// with first, if a secret is set. Every request goes through send, so the
+// timestamp is taken right before the request leaves, after anything it
+// waited for.
+func (c *Client) send(httpClient *http.Client, req *http.Request, body []byte) (*http.Response, error) {
+	// The wait for the rate limit can be longer than the clock skew the
+	// server allows, so it comes before the signature.
+	err := c.wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.secret) != 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(c.secret, timestamp, body))
+	}
+
+	return httpClient.Do(req)
+}
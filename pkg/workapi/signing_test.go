@@ -0,0 +1,177 @@
+package workapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte("email=email%40example.com&version=0.6g%2Bhmac")
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{
+			name:      "valid",
+			timestamp: timestamp,
+			signature: Sign(secret, timestamp, body),
+			body:      body,
+		},
+		{
+			name:      "within skew",
+			timestamp: strconv.FormatInt(now.Add(-MaxClockSkew).Unix(), 10),
+			signature: Sign(secret, strconv.FormatInt(now.Add(-MaxClockSkew).Unix(), 10), body),
+			body:      body,
+		},
+		{
+			name:      "other secret",
+			secret:    []byte("other"),
+			timestamp: timestamp,
+			signature: Sign(secret, timestamp, body),
+			body:      body,
+			want:      ErrBadSignature,
+		},
+		{
+			name:      "changed body",
+			timestamp: timestamp,
+			signature: Sign(secret, timestamp, body),
+			body:      []byte("email=other%40example.com&version=0.6g%2Bhmac"),
+			want:      ErrBadSignature,
+		},
+		{
+			// The timestamp is part of the signature, so it can't be
+			// replaced to replay a request.
+			name:      "changed timestamp",
+			timestamp: strconv.FormatInt(now.Unix()-1, 10),
+			signature: Sign(secret, timestamp, body),
+			body:      body,
+			want:      ErrBadSignature,
+		},
+		{
+			name:      "too old",
+			timestamp: strconv.FormatInt(now.Add(-MaxClockSkew-time.Second).Unix(), 10),
+			signature: Sign(secret, strconv.FormatInt(now.Add(-MaxClockSkew-time.Second).Unix(), 10), body),
+			body:      body,
+			want:      ErrBadTimestamp,
+		},
+		{
+			name:      "in the future",
+			timestamp: strconv.FormatInt(now.Add(MaxClockSkew+time.Second).Unix(), 10),
+			signature: Sign(secret, strconv.FormatInt(now.Add(MaxClockSkew+time.Second).Unix(), 10), body),
+			body:      body,
+			want:      ErrBadTimestamp,
+		},
+		{
+			name:      "invalid timestamp",
+			timestamp: "yesterday",
+			signature: Sign(secret, "yesterday", body),
+			body:      body,
+			want:      ErrBadTimestamp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifySecret := secret
+			if tt.secret != nil {
+				verifySecret = tt.secret
+			}
+
+			err := Verify(verifySecret, tt.timestamp, tt.signature, tt.body, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientSignsRequests(t *testing.T) {
+	secret := []byte("secret")
+
+	var (
+		header http.Header
+		body   []byte
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	client.SetSecret(string(secret))
+
+	before := time.Now()
+
+	err := client.RespondWork(context.Background(), WorkResponse{Email: "email@example.com"})
+	if err != nil {
+		t.Fatalf("responding failed: %v", err)
+	}
+
+	timestamp := header.Get(TimestampHeader)
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || unix < before.Unix() || unix > time.Now().Unix() {
+		t.Errorf("got timestamp %q, want the time the request was sent", timestamp)
+	}
+
+	err = Verify(secret, timestamp, header.Get(SignatureHeader), body, time.Now())
+	if err != nil {
+		t.Errorf("verifying the request failed: %v", err)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("version") != SignedProtocolVersion {
+		t.Errorf("got version %q, want %q", form.Get("version"), SignedProtocolVersion)
+	}
+}
+
+// slowLimiter makes every request wait, like a busy rate limit.
+type slowLimiter time.Duration
+
+func (l slowLimiter) Wait(ctx context.Context) error {
+	time.Sleep(time.Duration(l))
+
+	return nil
+}
+
+func TestClientSignsAfterRateLimit(t *testing.T) {
+	var timestamp string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp = r.Header.Get(TimestampHeader)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), server.URL)
+	client.SetSecret("secret")
+	client.SetLimiter(slowLimiter(1100 * time.Millisecond))
+
+	before := time.Now()
+
+	err := client.RespondWork(context.Background(), WorkResponse{Email: "email@example.com"})
+	if err != nil {
+		t.Fatalf("responding failed: %v", err)
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || unix < before.Add(1100*time.Millisecond).Unix() {
+		t.Errorf("got timestamp %q, want one after the wait for the rate limit", timestamp)
+	}
+}
@@ -0,0 +1,84 @@
+package workapi
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxTags is the number of tags a node may send.
+	MaxTags = 16
+	// MaxTagLength is the length in bytes of a tag's key, and of its value.
+	MaxTagLength = 64
+)
+
+var (
+	tagKeyPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+	tagValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.:/+-]+$`)
+)
+
+// CheckTags checks the tags can be sent. The keys are lowercase letters,
+// digits, and "_.-", like "region", and the values are letters, digits, and
+// "_.:/+-", like "eu-west" or "1gbit".
+func CheckTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("%d tags, maximum is %d", len(tags), MaxTags)
+	}
+
+	for key, value := range tags {
+		if len(key) > MaxTagLength || !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key: %q", key)
+		}
+
+		if len(value) > MaxTagLength || !tagValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value of tag %s: %q", key, value)
+		}
+	}
+
+	return nil
+}
+
+// FormatTags formats tags as comma separated key=value pairs, sorted by key,
+// like "region=eu,storage=large".
+func FormatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// ParseTags parses tags in the format of FormatTags, and checks them with
+// CheckTags. Empty is no tags.
+func ParseTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+
+	if s == "" {
+		return tags, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("tag %q isn't key=value", pair)
+		}
+
+		if _, ok := tags[key]; ok {
+			return nil, fmt.Errorf("duplicate tag: %s", key)
+		}
+
+		tags[key] = value
+	}
+
+	err := CheckTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,273 @@
+package workapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// WorkResponse is sent to the server when requesting work, and again with
+// the results of the work. The job fields are only set in the second case.
+type WorkResponse struct {
+	Email       string `json:"email"`
+	Version     string `json:"version"`
+	IPFSID      string `json:"ipfs_id"`
+	IPFSVersion string `json:"ipfs_ver"`
+	Online      bool   `json:"online"`
+	Peers       int    `json:"peers,string"`
+
+	Downloaded *string `json:"downloaded,omitempty"`
+	Length     *int    `json:"length,omitempty"`
+	// SHA256 is the hex encoded SHA-256 of the downloaded bytes, so the
+	// server can tell when nodes got different content for the same URL.
+	SHA256  *string `json:"sha256,omitempty"`
+	Error   *int    `json:"error,omitempty"`
+	Pinned  *string `json:"pinned,omitempty"`
+	Deleted *string `json:"deleted,omitempty"`
+
+	Used  *int `json:"used,omitempty"`
+	Avail *int `json:"avail,omitempty"`
+
+	// FreeSpace in bytes on the disk of the Kubo repo.
+	FreeSpace *int `json:"free_space,omitempty"`
+	// MaxEpisodeSize in bytes which the node accepts.
+	MaxEpisodeSize *int `json:"max_size,omitempty"`
+	// BandwidthClass is a rough speed of the node's downloads, one of
+	// "low", "medium", or "high".
+	BandwidthClass string `json:"bandwidth,omitempty"`
+	// AcceptedJobs are the job types the node accepts right now, like
+	// "download", "pin", and "delete". Sent comma separated as accept.
+	AcceptedJobs []string `json:"accept,omitempty"`
+	// Tags are declared by the operator, like region=eu or storage=large,
+	// so the server can assign work by location or size. Sent as tags, in
+	// the format of FormatTags. See CheckTags for the allowed keys and
+	// values.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Served is the number of bytes the node sent to peers over bitswap
+	// since the updater started, from the bitswap ledgers.
+	Served *int `json:"served,omitempty"`
+
+	// ErrorReason is the failure category, like "too_small" or "no_space".
+	// It's sent as error_code, so the server can tell why a job failed.
+	ErrorReason string `json:"error_code,omitempty"`
+	// ErrorMessage is the error of the failed job, truncated to
+	// MaxErrorMessageLength. Sent as error_message.
+	ErrorMessage string `json:"error_message,omitempty"`
+	// ErrorPermanent is if the job is expected to fail on every node, like
+	// when the episode is gone from the origin. Sent as error_permanent=1.
+	ErrorPermanent bool `json:"error_permanent,omitempty"`
+	// RetryAfter is the suggested number of seconds before the failed job
+	// is given to any node again. Sent as retry_after.
+	RetryAfter *int `json:"retry_after,omitempty"`
+	// ErrorLog is the log lines of the failed job, truncated to the last
+	// MaxErrorLogLength bytes. It's only sent with the v2 JSON protocol,
+	// see SetErrorLog.
+	ErrorLog string `json:"error_log,omitempty"`
+}
+
+// MaxErrorMessageLength is the length in bytes an error message is truncated
+// to, see SetErrorMessage.
+const MaxErrorMessageLength = 200
+
+// MaxErrorLogLength is the length in bytes an error log is truncated to, see
+// SetErrorLog.
+const MaxErrorLogLength = 4096
+
+// SetError marks the response as failed, with the failure category reason.
+func (r *WorkResponse) SetError(reason string) {
+	errInt := 1
+	r.Error = &errInt
+	r.ErrorReason = reason
+}
+
+// SetErrorMessage marks the response as failed, like SetError, and includes
+// the message of the error, truncated to MaxErrorMessageLength.
+func (r *WorkResponse) SetErrorMessage(reason string, message string) {
+	r.SetError(reason)
+	r.ErrorMessage = truncateMessage(message, MaxErrorMessageLength)
+}
+
+// SetRetryHint tells the server if the failed job is permanent, or when it
+// should be retried. A zero retryAfter isn't sent.
+func (r *WorkResponse) SetRetryHint(permanent bool, retryAfter time.Duration) {
+	r.ErrorPermanent = permanent
+	r.RetryAfter = nil
+
+	if retryAfter > 0 {
+		seconds := int(retryAfter.Seconds())
+		r.RetryAfter = &seconds
+	}
+}
+
+// SetErrorLog attaches the log lines of the failed job, so the owners of the
+// feed can see why it failed. Only the last MaxErrorLogLength bytes are
+// kept, starting at a whole line, since the lines closest to the failure are
+// the most useful.
+func (r *WorkResponse) SetErrorLog(lines string) {
+	r.ErrorLog = truncateLog(lines, MaxErrorLogLength)
+}
+
+// truncateLog keeps the last n bytes of lines, dropping the line which is
+// cut in half.
+func truncateLog(lines string, n int) string {
+	if len(lines) <= n {
+		return lines
+	}
+
+	lines = lines[len(lines)-n:]
+
+	_, rest, ok := strings.Cut(lines, "\n")
+	if ok {
+		return rest
+	}
+
+	return lines
+}
+
+// truncateMessage truncates message to at most n bytes, without cutting a
+// UTF-8 character in half.
+func truncateMessage(message string, n int) string {
+	if len(message) <= n {
+		return message
+	}
+
+	for n > 0 && !utf8.RuneStart(message[n]) {
+		n -= 1
+	}
+
+	return message[:n]
+}
+
+func (r WorkResponse) String() string {
+	sb := new(strings.Builder)
+
+	encoder := json.NewEncoder(sb)
+
+	_ = encoder.Encode(r)
+
+	return sb.String()
+}
+
+func boolToStr(b bool) string {
+	if b {
+		return "true"
+	}
+
+	return "false"
+}
+
+// Reader returns the form encoded body sent to the server.
+func (r WorkResponse) Reader() io.Reader {
+	data := url.Values{
+		"email":    {r.Email},
+		"version":  {r.Version},
+		"ipfs_id":  {r.IPFSID},
+		"ipfs_ver": {r.IPFSVersion},
+		"online":   {boolToStr(r.Online)},
+		"peers":    {strconv.Itoa(r.Peers)},
+	}
+
+	if r.Downloaded != nil {
+		data.Set("downloaded", *r.Downloaded)
+	}
+	if r.Length != nil {
+		data.Set("length", strconv.Itoa(*r.Length))
+	}
+	if r.SHA256 != nil {
+		data.Set("sha256", *r.SHA256)
+	}
+	if r.Error != nil {
+		data.Set("error", strconv.Itoa(*r.Error))
+	}
+	if r.ErrorReason != "" {
+		data.Set("error_code", r.ErrorReason)
+	}
+	if r.ErrorMessage != "" {
+		data.Set("error_message", r.ErrorMessage)
+	}
+	if r.ErrorPermanent {
+		data.Set("error_permanent", "1")
+	}
+	if r.RetryAfter != nil {
+		data.Set("retry_after", strconv.Itoa(*r.RetryAfter))
+	}
+	if r.Pinned != nil {
+		data.Set("pinned", *r.Pinned)
+	}
+	if r.Deleted != nil {
+		data.Set("deleted", *r.Deleted)
+	}
+	if r.Used != nil {
+		data.Set("used", strconv.Itoa(*r.Used))
+	}
+	if r.Avail != nil {
+		data.Set("avail", strconv.Itoa(*r.Avail))
+	}
+	if r.FreeSpace != nil {
+		data.Set("free_space", strconv.Itoa(*r.FreeSpace))
+	}
+	if r.MaxEpisodeSize != nil {
+		data.Set("max_size", strconv.Itoa(*r.MaxEpisodeSize))
+	}
+	if r.BandwidthClass != "" {
+		data.Set("bandwidth", r.BandwidthClass)
+	}
+	if r.AcceptedJobs != nil {
+		data.Set("accept", strings.Join(r.AcceptedJobs, ","))
+	}
+	if len(r.Tags) != 0 {
+		data.Set("tags", FormatTags(r.Tags))
+	}
+	if r.Served != nil {
+		data.Set("served", strconv.Itoa(*r.Served))
+	}
+
+	return strings.NewReader(data.Encode())
+}
+
+// Work is a job assigned by the server. Any combination of Download, Pin,
+// and Delete can be set. Message is "No Work" when there is nothing to do.
+type Work struct {
+	Show     string `json:"show"`
+	Episode  string `json:"episode"`
+	Download string `json:"download"`
+	Pin      string `json:"pin"`
+	Filename string `json:"filename"`
+	Delete   string `json:"delete"`
+	Message  string `json:"message"`
+	// Length of the download in bytes, if the server knows it. Zero if
+	// it's unknown.
+	Length int `json:"length,omitempty"`
+	// CID of the episode file, as reported by the nodes which downloaded
+	// it before, if the server knows it. A download with different content
+	// fails with a content_changed error.
+	CID string `json:"cid,omitempty"`
+	// TTL is how many seconds the episode is hosted for, like for
+	// time-limited promotions. The node unpins it when it expires, and
+	// reports it as deleted. Zero hosts it until a delete job.
+	TTL int `json:"ttl,omitempty"`
+
+	// RetryAfter is the time the server asked the client to wait before
+	// requesting work again, from the Retry-After header. Zero if the
+	// server didn't ask.
+	RetryAfter time.Duration `json:"-"`
+}
+
+// NoWork is if the server had nothing to do.
+func (w Work) NoWork() bool {
+	return w.Message == "No Work"
+}
+
+func (w Work) String() string {
+	sb := new(strings.Builder)
+
+	encoder := json.NewEncoder(sb)
+
+	_ = encoder.Encode(w)
+
+	return sb.String()
+}
@@ -0,0 +1,186 @@
+package workapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// jsonWorkResponse is the v2 encoding of a WorkResponse, see
+// workapi.WorkResponse.JSON.
+type jsonWorkResponse struct {
+	Email       string `json:"email"`
+	Version     string `json:"version"`
+	IPFSID      string `json:"ipfs_id"`
+	IPFSVersion string `json:"ipfs_ver"`
+	Online      bool   `json:"online"`
+	Peers       int    `json:"peers"`
+
+	Downloaded *string           `json:"downloaded"`
+	Length     *int              `json:"length"`
+	SHA256     *string           `json:"sha256"`
+	Error      *workapi.JobError `json:"error"`
+	Pinned     *string           `json:"pinned"`
+	Deleted    *string           `json:"deleted"`
+
+	Used           *int              `json:"used"`
+	Avail          *int              `json:"avail"`
+	FreeSpace      *int              `json:"free_space"`
+	MaxEpisodeSize *int              `json:"max_size"`
+	BandwidthClass string            `json:"bandwidth"`
+	AcceptedJobs   []string          `json:"accept"`
+	Tags           map[string]string `json:"tags"`
+	Served         *int              `json:"served"`
+}
+
+// Decode parses the body of a request, in the v2 JSON protocol, or the
+// legacy form encoding, depending on the contentType, like the Server does.
+// It also reads the requests recorded by a workapi.Recorder.
+func Decode(contentType string, body []byte) (workapi.WorkResponse, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return workapi.WorkResponse{}, fmt.Errorf("parsing content type failed: %w", err)
+	}
+
+	switch mediaType {
+	case workapi.JSONContentType:
+		return decodeJSON(body)
+	case "application/x-www-form-urlencoded":
+		return decodeForm(body)
+	}
+
+	return workapi.WorkResponse{}, fmt.Errorf("unsupported content type: %q", mediaType)
+}
+
+func decodeJSON(body []byte) (workapi.WorkResponse, error) {
+	var r jsonWorkResponse
+
+	err := json.Unmarshal(body, &r)
+	if err != nil {
+		return workapi.WorkResponse{}, fmt.Errorf("decoding json failed: %w", err)
+	}
+
+	workResponse := workapi.WorkResponse{
+		Email:          r.Email,
+		Version:        r.Version,
+		IPFSID:         r.IPFSID,
+		IPFSVersion:    r.IPFSVersion,
+		Online:         r.Online,
+		Peers:          r.Peers,
+		Downloaded:     r.Downloaded,
+		Length:         r.Length,
+		SHA256:         r.SHA256,
+		Pinned:         r.Pinned,
+		Deleted:        r.Deleted,
+		Used:           r.Used,
+		Avail:          r.Avail,
+		FreeSpace:      r.FreeSpace,
+		MaxEpisodeSize: r.MaxEpisodeSize,
+		BandwidthClass: r.BandwidthClass,
+		AcceptedJobs:   r.AcceptedJobs,
+		Tags:           r.Tags,
+		Served:         r.Served,
+	}
+
+	if r.Error != nil {
+		workResponse.SetError(r.Error.Code)
+		workResponse.ErrorMessage = r.Error.Message
+		workResponse.ErrorPermanent = r.Error.Permanent
+		workResponse.RetryAfter = r.Error.RetryAfter
+		workResponse.ErrorLog = r.Error.Log
+	}
+
+	return workResponse, nil
+}
+
+func decodeForm(body []byte) (workapi.WorkResponse, error) {
+	data, err := url.ParseQuery(string(body))
+	if err != nil {
+		return workapi.WorkResponse{}, fmt.Errorf("parsing form failed: %w", err)
+	}
+
+	workResponse := workapi.WorkResponse{
+		Email:          data.Get("email"),
+		Version:        data.Get("version"),
+		IPFSID:         data.Get("ipfs_id"),
+		IPFSVersion:    data.Get("ipfs_ver"),
+		Online:         data.Get("online") == "true",
+		Downloaded:     optionalString(data, "downloaded"),
+		SHA256:         optionalString(data, "sha256"),
+		Pinned:         optionalString(data, "pinned"),
+		Deleted:        optionalString(data, "deleted"),
+		ErrorReason:    data.Get("error_code"),
+		ErrorMessage:   data.Get("error_message"),
+		ErrorPermanent: data.Get("error_permanent") == "1",
+		BandwidthClass: data.Get("bandwidth"),
+	}
+
+	if data.Has("accept") {
+		workResponse.AcceptedJobs = strings.Split(data.Get("accept"), ",")
+	}
+
+	if data.Has("tags") {
+		tags, err := workapi.ParseTags(data.Get("tags"))
+		if err != nil {
+			return workapi.WorkResponse{}, fmt.Errorf("parsing tags failed: %w", err)
+		}
+
+		workResponse.Tags = tags
+	}
+
+	ints := []struct {
+		key   string
+		value **int
+	}{
+		{"length", &workResponse.Length},
+		{"error", &workResponse.Error},
+		{"retry_after", &workResponse.RetryAfter},
+		{"used", &workResponse.Used},
+		{"avail", &workResponse.Avail},
+		{"free_space", &workResponse.FreeSpace},
+		{"max_size", &workResponse.MaxEpisodeSize},
+		{"served", &workResponse.Served},
+	}
+
+	for _, field := range ints {
+		*field.value, err = optionalInt(data, field.key)
+		if err != nil {
+			return workapi.WorkResponse{}, err
+		}
+	}
+
+	workResponse.Peers, err = strconv.Atoi(data.Get("peers"))
+	if err != nil {
+		return workapi.WorkResponse{}, fmt.Errorf("parsing peers failed: %w", err)
+	}
+
+	return workResponse, nil
+}
+
+func optionalString(data url.Values, key string) *string {
+	if !data.Has(key) {
+		return nil
+	}
+
+	value := data.Get(key)
+
+	return &value
+}
+
+func optionalInt(data url.Values, key string) (*int, error) {
+	if !data.Has(key) {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(data.Get(key))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %w", key, err)
+	}
+
+	return &value, nil
+}
@@ -0,0 +1,33 @@
+// Package workapitest provides a fake IPFS Podcasting work server, for tests
+// of code using the work protocol, like the work loop, which shouldn't
+// touch ipfspodcasting.net.
+//
+// The Server answers requests for work with the queued replies, in order,
+// and "No Work" once they run out. It records every request, and the
+// results sent to /response:
+//
+//	server := workapitest.NewServer()
+//	defer server.Close()
+//
+//	server.Queue(workapi.Work{
+//		Show:     "show",
+//		Episode:  "episode",
+//		Download: "https://example.com/episode.mp3",
+//		Filename: "episode.mp3",
+//	})
+//
+//	u, err := updater.New(k, updater.Options{
+//		Email:     "email@example.com",
+//		ServerURL: server.URL,
+//	})
+//
+//	responses, err := server.WaitResponses(ctx, 1)
+//
+// With SetEvents, it also serves the event stream used with
+// updater.Options.Push, and Announce tells the connected clients to request
+// work right away. SetPeers serves the backbone peers used with
+// updater.Options.Peering.
+//
+// SetResponseStatus rejects the results, to test that they're kept until
+// the server is back.
+package workapitest
@@ -0,0 +1,477 @@
+package workapitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/angaz/ipfspodcasting/pkg/workapi"
+)
+
+// Reply is the answer to a request for work.
+type Reply struct {
+	Work workapi.Work
+	// Status is the HTTP status of the reply. Zero is 200 OK. Replies with
+	// any other status have an empty body, like to test the retries of
+	// server errors.
+	Status int
+	// RetryAfter is sent as the Retry-After header, in seconds. Zero
+	// doesn't send it.
+	RetryAfter time.Duration
+}
+
+// Request is a request received by the Server.
+type Request struct {
+	// Path is /request or /response.
+	Path        string
+	ContentType string
+	Header      http.Header
+	// WorkResponse is the decoded body.
+	WorkResponse workapi.WorkResponse
+}
+
+// Server is a fake work server, running on a local httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+	json     bool
+	secret   []byte
+	// changed is closed, and replaced, when a request is recorded, so
+	// WaitResponses can wait for the next one.
+	changed chan struct{}
+
+	events bool
+	// subscribers are the connected event streams. Their channel gets the
+	// events, and is closed to disconnect them.
+	subscribers map[chan string]struct{}
+
+	// peers are served at /peers, which responds with 404 Not Found while
+	// it's nil.
+	peers []workapi.Peer
+
+	// responseStatus rejects the results sent to /response, unless it's
+	// zero.
+	responseStatus int
+}
+
+// NewServer starts a Server which only supports the legacy form encoding,
+// like ipfspodcasting.net. The caller should call Close when finished, to
+// shut it down.
+func NewServer() *Server {
+	s := &Server{
+		changed:     make(chan struct{}),
+		subscribers: map[chan string]struct{}{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /request", s.handleRequest)
+	mux.HandleFunc("POST /response", s.handleResponse)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	mux.HandleFunc("GET /peers", s.handlePeers)
+	mux.HandleFunc("HEAD /{$}", func(http.ResponseWriter, *http.Request) {})
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// SetJSON enables the v2 JSON protocol. Without it, JSON requests are
+// rejected with 415 Unsupported Media Type, like a legacy server.
+func (s *Server) SetJSON(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.json = enabled
+}
+
+// SetEvents enables the event stream at /events. Without it, the stream
+// responds with 404 Not Found, like a server which doesn't push work.
+func (s *Server) SetEvents(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = enabled
+}
+
+// SetPeers sets the backbone peers served at /peers. Nil responds with 404
+// Not Found, like a server which doesn't have the list.
+func (s *Server) SetPeers(peers []workapi.Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.peers = peers
+}
+
+// Announce sends a work event to the connected event streams, which makes
+// the clients request work right away. Queue the work first. It returns the
+// number of streams which got it.
+func (s *Server) Announce() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for subscriber := range s.subscribers {
+		// A client with events waiting requests work anyway.
+		select {
+		case subscriber <- workapi.EventWork:
+		default:
+		}
+	}
+
+	return len(s.subscribers)
+}
+
+// Subscribers returns the number of connected event streams.
+func (s *Server) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subscribers)
+}
+
+// DisconnectEvents closes the connected event streams, like when the
+// connection drops, to test the fallback to polling.
+func (s *Server) DisconnectEvents() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for subscriber := range s.subscribers {
+		close(subscriber)
+		delete(s.subscribers, subscriber)
+	}
+}
+
+// Close disconnects the event streams, and shuts down the server.
+func (s *Server) Close() {
+	s.DisconnectEvents()
+	s.Server.Close()
+}
+
+// SetResponseStatus makes the server reject the results sent to /response
+// with status, without recording them, like while it's overloaded. Zero
+// accepts them again.
+func (s *Server) SetResponseStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responseStatus = status
+}
+
+// SetSecret makes the server require requests signed with secret, and
+// reject the others with 401 Unauthorized. Empty accepts unsigned requests.
+func (s *Server) SetSecret(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secret = []byte(secret)
+}
+
+// Queue adds work to the end of the replies.
+func (s *Server) Queue(work ...workapi.Work) {
+	replies := make([]Reply, 0, len(work))
+	for _, w := range work {
+		replies = append(replies, Reply{Work: w})
+	}
+
+	s.QueueReply(replies...)
+}
+
+// QueueReply adds replies to the end of the replies.
+func (s *Server) QueueReply(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns all the requests received so far, to both paths.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Responses returns the results sent to /response so far.
+func (s *Server) Responses() []workapi.WorkResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.responses()
+}
+
+func (s *Server) responses() []workapi.WorkResponse {
+	var responses []workapi.WorkResponse
+
+	for _, request := range s.requests {
+		if request.Path == "/response" {
+			responses = append(responses, request.WorkResponse)
+		}
+	}
+
+	return responses
+}
+
+// WaitResponses waits until at least n results were sent to /response, and
+// returns them, or ctx is done.
+func (s *Server) WaitResponses(ctx context.Context, n int) ([]workapi.WorkResponse, error) {
+	for {
+		s.mu.Lock()
+		responses := s.responses()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(responses) >= n {
+			return responses, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return responses, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// record decodes and verifies the request, and records it. It returns if
+// the request was accepted, and if the reply should use the v2 protocol. The
+// error response is written when the request is rejected.
+func (s *Server) record(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return false, false
+	}
+
+	contentType := r.Header.Get("Content-Type")
+
+	s.mu.Lock()
+	useJSON := s.json
+	secret := s.secret
+	s.mu.Unlock()
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == workapi.JSONContentType && !useJSON {
+		http.Error(w, "json is not supported", http.StatusUnsupportedMediaType)
+
+		return false, false
+	}
+
+	if len(secret) != 0 {
+		err := workapi.Verify(
+			secret,
+			r.Header.Get(workapi.TimestampHeader),
+			r.Header.Get(workapi.SignatureHeader),
+			body,
+			time.Now(),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return false, false
+		}
+	}
+
+	workResponse, err := Decode(contentType, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return false, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Path:         r.URL.Path,
+		ContentType:  contentType,
+		Header:       r.Header.Clone(),
+		WorkResponse: workResponse,
+	})
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+
+	return true, useJSON
+}
+
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	ok, useJSON := s.record(w, r)
+	if !ok {
+		return
+	}
+
+	reply := Reply{
+		Work: workapi.Work{
+			Message: "No Work",
+		},
+	}
+
+	s.mu.Lock()
+	if len(s.replies) != 0 {
+		reply = s.replies[0]
+		s.replies = s.replies[1:]
+	}
+	s.mu.Unlock()
+
+	if reply.RetryAfter != 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(reply.RetryAfter.Seconds())))
+	}
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		w.WriteHeader(reply.Status)
+
+		return
+	}
+
+	writeJSON(w, useJSON, reply.Work)
+}
+
+func (s *Server) handleResponse(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := s.responseStatus
+	s.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+
+		return
+	}
+
+	ok, useJSON := s.record(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, useJSON, map[string]string{
+		"message": "OK",
+	})
+}
+
+// writeJSON writes v as the body. The content type tells the client if the
+// server supports the v2 protocol.
+func writeJSON(w http.ResponseWriter, useJSON bool, v any) {
+	contentType := "application/json"
+	if useJSON {
+		contentType = workapi.JSONContentType
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// handlePeers serves the peers set with SetPeers.
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	peers := s.peers
+	secret := s.secret
+	s.mu.Unlock()
+
+	if peers == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if len(secret) != 0 {
+		err := workapi.Verify(
+			secret,
+			r.Header.Get(workapi.TimestampHeader),
+			r.Header.Get(workapi.SignatureHeader),
+			nil,
+			time.Now(),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"peers": peers,
+	})
+}
+
+// handleEvents streams the events sent with Announce, as server-sent
+// events, until DisconnectEvents.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	enabled := s.events
+	secret := s.secret
+	s.mu.Unlock()
+
+	if !enabled {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if len(secret) != 0 {
+		err := workapi.Verify(
+			secret,
+			r.Header.Get(workapi.TimestampHeader),
+			r.Header.Get(workapi.SignatureHeader),
+			nil,
+			time.Now(),
+		)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	// Buffered, so Announce doesn't block on a slow client.
+	subscriber := make(chan string, 16)
+
+	s.mu.Lock()
+	s.subscribers[subscriber] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subscribers, subscriber)
+	}()
+
+	w.Header().Set("Content-Type", workapi.EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscriber:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata:\n\n", event)
+			flusher.Flush()
+		}
+	}
+}